@@ -0,0 +1,151 @@
+package restify
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// NearDuplicateDistance is the largest FingerprintDistance at which two pages are
+// considered near duplicates by IsNearDuplicate.
+const NearDuplicateDistance = 3
+
+// fingerprintShingle is the number of consecutive words hashed together into a feature.
+const fingerprintShingle = 3
+
+// CanonicalURL determines the preferred URL of the page at root, which was retrieved from
+// fetchedURL. The page's <link rel="canonical"> is used if present, then its og:url meta
+// property, and otherwise fetchedURL itself. The result is absolute and has no fragment.
+func CanonicalURL(root *html.Node, fetchedURL *url.URL) *url.URL {
+	var candidates []string
+	if link, ok := scrape.Find(root, matchLinkRel("canonical")); ok {
+		candidates = append(candidates, scrape.Attr(link, "href"))
+	}
+	if meta, ok := scrape.Find(root, matchMetaProperty("og:url")); ok {
+		candidates = append(candidates, scrape.Attr(meta, "content"))
+	}
+
+	result := *fetchedURL
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		if resolved, ok := resolveReference(fetchedURL, candidate); ok {
+			result = *resolved
+			break
+		}
+	}
+	result.Fragment = ""
+	result.RawFragment = ""
+	return &result
+}
+
+// matchLinkRel matches <link> elements having rel among the tokens of their rel attribute.
+func matchLinkRel(rel string) scrape.Matcher {
+	return func(n *html.Node) bool {
+		return n.DataAtom == atom.Link && hasRel(n, rel)
+	}
+}
+
+// hasRel reports whether the rel attribute of node includes the given token, ignoring case.
+func hasRel(node *html.Node, rel string) bool {
+	for _, token := range strings.Fields(scrape.Attr(node, "rel")) {
+		if strings.EqualFold(token, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMetaProperty matches <meta> elements whose property or name attribute is the given value.
+func matchMetaProperty(property string) scrape.Matcher {
+	return func(n *html.Node) bool {
+		return n.DataAtom == atom.Meta &&
+			(strings.EqualFold(scrape.Attr(n, "property"), property) || strings.EqualFold(scrape.Attr(n, "name"), property))
+	}
+}
+
+// ContentFingerprint computes a 64-bit SimHash of the visible text of the page at root.
+// Unlike an exact hash, pages whose text differs only slightly, such as by a timestamp or an
+// advert, have fingerprints that differ in only a few bits. Compare fingerprints with
+// FingerprintDistance or IsNearDuplicate.
+func ContentFingerprint(root *html.Node) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(visibleText(root)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	var weights [64]int
+	addFeature := func(feature string) {
+		h := fnv.New64a()
+		//goland:noinspection GoUnhandledErrorResult
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<uint(bit)) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	if len(words) < fingerprintShingle {
+		addFeature(strings.Join(words, " "))
+	}
+	for i := 0; i+fingerprintShingle <= len(words); i++ {
+		addFeature(strings.Join(words[i:i+fingerprintShingle], " "))
+	}
+
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << uint(bit)
+		}
+	}
+	return fingerprint
+}
+
+// FingerprintDistance is the number of bits that differ between two content fingerprints.
+func FingerprintDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// IsNearDuplicate reports whether two content fingerprints are within NearDuplicateDistance.
+func IsNearDuplicate(a, b uint64) bool {
+	return FingerprintDistance(a, b) <= NearDuplicateDistance
+}
+
+// visibleText joins the text of root, skipping the content of script, style, and similar
+// elements that are not rendered.
+func visibleText(root *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			if trimmed := strings.TrimSpace(n.Data); trimmed != "" {
+				if b.Len() > 0 {
+					b.WriteString(" ")
+				}
+				b.WriteString(trimmed)
+			}
+			return
+		case html.ElementNode:
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Head:
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return b.String()
+}
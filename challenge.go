@@ -0,0 +1,142 @@
+package restify
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ChallengeVendor identifies the anti-bot service that served a challenge page.
+type ChallengeVendor string
+
+const (
+	// ChallengeCloudflare is the Cloudflare browser check or managed challenge
+	ChallengeCloudflare ChallengeVendor = "cloudflare"
+	// ChallengeAkamai is the Akamai Bot Manager challenge or edge denial
+	ChallengeAkamai ChallengeVendor = "akamai"
+	// ChallengePerimeterX is the PerimeterX, now HUMAN, press and hold challenge
+	ChallengePerimeterX ChallengeVendor = "perimeterx"
+	// ChallengeDataDome is the DataDome CAPTCHA
+	ChallengeDataDome ChallengeVendor = "datadome"
+	// ChallengeImperva is the Imperva Incapsula challenge
+	ChallengeImperva ChallengeVendor = "imperva"
+)
+
+// ErrChallenge matches every ChallengeError with errors.Is.
+var ErrChallenge = errors.New("challenge page")
+
+// ChallengeError is returned by a Loader configured WithChallengeDetection in place of a page
+// that is an anti-bot challenge rather than the content requested, such as so that the request
+// can be retried through a different proxy.
+type ChallengeError struct {
+	// Vendor is the service that served the challenge
+	Vendor ChallengeVendor
+	// URL is the location of the challenge page
+	URL *url.URL
+	// StatusCode is the HTTP status of the challenge page
+	StatusCode int
+}
+
+func (e *ChallengeError) Error() string {
+	return fmt.Sprintf("Page %s is a %s challenge with status %d", e.URL, e.Vendor, e.StatusCode)
+}
+
+// Is reports whether target is ErrChallenge.
+func (e *ChallengeError) Is(target error) bool {
+	return target == ErrChallenge
+}
+
+// WithChallengeDetection makes loads fail with a *ChallengeError when the page loaded is an
+// anti-bot challenge, as detected by DetectChallenge, rather than returning the challenge page.
+func WithChallengeDetection() LoaderOption {
+	return func(l *Loader) {
+		l.detectChallenges = true
+	}
+}
+
+// maxChallengeWords is the number of words of visible text above which a page is not considered
+// a challenge by markers that also appear on ordinary pages protected by the same service.
+const maxChallengeWords = 50
+
+// DetectChallenge determines whether resp is a challenge page of an anti-bot service, from its
+// headers and from markers in its content. Since the scripts of these services are often also
+// included on ordinary pages, markers that are not specific to challenges only count for
+// responses with an error status or little text. If resp is not a challenge, then ok will be
+// false.
+func DetectChallenge(resp *Response) (vendor ChallengeVendor, ok bool) {
+	header := resp.Header
+	if header == nil {
+		header = make(http.Header)
+	}
+	markers := challengeMarkers(resp.Root)
+	has := func(values ...string) bool {
+		for _, value := range values {
+			if strings.Contains(markers, value) {
+				return true
+			}
+		}
+		return false
+	}
+	blocked := resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode == http.StatusServiceUnavailable
+	suspicious := blocked || len(strings.Fields(visibleText(resp.Root))) <= maxChallengeWords
+	server := strings.ToLower(header.Get("Server"))
+
+	switch {
+	case strings.EqualFold(header.Get("Cf-Mitigated"), "challenge"),
+		(server == "cloudflare" || header.Get("Cf-Ray") != "") && suspicious &&
+			has("challenge-platform", "cf-chl", "cf_chl", "cf-browser-verification", "just a moment", "attention required"):
+		return ChallengeCloudflare, true
+
+	case has("bm-verify", "sec-if-cpt-container", "_sec/cp_challenge"),
+		strings.HasPrefix(server, "akamaighost") && blocked && has("access denied"):
+		return ChallengeAkamai, true
+
+	case has("px-captcha") && suspicious:
+		return ChallengePerimeterX, true
+
+	case has("captcha-delivery.com"),
+		header.Get("X-Datadome") != "" && blocked:
+		return ChallengeDataDome, true
+
+	case has("incapsula incident id"),
+		has("_incapsula_resource") && suspicious:
+		return ChallengeImperva, true
+	}
+	return "", false
+}
+
+// challengeMarkers gathers the lowercase title, visible text, script content, and identifying
+// attributes of the page at root, in which the markers of challenge pages are searched for.
+func challengeMarkers(root *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			b.WriteString(" ")
+		case html.ElementNode:
+			if n.DataAtom == atom.Style {
+				return
+			}
+			for _, a := range n.Attr {
+				switch a.Key {
+				case "id", "class", "src", "action", "href":
+					b.WriteString(a.Val)
+					b.WriteString(" ")
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(root)
+	return strings.ToLower(b.String())
+}
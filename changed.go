@@ -0,0 +1,82 @@
+package restify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/html"
+)
+
+// PageState records what was learned of a page when it was last loaded, so that LoadIfChanged
+// can tell whether it has changed since. Its zero value is the state of a page never loaded.
+type PageState struct {
+	// ETag is the entity tag the server gave the page, if any
+	ETag string `json:"etag,omitempty"`
+	// LastModified is the Last-Modified time the server gave the page, if any, as sent
+	LastModified string `json:"lastModified,omitempty"`
+	// Hash is the hex encoded SHA-256 of the parsed page, as rendered by html.Render, or as
+	// normalized first if the Loader was configured WithNormalizedHashing
+	Hash string `json:"hash,omitempty"`
+}
+
+type conditionalKey struct{}
+
+// LoadIfChanged retrieves the HTML content from the given url unless it is unchanged since it
+// was last loaded, as recorded by last, returning the page's new state to pass to the next call.
+//
+// The request is conditional on the ETag and Last-Modified of last, so servers supporting them
+// can respond without the content, in which case root is nil. Otherwise the content is compared
+// by hash, which also catches servers that ignore the conditions, and root is the page even if
+// it is unchanged. A page is always changed when last is the zero PageState. A response with an
+// error status is an error, returned along with last.
+func (l *Loader) LoadIfChanged(url *url.URL, last PageState) (root *html.Node, state PageState, changed bool, err error) {
+	return l.LoadIfChangedContext(context.Background(), url, last)
+}
+
+// LoadIfChangedContext is like LoadIfChanged but the request is bound to the given context, in
+// addition to the Loader's timeout.
+func (l *Loader) LoadIfChangedContext(ctx context.Context, url *url.URL, last PageState) (root *html.Node, state PageState, changed bool, err error) {
+	resp, err := l.Fetch(context.WithValue(ctx, conditionalKey{}, last), url)
+	if err != nil {
+		return nil, last, false, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, last, false, nil
+	}
+	if resp.StatusCode >= 400 {
+		// error pages say nothing of the page, so neither their validators nor hash are kept
+		return nil, last, false, fmt.Errorf("Page %s responded with status %d", resp.URL, resp.StatusCode)
+	}
+
+	hash, err := l.pageHash(resp.Root)
+	if err != nil {
+		return nil, last, false, err
+	}
+	state = PageState{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Hash:         hash,
+	}
+	return resp.Root, state, state.Hash != last.Hash, nil
+}
+
+// pageHash computes the hash of the page at root that changes are detected by, its NormalizedHash
+// when the Loader was configured WithNormalizedHashing.
+func (l *Loader) pageHash(root *html.Node) (string, error) {
+	if l.hashOptions != nil {
+		return NormalizedHash(root, *l.hashOptions)
+	}
+	return documentHash(root), nil
+}
+
+// documentHash computes the hex encoded SHA-256 of the rendering of root.
+func documentHash(root *html.Node) string {
+	hash := sha256.New()
+	//goland:noinspection GoUnhandledErrorResult
+	html.Render(hash, root)
+	return hex.EncodeToString(hash.Sum(nil))
+}
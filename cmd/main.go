@@ -1,7 +1,9 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"strings"
@@ -26,7 +28,17 @@ var (
 		String()
 	byTagName   = kingpin.Flag("tag", "If specified, the first-level element with this tag name will be extracted.").String()
 	byAttribute = kingpin.Flag("attribute",
-		"If specified, as key=value, the element with the given attribute name set to the given value is extracted.").
+		"If specified, as key=value, the element with the given attribute name set to the given value is extracted. The CSS operators ^=, $=, *=, ~=, and |= may be used in place of =.").
+		String()
+	bySelector = kingpin.Flag("selector", "If specified, the elements matching this CSS selector will be extracted.").
+			String()
+	explain = kingpin.Flag("explain", "Instead of extracting, report how the --selector matched the page step by step.").
+		Bool()
+	outline = kingpin.Flag("outline", "Instead of extracting, summarize the structure of the extracted elements as text or json.").
+		Enum("text", "json")
+	outlineDepth = kingpin.Flag("outline-depth", "Limits the levels of elements included by --outline, unlimited if zero.").
+			Default("0").Int()
+	query = kingpin.Flag("query", "If specified, a GraphQL-like query, or @file to read one from, describing the fields to extract as a JSON object.").
 		String()
 	showVersion = kingpin.Flag("version", "Print version and exit").
 			Bool()
@@ -37,6 +49,8 @@ var (
 			String()
 	headers = kingpin.Flag("headers", "Additional headers to pass with request").
 		StringMap()
+	cookies = kingpin.Flag("cookies", "A cookies.txt or JSON file of cookies exported from a browser to send with the request").
+		String()
 )
 
 func main() {
@@ -53,12 +67,50 @@ func main() {
 		configs = append(configs, restify.WithHeaders(*headers))
 	}
 
-	root, err := restify.LoadContent(*url, *userAgent, configs...)
+	options := []restify.LoaderOption{restify.WithUserAgent(*userAgent), restify.WithRequestConfigs(configs...)}
+	if *cookies != "" {
+		imported, err := restify.ReadCookieFile(*cookies)
+		if err != nil {
+			log.Fatal(err)
+		}
+		options = append(options, restify.WithCookies(imported))
+	}
+
+	root, err := restify.NewLoader(options...).Load(*url)
 
 	if err != nil {
 		log.Fatal("Failed to load content: ", err)
 	}
 
+	if *explain {
+		if *bySelector == "" {
+			log.Fatal("--explain requires --selector")
+		}
+		explanation, err := restify.Explain(root, *bySelector)
+		if err != nil {
+			log.Fatal("Failed to explain selector: ", err)
+		}
+		fmt.Print(explanation)
+		os.Exit(0)
+	}
+
+	if *query != "" {
+		text := *query
+		if strings.HasPrefix(text, "@") {
+			content, err := ioutil.ReadFile(text[1:])
+			if err != nil {
+				log.Fatal("Failed to read query: ", err)
+			}
+			text = string(content)
+		}
+		asJson, err := restify.ResolveGraphQuery(root, text)
+		if err != nil {
+			log.Fatal("Failed to resolve query: ", err)
+		}
+		fmt.Print(string(asJson))
+		os.Exit(0)
+	}
+
 	var subset []*html.Node
 	if *byId != "" {
 		elem, ok := restify.FindSubsetById(root, *byId)
@@ -81,16 +133,46 @@ func main() {
 		key := keyVal[0]
 		if len(keyVal) == 1 {
 			subset = restify.FindSubsetByAttributeName(root, key)
+		} else if i := len(key) - 1; i > 0 && strings.ContainsRune("^$*~|", rune(key[i])) {
+			op := restify.AttributeOperator(key[i:] + "=")
+			subset = restify.FindSubsetByAttributeOperator(root, key[:i], op, keyVal[1])
 		} else {
 			subset = restify.FindSubsetByAttributeNameValue(root, key, keyVal[1])
 		}
 		if len(subset) == 0 {
 			log.Fatalf("Unable to find an element with attribute matcher %s", *byAttribute)
 		}
+	} else if *bySelector != "" {
+		subset, err = restify.FindSubsetBySelector(root, *bySelector)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(subset) == 0 {
+			log.Fatalf("Unable to find an element matching the selector '%s'\n", *bySelector)
+		}
 	} else {
 		subset = append(subset, root)
 	}
 
+	if *outline != "" {
+		outlines := make([]*restify.OutlineNode, len(subset))
+		for i, n := range subset {
+			outlines[i] = restify.Outline(n, *outlineDepth)
+		}
+		if *outline == "json" {
+			asJson, err := json.Marshal(outlines)
+			if err != nil {
+				log.Fatal("Failed to encode outline: ", err)
+			}
+			fmt.Print(string(asJson))
+		} else {
+			for _, o := range outlines {
+				fmt.Print(o)
+			}
+		}
+		os.Exit(0)
+	}
+
 	asJson, err := restify.ConvertHtmlToJson(subset)
 	if err != nil {
 		log.Fatal("Failed to parse HTML into JSON", err)
@@ -0,0 +1,313 @@
+// Command restify-gen generates a Go struct, and a function extracting it from pages, from a
+// restify ruleset in JSON or YAML, so that extracted records have typed fields rather than
+// being maps. The fields are typed by the type of each rule: strings, float64 for numbers,
+// int64 for integers, restify.Price, time.Time for dates, and *url.URL, or slices of these for
+// rules extracting multiple values. It is intended to be run by go generate:
+//
+//	//go:generate go run github.com/comnoco/restify/cmd/restify-gen --type Product products.yaml
+//
+// which writes products_restify.go, declaring the Product struct and ExtractProduct function in
+// the package of the file containing the directive.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/comnoco/restify"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	rulesetPath = kingpin.Arg("ruleset", "The JSON or YAML ruleset to generate from").
+			Required().String()
+	typeName = kingpin.Flag("type", "The name of the generated struct, by default derived from the ruleset's file name").
+			String()
+	packageName = kingpin.Flag("package", "The package of the generated file, by default that of the go:generate directive").
+			Default(os.Getenv("GOPACKAGE")).String()
+	output = kingpin.Flag("output", "The generated file, by default the ruleset's file name with the suffix _restify.go").
+		Short('o').String()
+)
+
+// field is a field of the generated struct.
+type field struct {
+	// Name is the Go name of the field
+	Name string
+	// Key is the name of the rule within records
+	Key string
+	// Type is the Go type of the field
+	Type string
+	// Multiple is set for fields holding every value of the rule
+	Multiple bool
+	// Parse is the expression converting the string value to the field's element type, with
+	// the error it may yield, or empty for strings
+	Parse string
+}
+
+// generated is the data given to the template of the generated file.
+type generated struct {
+	Source  string
+	Package string
+	Type    string
+	Helper  string
+	Ruleset string
+	Fields  []field
+	Imports []string
+	// Integers is set when a field is a RuleTypeInteger, to declare the parsing helper
+	Integers bool
+}
+
+func main() {
+	kingpin.Parse()
+
+	rules, err := restify.ReadRuleset(*rulesetPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(*rulesetPath), filepath.Ext(*rulesetPath))
+	name := *typeName
+	if name == "" {
+		name = goName(base)
+	}
+	pkg := *packageName
+	if pkg == "" {
+		pkg = "main"
+	}
+	path := *output
+	if path == "" {
+		path = base + "_restify.go"
+	}
+
+	source, err := generate(rules, filepath.Base(*rulesetPath), pkg, name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, source, 0644); err != nil {
+		log.Fatal("Failed to write generated code: ", err)
+	}
+}
+
+// generate produces the formatted source of the struct named typeName and its extraction
+// function for rules.
+func generate(rules *restify.Ruleset, source string, pkg string, typeName string) ([]byte, error) {
+	data := generated{
+		Source:  source,
+		Package: pkg,
+		Type:    typeName,
+		Helper:  string(unicode.ToLower(rune(typeName[0]))) + typeName[1:],
+		Ruleset: rulesetLiteral(rules),
+	}
+
+	imports := map[string]bool{}
+	names := map[string]string{}
+	for _, rule := range rules.Rules {
+		f := field{Name: goName(rule.Name), Key: rule.Name, Multiple: rule.Multiple}
+		if previous, clash := names[f.Name]; clash {
+			return nil, fmt.Errorf("Rules %q and %q would both generate the field %s", previous, rule.Name, f.Name)
+		}
+		names[f.Name] = rule.Name
+
+		switch rule.Type {
+		case restify.RuleTypeString:
+			f.Type = "string"
+		case restify.RuleTypeNumber:
+			f.Type, f.Parse = "float64", `restify.ParseNumber(value, "")`
+		case restify.RuleTypeInteger:
+			f.Type, f.Parse = "int64", data.Helper+"ParseInteger(value)"
+			data.Integers = true
+		case restify.RuleTypePrice:
+			f.Type, f.Parse = "restify.Price", `restify.ParsePrice(value)`
+		case restify.RuleTypeDate:
+			f.Type, f.Parse = "time.Time", `restify.ParseDate(value, restify.DateHints{})`
+			imports["time"] = true
+		case restify.RuleTypeUrl:
+			f.Type, f.Parse = "*url.URL", `url.Parse(value)`
+			imports["net/url"] = true
+		default:
+			return nil, fmt.Errorf("Unknown type %q of rule %q", rule.Type, rule.Name)
+		}
+		data.Fields = append(data.Fields, f)
+	}
+	for imported := range imports {
+		data.Imports = append(data.Imports, imported)
+	}
+	sort.Strings(data.Imports)
+
+	var buffer bytes.Buffer
+	if err := fileTemplate.Execute(&buffer, data); err != nil {
+		return nil, fmt.Errorf("Failed to generate code: %w", err)
+	}
+	formatted, err := format.Source(buffer.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to format generated code: %w", err)
+	}
+	return formatted, nil
+}
+
+// rulesetLiteral renders rules as a Go composite literal.
+func rulesetLiteral(rules *restify.Ruleset) string {
+	var b strings.Builder
+	b.WriteString("&restify.Ruleset{\n")
+	if rules.Item != "" {
+		fmt.Fprintf(&b, "Item: %q,\n", rules.Item)
+	}
+	if rules.MinItems != 0 {
+		fmt.Fprintf(&b, "MinItems: %d,\n", rules.MinItems)
+	}
+	b.WriteString("Rules: []restify.Rule{\n")
+	for _, rule := range rules.Rules {
+		fmt.Fprintf(&b, "{Name: %q", rule.Name)
+		if rule.Selector != "" {
+			fmt.Fprintf(&b, ", Selector: %q", rule.Selector)
+		}
+		if rule.Attr != "" {
+			fmt.Fprintf(&b, ", Attr: %q", rule.Attr)
+		}
+		if rule.Multiple {
+			b.WriteString(", Multiple: true")
+		}
+		if rule.Type != "" {
+			fmt.Fprintf(&b, ", Type: restify.%s", ruleTypeNames[rule.Type])
+		}
+		if rule.Required {
+			b.WriteString(", Required: true")
+		}
+		if rule.MinCount != 0 {
+			fmt.Fprintf(&b, ", MinCount: %d", rule.MinCount)
+		}
+		if rule.MaxCount != 0 {
+			fmt.Fprintf(&b, ", MaxCount: %d", rule.MaxCount)
+		}
+		if rule.Pattern != "" {
+			fmt.Fprintf(&b, ", Pattern: %q", rule.Pattern)
+		}
+		b.WriteString("},\n")
+	}
+	b.WriteString("},\n}")
+	return b.String()
+}
+
+// ruleTypeNames are the names of the RuleType constants.
+var ruleTypeNames = map[restify.RuleType]string{
+	restify.RuleTypeNumber:  "RuleTypeNumber",
+	restify.RuleTypeInteger: "RuleTypeInteger",
+	restify.RuleTypePrice:   "RuleTypePrice",
+	restify.RuleTypeDate:    "RuleTypeDate",
+	restify.RuleTypeUrl:     "RuleTypeUrl",
+}
+
+// goName converts a rule or file name, such as "image_url" or "product-list", into an exported
+// Go identifier, such as "ImageUrl" or "ProductList".
+func goName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	identifier := b.String()
+	if identifier == "" || unicode.IsDigit(rune(identifier[0])) {
+		identifier = "Field" + identifier
+	}
+	return identifier
+}
+
+var fileTemplate = template.Must(template.New("file").Parse(`// Code generated by restify-gen from {{.Source}}; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .Imports}}
+	"{{.}}"
+{{- end}}
+
+	"github.com/comnoco/restify"
+	"golang.org/x/net/html"
+)
+
+// {{.Helper}}Ruleset is the ruleset {{.Type}} is extracted by, as declared by {{.Source}}.
+var {{.Helper}}Ruleset = {{.Ruleset}}
+
+// {{.Type}} is a record extracted by Extract{{.Type}}.
+type {{.Type}} struct {
+{{- range .Fields}}
+	// {{.Name}} is the {{if .Multiple}}list of values{{else}}value{{end}} of the {{printf "%q" .Key}} rule
+	{{.Name}} {{if .Multiple}}[]{{end}}{{.Type}} ` + "`" + `json:"{{.Key}}"` + "`" + `
+{{- end}}
+}
+
+// Extract{{.Type}} extracts a {{.Type}} from each item of the page at root. Fields that are
+// missing, or whose values cannot be parsed as their type, are left as their zero value. When
+// the records fail the expectations of the ruleset, they are returned along with
+// restify.ValidationErrors describing each failure, as restify.Ruleset.Extract does.
+func Extract{{.Type}}(root *html.Node) ([]{{.Type}}, error) {
+	records, err := {{.Helper}}Ruleset.Extract(root)
+	if _, invalid := err.(restify.ValidationErrors); err != nil && !invalid {
+		return nil, err
+	}
+
+	items := make([]{{.Type}}, len(records))
+	for i, record := range records {
+		item := &items[i]
+	{{- range .Fields}}
+		{{- if and .Multiple (not .Parse)}}
+		item.{{.Name}} = {{$.Helper}}Values(record[{{printf "%q" .Key}}])
+		{{- else if .Multiple}}
+		for _, value := range {{$.Helper}}Values(record[{{printf "%q" .Key}}]) {
+			if parsed, err := {{.Parse}}; err == nil {
+				item.{{.Name}} = append(item.{{.Name}}, parsed)
+			}
+		}
+		{{- else}}
+		if values := {{$.Helper}}Values(record[{{printf "%q" .Key}}]); len(values) > 0 {
+			{{- if .Parse}}
+			value := values[0]
+			if parsed, err := {{.Parse}}; err == nil {
+				item.{{.Name}} = parsed
+			}
+			{{- else}}
+			item.{{.Name}} = values[0]
+			{{- end}}
+		}
+		{{- end}}
+	{{- end}}
+	}
+	return items, err
+}
+
+// {{.Helper}}Values returns the values of a field of a restify.Record.
+func {{.Helper}}Values(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	}
+	return nil
+}
+{{- if .Integers}}
+
+// {{.Helper}}ParseInteger parses a whole number as restify.RuleTypeInteger accepts it.
+func {{.Helper}}ParseInteger(value string) (int64, error) {
+	number, err := restify.ParseNumber(value, "")
+	return int64(number), err
+}
+{{- end}}
+`))
@@ -0,0 +1,129 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Command wasm exposes the extraction functions of restify to JavaScript, such as in a browser
+// extension, so that pages can be extracted with the same rules as on a server. Pages are given
+// as HTML, since loading them is left to the browser. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o restify.wasm ./cmd/wasm
+//
+// and load it with the wasm_exec.js shipped with Go. It defines a global restify object whose
+// functions each take the HTML of a page and return their result, or an Error if they fail:
+//
+//	restify.extract(html, ruleset)   // the Records extracted by a Ruleset, given as JSON or an object
+//	restify.select(html, selector)   // the elements matching a CSS selector, in restify's JSON structure
+//	restify.query(html, query)       // the object described by a GraphQL-like query
+//	restify.text(html)               // the page as plain text
+//	restify.markdown(html)           // the page as Markdown
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"syscall/js"
+
+	"github.com/comnoco/restify"
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+)
+
+func main() {
+	api := js.Global().Get("Object").New()
+	api.Set("extract", function(2, extract))
+	api.Set("select", function(2, selectElements))
+	api.Set("query", function(2, query))
+	api.Set("text", function(1, func(root *html.Node, _ []js.Value) (interface{}, error) {
+		return restify.ToText(root, restify.TextOptions{}), nil
+	}))
+	api.Set("markdown", function(1, func(root *html.Node, _ []js.Value) (interface{}, error) {
+		return restify.ToMarkdown(root, restify.MarkdownOptions{}), nil
+	}))
+	js.Global().Set("restify", api)
+
+	// the functions are only callable while the program runs
+	select {}
+}
+
+// function adapts an implementation taking the parsed page, given as the first argument, to a
+// JavaScript function of arity arguments. Results that are not strings are converted to
+// JavaScript values by way of JSON, and errors are returned as Error objects.
+func function(arity int, implementation func(root *html.Node, args []js.Value) (interface{}, error)) js.Func {
+	return js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		if len(args) < arity {
+			return jsError(errors.New("Too few arguments"))
+		}
+		if args[0].Type() != js.TypeString {
+			return jsError(errors.New("Expected the HTML of a page as the first argument"))
+		}
+		root, err := restify.LoadBuffer([]byte(args[0].String()))
+		if err != nil {
+			return jsError(err)
+		}
+
+		result, err := implementation(root, args[1:])
+		if err != nil {
+			return jsError(err)
+		}
+		if text, ok := result.(string); ok {
+			return text
+		}
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return jsError(err)
+		}
+		return js.Global().Get("JSON").Call("parse", string(encoded))
+	})
+}
+
+func extract(root *html.Node, args []js.Value) (interface{}, error) {
+	rulesJson := args[0].String()
+	if args[0].Type() == js.TypeObject {
+		rulesJson = js.Global().Get("JSON").Call("stringify", args[0]).String()
+	}
+	var rules restify.Ruleset
+	if err := json.Unmarshal([]byte(rulesJson), &rules); err != nil {
+		return nil, err
+	}
+
+	records, err := rules.Extract(root)
+	if _, invalid := err.(restify.ValidationErrors); err != nil && !invalid {
+		return nil, err
+	}
+	var failures []string
+	if invalid, ok := err.(restify.ValidationErrors); ok {
+		for _, failure := range invalid {
+			failures = append(failures, failure.Error())
+		}
+	}
+	if records == nil {
+		records = []restify.Record{}
+	}
+	return struct {
+		Records []restify.Record `json:"records"`
+		Errors  []string         `json:"errors,omitempty"`
+	}{records, failures}, nil
+}
+
+func selectElements(root *html.Node, args []js.Value) (interface{}, error) {
+	selector, err := restify.ParseSelector(args[0].String())
+	if err != nil {
+		return nil, err
+	}
+	converted, err := restify.ConvertHtmlToJson(scrape.FindAll(root, selector.Matcher()))
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(converted), nil
+}
+
+func query(root *html.Node, args []js.Value) (interface{}, error) {
+	resolved, err := restify.ResolveGraphQuery(root, args[0].String())
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(resolved), nil
+}
+
+func jsError(err error) js.Value {
+	return js.Global().Get("Error").New(err.Error())
+}
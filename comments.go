@@ -0,0 +1,50 @@
+package restify
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+)
+
+// FindComments retrieves the comment nodes within root in document order, such as the
+// <!-- section:start --> markers that many content management systems embed. The HTML parser
+// represents processing instructions as comments too, so they are included; see
+// FindProcessingInstructions.
+func FindComments(root *html.Node) []*html.Node {
+	return scrape.FindAllNested(root, func(n *html.Node) bool {
+		return n.Type == html.CommentNode
+	})
+}
+
+// FindCommentMatching retrieves the comment nodes within root whose text matches pattern.
+func FindCommentMatching(root *html.Node, pattern *regexp.Regexp) []*html.Node {
+	return scrape.FindAllNested(root, func(n *html.Node) bool {
+		return n.Type == html.CommentNode && pattern.MatchString(n.Data)
+	})
+}
+
+// FindProcessingInstructions retrieves the processing instructions within root, such as
+// <?php echo 1; ?> or <?xml-stylesheet href="feed.xsl"?>, which are comment nodes whose text
+// begins with a question mark. Use ProcessingInstruction to read them.
+func FindProcessingInstructions(root *html.Node) []*html.Node {
+	return scrape.FindAllNested(root, func(n *html.Node) bool {
+		return n.Type == html.CommentNode && strings.HasPrefix(n.Data, "?")
+	})
+}
+
+// ProcessingInstruction reads the target, such as "xml-stylesheet", and the instruction that
+// follows it from a node found by FindProcessingInstructions. If node is not a processing
+// instruction, then ok will be false.
+func ProcessingInstruction(node *html.Node) (target string, instruction string, ok bool) {
+	if node.Type != html.CommentNode || !strings.HasPrefix(node.Data, "?") {
+		return "", "", false
+	}
+	content := strings.TrimSuffix(strings.TrimPrefix(node.Data, "?"), "?")
+	target = content
+	if i := strings.IndexAny(content, " \t\r\n"); i >= 0 {
+		target, instruction = content[:i], strings.TrimSpace(content[i:])
+	}
+	return target, instruction, target != ""
+}
@@ -0,0 +1,162 @@
+package restify
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Decompressor opens a reader of the decompressed content of reader.
+type Decompressor func(reader io.Reader) (io.ReadCloser, error)
+
+// compressionFormat is a format of compressed files recognized by LoadPath and LoadBuffer.
+type compressionFormat struct {
+	name       string
+	extensions []string
+	magic      string
+	open       Decompressor
+}
+
+var (
+	compressionMu      sync.RWMutex
+	compressionFormats = []*compressionFormat{
+		{name: "gzip", extensions: []string{".gz", ".gzip"}, magic: "\x1f\x8b", open: openGzip},
+		{name: "bzip2", extensions: []string{".bz2"}, magic: "BZh", open: openBzip2},
+	}
+)
+
+// maxMagicLength is the length of the longest magic number that compressed content is
+// recognized by.
+const maxMagicLength = 16
+
+// RegisterDecompressor sets how files compressed in the named format are decompressed by
+// LoadPath, LoadFile, LoadFiles, LoadFileMmap, and LoadBuffer. The formats "gzip" and "bzip2"
+// are built in. Others, such as zstd, which the standard library has no decoder for, can be
+// registered, for instance with github.com/klauspost/compress/zstd:
+//
+//	restify.RegisterDecompressor("zstd", ".zst", "\x28\xb5\x2f\xfd", func(r io.Reader) (io.ReadCloser, error) {
+//		decoder, err := zstd.NewReader(r)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return decoder.IOReadCloser(), nil
+//	})
+//
+// Content is recognized by its magic number, the bytes every file of the format begins with,
+// or failing that by the extension of the file's path.
+func RegisterDecompressor(name string, extension string, magic string, open Decompressor) {
+	compressionMu.Lock()
+	defer compressionMu.Unlock()
+	for _, format := range compressionFormats {
+		if format.name == name {
+			format.open = open
+			format.extensions = appendMissing(format.extensions, strings.ToLower(extension))
+			if magic != "" {
+				format.magic = magic
+			}
+			return
+		}
+	}
+	compressionFormats = append(compressionFormats, &compressionFormat{
+		name:       name,
+		extensions: []string{strings.ToLower(extension)},
+		magic:      magic,
+		open:       open,
+	})
+}
+
+// appendMissing appends value to values unless it is empty or already present.
+func appendMissing(values []string, value string) []string {
+	if value == "" {
+		return values
+	}
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// decompress wraps reader so that content in a compression format, recognized by its start or
+// else by the extension of path, is decompressed. Other content is read as it is. The returned
+// close function must be called once the content has been read.
+func decompress(reader io.Reader, path string) (io.Reader, func() error, error) {
+	buffered := bufio.NewReader(reader)
+	// a short read just means content too short to be compressed
+	start, _ := buffered.Peek(maxMagicLength)
+
+	name, open, ok := findCompression(start, path)
+	if !ok {
+		return buffered, func() error { return nil }, nil
+	}
+	if open == nil {
+		return nil, nil, fmt.Errorf("Unable to decompress %s content without a registered decompressor", name)
+	}
+	decompressed, err := open(buffered)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to decompress %s content: %w", name, err)
+	}
+	return decompressed, decompressed.Close, nil
+}
+
+// findCompression determines the compression format of content with the given start, or else of
+// the file at path, returning its name and decompressor. If the content is not compressed, then
+// ok will be false.
+func findCompression(start []byte, path string) (name string, open Decompressor, ok bool) {
+	extension := strings.ToLower(filepath.Ext(path))
+	compressionMu.RLock()
+	defer compressionMu.RUnlock()
+
+	var format *compressionFormat
+	for _, candidate := range compressionFormats {
+		if candidate.magic != "" && bytes.HasPrefix(start, []byte(candidate.magic)) {
+			format = candidate
+			break
+		}
+	}
+	for _, candidate := range compressionFormats {
+		if format != nil || extension == "" {
+			break
+		}
+		for _, candidateExtension := range candidate.extensions {
+			if candidateExtension == extension {
+				format = candidate
+			}
+		}
+	}
+	if format == nil {
+		return "", nil, false
+	}
+	return format.name, format.open, true
+}
+
+func openGzip(reader io.Reader) (io.ReadCloser, error) {
+	decompressed, err := getGzipReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	return pooledGzipReader{decompressed}, nil
+}
+
+// pooledGzipReader returns its reader to the pool when closed.
+type pooledGzipReader struct {
+	*gzip.Reader
+}
+
+func (r pooledGzipReader) Close() error {
+	err := r.Reader.Close()
+	putGzipReader(r.Reader)
+	return err
+}
+
+func openBzip2(reader io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bzip2.NewReader(reader)), nil
+}
@@ -0,0 +1,423 @@
+package restify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// LoaderConfig declares the configuration of a Loader, as read by NewLoaderFromConfig from
+// JSON or YAML, so that scraping can be tuned without code changes. Every field is optional.
+type LoaderConfig struct {
+	// UserAgent is sent with each request, as with WithUserAgent
+	UserAgent string `json:"userAgent,omitempty"`
+	// UserAgents are rotated through, as with WithUserAgentRotation
+	UserAgents []string `json:"userAgents,omitempty"`
+	// BrowserProfile is "chrome" or "firefox", to send the headers of ProfileChrome or
+	// ProfileFirefox
+	BrowserProfile string `json:"browserProfile,omitempty"`
+	// Headers are sent with each request
+	Headers map[string]string `json:"headers,omitempty"`
+	// Timeout is the time allowed for each load, as with WithTimeout
+	Timeout *ConfigDuration `json:"timeout,omitempty"`
+	// Protocol is "HTTP/1.1", "HTTP/2", or "h2c", as with WithProtocol
+	Protocol Protocol `json:"protocol,omitempty"`
+	// Proxy is the URL of a proxy to send requests through, as with WithProxy
+	Proxy string `json:"proxy,omitempty"`
+	// RateLimits limit the load placed on hosts, as with WithHostPolicy, in order of precedence
+	RateLimits []RateLimitConfig `json:"rateLimits,omitempty"`
+	// Retry retries failed requests, as with WithRetries
+	Retry *RetryConfig `json:"retry,omitempty"`
+	// Dns configures how host names are resolved and cached
+	Dns *DnsConfig `json:"dns,omitempty"`
+	// Cache keeps the pages loaded in memory, as with WithDocumentStore
+	Cache *CacheConfig `json:"cache,omitempty"`
+	// Auth authenticates the requests to a host
+	Auth *AuthConfig `json:"auth,omitempty"`
+	// Cookies is the path of a file of cookies exported from a browser, as read by
+	// ReadCookieFile, relative to the configuration file
+	Cookies string `json:"cookies,omitempty"`
+	// Tls configures the verification of servers and the certificate presented to them
+	Tls *TlsConfig `json:"tls,omitempty"`
+	// AllowedHosts only allows requests to matching hosts, as with WithAllowedHosts
+	AllowedHosts []string `json:"allowedHosts,omitempty"`
+	// DeniedHosts refuses requests to matching hosts, as with WithDeniedHosts
+	DeniedHosts []string `json:"deniedHosts,omitempty"`
+	// DenyPrivateNetworks refuses connections to non-public addresses, as with
+	// DenyPrivateNetworks
+	DenyPrivateNetworks bool `json:"denyPrivateNetworks,omitempty"`
+	// HtmlRedirects is the number of redirects within pages to follow, as with
+	// WithHtmlRedirects
+	HtmlRedirects int `json:"htmlRedirects,omitempty"`
+	// PreferredLanguages are the language variants of pages to load instead, as with
+	// WithPreferredLanguages
+	PreferredLanguages []string `json:"preferredLanguages,omitempty"`
+	// ChallengeDetection fails loads of anti-bot challenge pages, as with
+	// WithChallengeDetection
+	ChallengeDetection bool `json:"challengeDetection,omitempty"`
+}
+
+// RateLimitConfig declares a HostPolicy.
+type RateLimitConfig struct {
+	// Host is the pattern of the hosts limited, as described by WithHostPolicy
+	Host string `json:"host"`
+	// MaxConns is the number of requests to each host that may be in progress at once
+	MaxConns int `json:"maxConns,omitempty"`
+	// Delay is the minimum time between starting requests to each host
+	Delay ConfigDuration `json:"delay,omitempty"`
+}
+
+// RetryConfig declares a RetryPolicy.
+type RetryConfig struct {
+	// MaxRetries is the number of times a request is retried after its first attempt
+	MaxRetries int `json:"maxRetries"`
+	// Backoff is the delay before the first retry, doubling for each that follows
+	Backoff ConfigDuration `json:"backoff,omitempty"`
+	// MaxBackoff is the longest delay between retries
+	MaxBackoff ConfigDuration `json:"maxBackoff,omitempty"`
+	// StatusCodes are the response statuses that are retried
+	StatusCodes []int `json:"statusCodes,omitempty"`
+}
+
+// DnsConfig declares how host names are resolved.
+type DnsConfig struct {
+	// Servers are DNS servers to query, as with NewDnsResolver
+	Servers []string `json:"servers,omitempty"`
+	// DohUrl is the endpoint of a DNS over HTTPS service to query instead, as with
+	// NewDohResolver
+	DohUrl string `json:"dohUrl,omitempty"`
+	// Cache caches resolved addresses, as with WithDnsCache
+	Cache bool `json:"cache,omitempty"`
+}
+
+// CacheConfig declares the DocumentStore of a Loader.
+type CacheConfig struct {
+	// MaxEntries is the number of pages kept, unlimited if zero
+	MaxEntries int `json:"maxEntries,omitempty"`
+	// MaxBytes is the estimated memory the pages kept may hold, unlimited if zero
+	MaxBytes int64 `json:"maxBytes,omitempty"`
+	// TTL is how long pages are used without requesting them again
+	TTL ConfigDuration `json:"ttl,omitempty"`
+}
+
+// AuthConfig declares the authentication sent with each request to a host.
+type AuthConfig struct {
+	// Host is the pattern of the hosts the credentials are sent to, as described by
+	// WithHostPolicy
+	Host string `json:"host"`
+	// Username and Password are sent by HTTP basic authentication
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// BearerToken is sent as a bearer token instead
+	BearerToken string `json:"bearerToken,omitempty"`
+}
+
+// TlsConfig declares the TLS configuration of connections. Relative paths are relative to the
+// configuration file.
+type TlsConfig struct {
+	// RootCAs is the path of a PEM file of the certificate authorities servers are verified
+	// against, in place of the system's
+	RootCAs string `json:"rootCAs,omitempty"`
+	// ClientCert and ClientKey are the paths of the PEM files of a client certificate and its
+	// key, for mutual TLS
+	ClientCert string `json:"clientCert,omitempty"`
+	ClientKey  string `json:"clientKey,omitempty"`
+	// InsecureSkipVerify disables verification of server certificates
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// ConfigDuration is a time.Duration read from a string such as "1m30s", or from a number of
+// seconds.
+type ConfigDuration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *ConfigDuration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		*d = ConfigDuration(duration)
+		return nil
+	}
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err != nil {
+		return fmt.Errorf("Unable to parse duration %s", data)
+	}
+	*d = ConfigDuration(seconds * float64(time.Second))
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d ConfigDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// configVariable matches the ${NAME} references to environment variables within configuration
+// files.
+var configVariable = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// NewLoaderFromConfig creates a Loader configured by the JSON or YAML file at path, as read by
+// ReadLoaderConfig, followed by the given options, which take precedence.
+func NewLoaderFromConfig(path string, options ...LoaderOption) (*Loader, error) {
+	config, err := ReadLoaderConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	configured, err := config.Options(filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to configure loader from %s: %w", path, err)
+	}
+	return NewLoader(append(configured, options...)...), nil
+}
+
+// ReadLoaderConfig reads a LoaderConfig from the file at path, which is YAML if its extension is
+// .yaml or .yml, or its content does not begin with "{", and JSON otherwise. Only the block
+// mappings, sequences, and scalars of YAML, and single-line flow sequences and mappings, are
+// supported, and plain scalars take the type of their field, so that "no" is a string where one
+// is expected, while booleans are only true and false. References to environment variables of
+// the form ${NAME} within strings are replaced by their values, such as to keep passwords out of
+// the file, once the file is parsed, so the values are used as they are. Unknown fields are
+// reported as errors, to catch misspellings.
+func ReadLoaderConfig(path string) (*LoaderConfig, error) {
+	var config LoaderConfig
+	if err := readConfigFile(path, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// readConfigFile decodes the JSON or YAML file at path into target, as described by
+// ReadLoaderConfig.
+func readConfigFile(path string, target interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Failed to read %s: %w", path, err)
+	}
+
+	var document interface{}
+	extension := strings.ToLower(filepath.Ext(path))
+	isJson := extension == ".json" ||
+		(extension != ".yaml" && extension != ".yml" && bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")))
+	if isJson {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&document)
+	} else if document, err = parseYaml(data); err == nil {
+		document = typeYaml(document, reflect.TypeOf(target))
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to parse %s: %w", path, err)
+	}
+
+	// variables are expanded once parsed so that their values are never parsed themselves
+	data, err = json.Marshal(expandConfigVariables(document))
+	if err != nil {
+		return fmt.Errorf("Failed to parse %s: %w", path, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return fmt.Errorf("Failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// expandConfigVariables replaces the references to environment variables within the strings of
+// a parsed configuration file.
+func expandConfigVariables(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return configVariable.ReplaceAllStringFunc(v, func(reference string) string {
+			return os.Getenv(reference[2 : len(reference)-1])
+		})
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = expandConfigVariables(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = expandConfigVariables(item)
+		}
+	}
+	return value
+}
+
+// Options converts the configuration into the equivalent LoaderOptions, reading the cookie and
+// TLS files it refers to relative to dir.
+func (c *LoaderConfig) Options(dir string) ([]LoaderOption, error) {
+	var options []LoaderOption
+	if c.UserAgent != "" {
+		options = append(options, WithUserAgent(c.UserAgent))
+	}
+	if len(c.UserAgents) > 0 {
+		options = append(options, WithUserAgentRotation(c.UserAgents))
+	}
+	switch strings.ToLower(c.BrowserProfile) {
+	case "":
+	case "chrome":
+		options = append(options, WithBrowserProfile(ProfileChrome))
+	case "firefox":
+		options = append(options, WithBrowserProfile(ProfileFirefox))
+	default:
+		return nil, fmt.Errorf("Unknown browser profile %q", c.BrowserProfile)
+	}
+	if len(c.Headers) > 0 {
+		options = append(options, WithRequestConfigs(WithHeaders(c.Headers)))
+	}
+	if c.Timeout != nil {
+		options = append(options, WithTimeout(time.Duration(*c.Timeout)))
+	}
+	switch c.Protocol {
+	case ProtocolAuto, ProtocolHttp1, ProtocolHttp2, ProtocolH2c:
+		if c.Protocol != ProtocolAuto {
+			options = append(options, WithProtocol(c.Protocol))
+		}
+	default:
+		return nil, fmt.Errorf("Unknown protocol %q", c.Protocol)
+	}
+	if c.Proxy != "" {
+		proxyUrl, err := url.Parse(c.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse proxy: %w", err)
+		}
+		options = append(options, WithProxy(proxyUrl))
+	}
+	for _, limit := range c.RateLimits {
+		if limit.Host == "" {
+			return nil, errors.New("Unable to limit the rate of requests without a host pattern")
+		}
+		options = append(options, WithHostPolicy(limit.Host, HostPolicy{
+			MaxConns: limit.MaxConns,
+			Delay:    time.Duration(limit.Delay),
+		}))
+	}
+	if c.Retry != nil {
+		options = append(options, WithRetries(RetryPolicy{
+			MaxRetries:  c.Retry.MaxRetries,
+			Backoff:     time.Duration(c.Retry.Backoff),
+			MaxBackoff:  time.Duration(c.Retry.MaxBackoff),
+			StatusCodes: c.Retry.StatusCodes,
+		}))
+	}
+
+	if c.Dns != nil {
+		switch {
+		case c.Dns.DohUrl != "" && len(c.Dns.Servers) > 0:
+			return nil, errors.New("Unable to use both DNS servers and DNS over HTTPS")
+		case c.Dns.DohUrl != "":
+			options = append(options, WithResolver(NewDohResolver(c.Dns.DohUrl)))
+		case len(c.Dns.Servers) > 0:
+			options = append(options, WithResolver(NewDnsResolver(c.Dns.Servers...)))
+		}
+		if c.Dns.Cache {
+			options = append(options, WithDnsCache())
+		}
+	}
+
+	if c.Cache != nil {
+		options = append(options, WithDocumentStore(DocumentStoreOptions{
+			MaxEntries: c.Cache.MaxEntries,
+			MaxBytes:   c.Cache.MaxBytes,
+			TTL:        time.Duration(c.Cache.TTL),
+		}))
+	}
+
+	if c.Auth != nil {
+		switch {
+		case c.Auth.Host == "":
+			return nil, errors.New("Unable to authenticate without a host pattern")
+		case c.Auth.BearerToken != "" && c.Auth.Username != "":
+			return nil, errors.New("Unable to use both basic authentication and a bearer token")
+		case c.Auth.BearerToken != "":
+			options = append(options, WithRequestConfigs(WithBearerToken(c.Auth.Host, c.Auth.BearerToken)))
+		case c.Auth.Username != "":
+			options = append(options, WithRequestConfigs(WithBasicAuth(c.Auth.Host, c.Auth.Username, c.Auth.Password)))
+		}
+	}
+
+	if c.Cookies != "" {
+		path := c.Cookies
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		cookies, err := ReadCookieFile(path)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, WithCookies(cookies))
+	}
+
+	if c.Tls != nil {
+		tlsOptions, err := c.Tls.options(dir)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, tlsOptions...)
+	}
+
+	if len(c.AllowedHosts) > 0 {
+		options = append(options, WithAllowedHosts(c.AllowedHosts...))
+	}
+	if len(c.DeniedHosts) > 0 {
+		options = append(options, WithDeniedHosts(c.DeniedHosts...))
+	}
+	if c.DenyPrivateNetworks {
+		options = append(options, DenyPrivateNetworks())
+	}
+	if c.HtmlRedirects > 0 {
+		options = append(options, WithHtmlRedirects(c.HtmlRedirects))
+	}
+	if len(c.PreferredLanguages) > 0 {
+		options = append(options, WithPreferredLanguages(c.PreferredLanguages...))
+	}
+	if c.ChallengeDetection {
+		options = append(options, WithChallengeDetection())
+	}
+	return options, nil
+}
+
+func (c *TlsConfig) options(dir string) ([]LoaderOption, error) {
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+
+	var options []LoaderOption
+	if c.RootCAs != "" {
+		pem, err := ioutil.ReadFile(resolve(c.RootCAs))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read root CAs: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Unable to find certificates in %s", c.RootCAs)
+		}
+		options = append(options, WithRootCAs(pool))
+	}
+	if c.ClientCert != "" || c.ClientKey != "" {
+		certificate, err := tls.LoadX509KeyPair(resolve(c.ClientCert), resolve(c.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load client certificate: %w", err)
+		}
+		options = append(options, WithClientCert(certificate))
+	}
+	if c.InsecureSkipVerify {
+		options = append(options, WithInsecureSkipVerify())
+	}
+	return options, nil
+}
@@ -0,0 +1,425 @@
+package restify
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a significant line of a YAML document.
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// yamlScalar is a plain, unquoted scalar of YAML, whose type depends on the field it is decoded
+// into: "no" is a string for string fields, and "8080" is a number for numeric fields.
+type yamlScalar string
+
+// yamlInteger and yamlNumber match the plain scalars of YAML that are numbers.
+var (
+	yamlInteger = regexp.MustCompile(`^[-+]?\d+$`)
+	yamlNumber  = regexp.MustCompile(`^[-+]?(?:\d+(?:\.\d*)?|\.\d+)(?:[eE][-+]?\d+)?$`)
+)
+
+// parseYaml parses the subset of YAML used by configuration files: block mappings and
+// sequences, plain and quoted scalars, and flow sequences and mappings on a single line. Mappings
+// are map[string]interface{}, sequences []interface{}, quoted scalars strings, and plain scalars
+// yamlScalar, to be typed by typeYaml.
+func parseYaml(data []byte) (interface{}, error) {
+	var lines []yamlLine
+	for i, text := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		text = strings.TrimRight(stripYamlComment(text), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d is indented with a tab", i+1)
+		}
+		lines = append(lines, yamlLine{number: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	p := &yamlParser{lines: lines}
+	value, err := p.parseBlock(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.next < len(p.lines) {
+		return nil, fmt.Errorf("line %d is not indented consistently", p.lines[p.next].number)
+	}
+	return value, nil
+}
+
+// stripYamlComment removes a comment from a line, ignoring # within quoted scalars.
+func stripYamlComment(text string) string {
+	var quote rune
+	for i, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case (r == '"' || r == '\'') && (i == 0 || strings.ContainsRune(" \t:[{,", rune(text[i-1]))):
+			// apostrophes within plain scalars do not begin quotes
+			quote = r
+		case r == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return text[:i]
+		}
+	}
+	return text
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	next  int
+}
+
+// parseBlock parses the mapping or sequence whose entries are indented by indent.
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	if isYamlSequenceItem(p.lines[p.next].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	items := []interface{}{}
+	for p.next < len(p.lines) {
+		line := p.lines[p.next]
+		// sequences nested at the indent of their key end at the next key
+		if line.indent < indent || (line.indent == indent && !isYamlSequenceItem(line.text)) {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d is not indented consistently", line.number)
+		}
+
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if rest == "" {
+			p.next++
+			item, err := p.parseNested(line)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		// the content of the item is parsed as though it began its own line, so that a mapping
+		// starting on the line of its dash continues on the lines that follow
+		p.lines[p.next] = yamlLine{number: line.number, indent: line.indent + len(line.text) - len(rest), text: rest}
+		if _, _, isMapping := splitYamlKey(rest); isMapping || isYamlSequenceItem(rest) {
+			item, err := p.parseBlock(p.lines[p.next].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		p.next++
+		item, err := parseYamlScalar(rest, line.number)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	entries := map[string]interface{}{}
+	for p.next < len(p.lines) {
+		line := p.lines[p.next]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d is not indented consistently", line.number)
+		}
+		key, rest, ok := splitYamlKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d is not a key and value", line.number)
+		}
+		if _, duplicate := entries[key]; duplicate {
+			return nil, fmt.Errorf("line %d repeats the key %q", line.number, key)
+		}
+		p.next++
+
+		if rest == "" {
+			value, err := p.parseNested(line)
+			if err != nil {
+				return nil, err
+			}
+			entries[key] = value
+			continue
+		}
+		value, err := parseYamlScalar(rest, line.number)
+		if err != nil {
+			return nil, err
+		}
+		entries[key] = value
+	}
+	return entries, nil
+}
+
+// parseNested parses the block following line, which has no value of its own, or returns nil if
+// there is none. Sequences may be nested at the same indent as the key they belong to.
+func (p *yamlParser) parseNested(line yamlLine) (interface{}, error) {
+	if p.next >= len(p.lines) {
+		return nil, nil
+	}
+	next := p.lines[p.next]
+	if next.indent > line.indent || (next.indent == line.indent && isYamlSequenceItem(next.text) &&
+		!isYamlSequenceItem(line.text)) {
+		return p.parseBlock(next.indent)
+	}
+	return nil, nil
+}
+
+func isYamlSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYamlKey splits a line of a mapping into its key and value. If the line is not a key and
+// value, then ok will be false.
+func splitYamlKey(text string) (key string, value string, ok bool) {
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		end := strings.IndexByte(text[1:], text[0])
+		if end < 0 {
+			return "", "", false
+		}
+		rest := text[end+2:]
+		if rest != ":" && !strings.HasPrefix(rest, ": ") {
+			return "", "", false
+		}
+		return text[1 : end+1], strings.TrimSpace(rest[1:]), true
+	}
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		return "", "", false
+	}
+	colon := strings.Index(text, ": ")
+	if colon < 0 && strings.HasSuffix(text, ":") {
+		colon = len(text) - 1
+	}
+	if colon <= 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(text[:colon]), strings.TrimSpace(text[colon+1:]), true
+}
+
+// parseYamlScalar parses a scalar, or a flow sequence or mapping of scalars.
+func parseYamlScalar(text string, number int) (interface{}, error) {
+	switch {
+	case text == "|" || text == ">" || strings.HasPrefix(text, "|") || strings.HasPrefix(text, ">"):
+		return nil, fmt.Errorf("line %d uses a block scalar, which is not supported", number)
+	case strings.HasPrefix(text, "&") || strings.HasPrefix(text, "*") || strings.HasPrefix(text, "!"):
+		return nil, fmt.Errorf("line %d uses an anchor, alias, or tag, which are not supported", number)
+
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("line %d has an unterminated flow sequence", number)
+		}
+		items := []interface{}{}
+		for _, part := range splitYamlFlow(text[1 : len(text)-1]) {
+			item, err := parseYamlScalar(part, number)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+
+	case strings.HasPrefix(text, "{"):
+		if !strings.HasSuffix(text, "}") {
+			return nil, fmt.Errorf("line %d has an unterminated flow mapping", number)
+		}
+		entries := map[string]interface{}{}
+		for _, part := range splitYamlFlow(text[1 : len(text)-1]) {
+			key, rest, ok := splitYamlKey(part)
+			if !ok {
+				return nil, fmt.Errorf("line %d has a flow mapping entry without a key", number)
+			}
+			value, err := parseYamlScalar(rest, number)
+			if err != nil {
+				return nil, err
+			}
+			entries[key] = value
+		}
+		return entries, nil
+
+	case strings.HasPrefix(text, `"`):
+		value, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d has an invalid quoted string", number)
+		}
+		return value, nil
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("line %d has an invalid quoted string", number)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	}
+
+	switch strings.ToLower(text) {
+	case "", "~", "null":
+		return nil, nil
+	}
+	return yamlScalar(text), nil
+}
+
+// jsonUnmarshaler is the type of json.Unmarshaler.
+var jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// typeYaml converts the plain scalars within a value parsed by parseYaml to the types of the
+// fields of target they are decoded into, the type of a pointer to the value decoded, so that
+// the value can be decoded from JSON: strings for string fields, numbers for numeric fields,
+// and booleans for bool fields given true or false. The scalars of other fields, such as those
+// of interface{} fields or of types decoding themselves, are typed by their content.
+func typeYaml(value interface{}, target reflect.Type) interface{} {
+	for target != nil && target.Kind() == reflect.Ptr {
+		target = target.Elem()
+	}
+	if target != nil && reflect.PtrTo(target).Implements(jsonUnmarshaler) {
+		target = nil
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		typed := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			var itemType reflect.Type
+			if target != nil && target.Kind() == reflect.Map {
+				itemType = target.Elem()
+			} else if target != nil && target.Kind() == reflect.Struct {
+				itemType = jsonFieldType(target, key)
+			}
+			typed[key] = typeYaml(item, itemType)
+		}
+		return typed
+	case []interface{}:
+		typed := make([]interface{}, len(v))
+		for i, item := range v {
+			var itemType reflect.Type
+			if target != nil && (target.Kind() == reflect.Slice || target.Kind() == reflect.Array) {
+				itemType = target.Elem()
+			}
+			typed[i] = typeYaml(item, itemType)
+		}
+		return typed
+	case yamlScalar:
+		return typeYamlScalar(string(v), target)
+	}
+	return value
+}
+
+// typeYamlScalar converts a plain scalar to the type decoded into target, or when target is nil,
+// to the type of its content.
+func typeYamlScalar(text string, target reflect.Type) interface{} {
+	kind := reflect.Interface
+	if target != nil {
+		kind = target.Kind()
+	}
+	switch kind {
+	case reflect.String:
+		return text
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if yamlNumber.MatchString(text) {
+			if yamlInteger.MatchString(text) {
+				return json.Number(strings.TrimPrefix(text, "+"))
+			}
+			if value, err := strconv.ParseFloat(text, 64); err == nil {
+				return value
+			}
+		}
+		return text
+	}
+
+	switch strings.ToLower(text) {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if kind == reflect.Bool {
+		return text
+	}
+	if yamlInteger.MatchString(text) {
+		return json.Number(strings.TrimPrefix(text, "+"))
+	}
+	if yamlNumber.MatchString(text) {
+		if value, err := strconv.ParseFloat(text, 64); err == nil {
+			return value
+		}
+	}
+	return text
+}
+
+// jsonFieldType returns the type of the field of the struct type t that the JSON key decodes
+// into, matching names as encoding/json does, or nil if there is none.
+func jsonFieldType(t reflect.Type, key string) reflect.Type {
+	var folded reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		name := field.Name
+		if tag := field.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if comma := strings.IndexByte(tag, ','); comma >= 0 {
+				tag = tag[:comma]
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		if name == key {
+			return field.Type
+		}
+		if folded == nil && strings.EqualFold(name, key) {
+			folded = field.Type
+		}
+	}
+	return folded
+}
+
+// splitYamlFlow splits the content of a flow collection at commas outside of quotes and nested
+// collections.
+func splitYamlFlow(text string) []string {
+	var parts []string
+	var quote rune
+	depth := 0
+	start := 0
+	for i, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '[' || r == '{':
+			depth++
+		case r == ']' || r == '}':
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(text[start:]); last != "" || len(parts) > 0 {
+		parts = append(parts, last)
+	}
+	return parts
+}
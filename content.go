@@ -0,0 +1,267 @@
+package restify
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// ContentKind identifies how the content of a Response was interpreted.
+type ContentKind string
+
+const (
+	// ContentHtml is HTML, and any content type not otherwise recognized, as well as XHTML, XML,
+	// and JSON that could not be decoded, which is parsed as HTML instead
+	ContentHtml ContentKind = "html"
+	// ContentXhtml is XHTML, parsed as XML
+	ContentXhtml ContentKind = "xhtml"
+	// ContentXml is an XML document, such as an RSS feed or sitemap
+	ContentXml ContentKind = "xml"
+	// ContentJson is a JSON document, decoded into Response.Json
+	ContentJson ContentKind = "json"
+	// ContentText is plain text, presented in Response.Text
+	ContentText ContentKind = "text"
+	// ContentPdf is a PDF document, whose text is presented in Response.Text when the Loader was
+	// configured WithPdfExtractor
+	ContentPdf ContentKind = "pdf"
+)
+
+// PdfExtractor extracts the text of the PDF document read from reader, such as
+// pdftext.Extract.
+type PdfExtractor func(reader io.Reader) (string, error)
+
+// WithPdfExtractor configures the Loader to extract the text of PDF responses with extractor.
+// Without an extractor, PDF responses are loaded as empty documents rather than failing, so
+// that crawls encountering them can carry on.
+func WithPdfExtractor(extractor PdfExtractor) LoaderOption {
+	return func(l *Loader) {
+		l.pdfExtractor = extractor
+	}
+}
+
+const xhtmlNamespace = "http://www.w3.org/1999/xhtml"
+
+// contentKind determines the kind of content from its media type, such as application/rss+xml.
+func contentKind(mediaType string) ContentKind {
+	switch {
+	case mediaType == "application/xhtml+xml":
+		return ContentXhtml
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return ContentXml
+	case mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json"):
+		return ContentJson
+	case mediaType == "text/plain":
+		return ContentText
+	case mediaType == "application/pdf":
+		return ContentPdf
+	default:
+		return ContentHtml
+	}
+}
+
+// parseContent interprets body according to contentType, setting the Root, Charset,
+// ContentType, Kind, Json, and Text of resp. XML and JSON that cannot be decoded are parsed as
+// HTML instead, with a Kind of ContentHtml.
+func (l *Loader) parseContent(resp *Response, body io.Reader, contentType string) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = ""
+	}
+	resp.ContentType = mediaType
+	resp.Kind = contentKind(mediaType)
+
+	switch resp.Kind {
+	case ContentXhtml, ContentXml:
+		content, err := ioutil.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("Failed to read response body: %w", err)
+		}
+		resp.Charset = "utf-8"
+		if label := params["charset"]; label != "" {
+			resp.Charset = strings.ToLower(label)
+		}
+		resp.Root, err = ParseXml(bytes.NewReader(content), params["charset"])
+		if err != nil {
+			// browsers render malformed XML as an error, but the HTML parser salvages it, as it
+			// does error pages served with the wrong type
+			resp.Kind = ContentHtml
+			return parseHtmlContent(resp, bytes.NewReader(content), contentType)
+		}
+		return nil
+
+	case ContentJson:
+		// JSON is always UTF-8, as required by RFC 8259
+		content, err := ioutil.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("Failed to read response body: %w", err)
+		}
+		if err := json.Unmarshal(content, &resp.Json); err != nil {
+			// such as an HTML error page served with the type of the API
+			resp.Kind, resp.Json = ContentHtml, nil
+			return parseHtmlContent(resp, bytes.NewReader(content), contentType)
+		}
+		resp.Charset = "utf-8"
+		resp.Root = textDocument(string(content))
+		return nil
+
+	case ContentText:
+		decoded, charsetName, release := decodeCharset(body, contentType)
+		defer release()
+		content, err := ioutil.ReadAll(decoded)
+		if err != nil {
+			return fmt.Errorf("Failed to read response body: %w", err)
+		}
+		resp.Charset = charsetName
+		resp.Text = string(content)
+		resp.Root = textDocument(resp.Text)
+		return nil
+
+	case ContentPdf:
+		if l.pdfExtractor != nil {
+			text, err := l.pdfExtractor(body)
+			if err != nil {
+				return fmt.Errorf("Failed to extract PDF text: %w", err)
+			}
+			resp.Text = text
+		}
+		resp.Root = textDocument(resp.Text)
+		return nil
+
+	default:
+		return parseHtmlContent(resp, body, contentType)
+	}
+}
+
+// transform applies the Loader's transforms to resp when its content is HTML.
+func (l *Loader) transform(resp *Response) {
+	if resp.Kind != ContentHtml && resp.Kind != ContentXhtml {
+		return
+	}
+	for _, t := range l.transforms {
+		t(resp.Root)
+	}
+}
+
+func parseHtmlContent(resp *Response, body io.Reader, contentType string) error {
+	decoded, charsetName, release := decodeCharset(body, contentType)
+	defer release()
+	root, err := html.Parse(decoded)
+	if err != nil {
+		return fmt.Errorf("Failed to parse response body: %w", err)
+	}
+	resp.Root = root
+	resp.Charset = charsetName
+	return nil
+}
+
+// ParseXml parses an XML document, such as XHTML, a feed, or a sitemap, into the same tree of
+// nodes produced by the HTML parser, so that it can be searched and converted in the same ways.
+// Elements are named by their local names, with the namespace of elements outside of XHTML in
+// the node's Namespace. Processing instructions other than the XML declaration are kept as
+// comments, as the HTML parser does, and directives are omitted. The document's
+// encoding is taken from charsetLabel, when not empty, or else from its XML declaration.
+func ParseXml(reader io.Reader, charsetLabel string) (*html.Node, error) {
+	var decoder *xml.Decoder
+	if charsetLabel != "" && !strings.EqualFold(charsetLabel, "utf-8") {
+		decoded, err := charset.NewReaderLabel(charsetLabel, reader)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode XML: %w", err)
+		}
+		decoder = xml.NewDecoder(decoded)
+		// already decoded, regardless of the declaration
+		decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+			return input, nil
+		}
+	} else {
+		decoder = xml.NewDecoder(reader)
+		decoder.CharsetReader = charset.NewReaderLabel
+	}
+
+	root := &html.Node{Type: html.DocumentNode}
+	current := root
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse XML: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			n := &html.Node{
+				Type:     html.ElementNode,
+				Data:     t.Name.Local,
+				DataAtom: atom.Lookup([]byte(t.Name.Local)),
+			}
+			if t.Name.Space != xhtmlNamespace {
+				n.Namespace = t.Name.Space
+			}
+			for _, a := range t.Attr {
+				n.Attr = append(n.Attr, xmlAttribute(a))
+			}
+			current.AppendChild(n)
+			current = n
+
+		case xml.EndElement:
+			current = current.Parent
+
+		case xml.CharData:
+			if current != root {
+				current.AppendChild(&html.Node{Type: html.TextNode, Data: string(t)})
+			}
+
+		case xml.Comment:
+			current.AppendChild(&html.Node{Type: html.CommentNode, Data: string(t)})
+
+		case xml.ProcInst:
+			if t.Target != "xml" {
+				data := "?" + t.Target
+				if len(t.Inst) > 0 {
+					data += " " + string(t.Inst)
+				}
+				current.AppendChild(&html.Node{Type: html.CommentNode, Data: data + "?"})
+			}
+		}
+	}
+	return root, nil
+}
+
+// xmlAttribute converts an XML attribute, restoring the form of namespace declarations.
+func xmlAttribute(a xml.Attr) html.Attribute {
+	switch {
+	case a.Name.Space == "xmlns":
+		return html.Attribute{Key: "xmlns:" + a.Name.Local, Val: a.Value}
+	case a.Name.Space == "" || a.Name.Space == xhtmlNamespace:
+		return html.Attribute{Key: a.Name.Local, Val: a.Value}
+	default:
+		return html.Attribute{Namespace: a.Name.Space, Key: a.Name.Local, Val: a.Value}
+	}
+}
+
+// textDocument builds a synthetic HTML document presenting text verbatim within a <pre> element.
+func textDocument(text string) *html.Node {
+	root := &html.Node{Type: html.DocumentNode}
+	htmlElem := &html.Node{Type: html.ElementNode, DataAtom: atom.Html, Data: "html"}
+	head := &html.Node{Type: html.ElementNode, DataAtom: atom.Head, Data: "head"}
+	body := &html.Node{Type: html.ElementNode, DataAtom: atom.Body, Data: "body"}
+	pre := &html.Node{Type: html.ElementNode, DataAtom: atom.Pre, Data: "pre"}
+	root.AppendChild(htmlElem)
+	htmlElem.AppendChild(head)
+	htmlElem.AppendChild(body)
+	body.AppendChild(pre)
+	if text != "" {
+		pre.AppendChild(&html.Node{Type: html.TextNode, Data: text})
+	}
+	return root
+}
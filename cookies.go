@@ -0,0 +1,239 @@
+package restify
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// netscapeHttpOnlyPrefix marks the lines of cookies.txt files that declare HttpOnly cookies,
+// which would otherwise be comments.
+const netscapeHttpOnlyPrefix = "#HttpOnly_"
+
+// WithCookieJar stores the cookies set by responses in jar and sends them with later requests,
+// such as to keep the session of a login across loads. Loaders otherwise neither store nor send
+// cookies.
+func WithCookieJar(jar http.CookieJar) LoaderOption {
+	return func(l *Loader) {
+		l.cookieJar = jar
+	}
+}
+
+// WithCookies sends the given cookies with the requests to their domains, such as those exported
+// from a browser after logging in manually and read by ReadCookieFile. They are imported into
+// the jar configured WithCookieJar or, if there is none, into a new in-memory jar that also
+// keeps the cookies set by responses.
+func WithCookies(cookies []*http.Cookie) LoaderOption {
+	return func(l *Loader) {
+		l.cookies = append(l.cookies, cookies...)
+	}
+}
+
+// ImportCookies stores the given cookies in jar, as parsed by ReadCookieFile, associating each
+// with the URL of its domain and path. Cookies without a domain, or that have expired, are
+// skipped.
+func ImportCookies(jar http.CookieJar, cookies []*http.Cookie) {
+	for _, cookie := range cookies {
+		imported := *cookie
+		host := strings.TrimPrefix(imported.Domain, ".")
+		if host == "" || (!imported.Expires.IsZero() && imported.Expires.Before(time.Now())) {
+			continue
+		}
+		if !strings.HasPrefix(imported.Domain, ".") {
+			// the jar treats cookies without a domain attribute as those of their host only
+			imported.Domain = ""
+		}
+		if imported.Path == "" {
+			imported.Path = "/"
+		}
+
+		cookieUrl := &url.URL{Scheme: "http", Host: host, Path: imported.Path}
+		if imported.Secure {
+			cookieUrl.Scheme = "https"
+		}
+		jar.SetCookies(cookieUrl, []*http.Cookie{&imported})
+	}
+}
+
+// ReadCookieFile reads the cookies exported from a browser into the file at path, either in the
+// cookies.txt format of Netscape, as written by extensions and by curl and wget, or as JSON, as
+// written by extensions for Chrome and Firefox, by Puppeteer, or as the storage state of
+// Playwright. Domains with a leading dot apply to their subdomains too, while those without
+// apply only to their host.
+func ReadCookieFile(path string) ([]*http.Cookie, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read cookies: %w", err)
+	}
+
+	trimmed := bytes.TrimSpace(content)
+	var cookies []*http.Cookie
+	if bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{")) {
+		cookies, err = ParseJsonCookies(trimmed)
+	} else {
+		cookies, err = ParseNetscapeCookies(bytes.NewReader(content))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse cookies in %s: %w", path, err)
+	}
+	return cookies, nil
+}
+
+// ParseNetscapeCookies parses cookies in the cookies.txt format of Netscape, in which each line
+// holds the tab-separated domain, whether subdomains are included, path, whether the cookie is
+// secure, expiry in seconds since the epoch or zero for session cookies, name, and value. Lines
+// starting with # are comments, except those starting with #HttpOnly_ that declare HttpOnly
+// cookies.
+func ParseNetscapeCookies(reader io.Reader) ([]*http.Cookie, error) {
+	var cookies []*http.Cookie
+	scanner := bufio.NewScanner(reader)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, netscapeHttpOnlyPrefix)
+		if httpOnly {
+			line = line[len(netscapeHttpOnlyPrefix):]
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			// cookies with empty values may lose their trailing tab
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d has %d fields rather than 7", number, len(fields))
+		}
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d has an invalid expiry %q", number, fields[4])
+		}
+
+		domain := fields[0]
+		if strings.EqualFold(fields[1], "TRUE") && !strings.HasPrefix(domain, ".") {
+			domain = "." + domain
+		}
+		cookie := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Domain:   domain,
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		if expiry > 0 {
+			cookie.Expires = time.Unix(expiry, 0)
+		}
+		cookies = append(cookies, cookie)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cookies, nil
+}
+
+// jsonCookie is a cookie as exported to JSON by browser extensions, of which
+// ExpirationDate and HostOnly are named by the chrome.cookies API, while Expires is named by
+// Puppeteer and Playwright.
+type jsonCookie struct {
+	Name           string   `json:"name"`
+	Value          string   `json:"value"`
+	Domain         string   `json:"domain"`
+	Path           string   `json:"path"`
+	Secure         bool     `json:"secure"`
+	HttpOnly       bool     `json:"httpOnly"`
+	HostOnly       bool     `json:"hostOnly"`
+	Session        bool     `json:"session"`
+	SameSite       string   `json:"sameSite"`
+	ExpirationDate *float64 `json:"expirationDate"`
+	Expires        *float64 `json:"expires"`
+}
+
+// ParseJsonCookies parses cookies exported as a JSON array of objects with the fields of the
+// chrome.cookies API, such as name, value, domain, path, secure, httpOnly, hostOnly, and
+// expirationDate in seconds since the epoch. The fields of Puppeteer and Playwright, which give
+// the expiry as expires, are accepted too, as is an object holding the array as cookies.
+func ParseJsonCookies(data []byte) ([]*http.Cookie, error) {
+	var exported []jsonCookie
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var state struct {
+			Cookies []jsonCookie `json:"cookies"`
+		}
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, err
+		}
+		exported = state.Cookies
+	} else if err := json.Unmarshal(data, &exported); err != nil {
+		return nil, err
+	}
+
+	cookies := make([]*http.Cookie, 0, len(exported))
+	for i, e := range exported {
+		if e.Name == "" {
+			return nil, fmt.Errorf("cookie %d has no name", i+1)
+		}
+		domain := e.Domain
+		if e.HostOnly {
+			domain = strings.TrimPrefix(domain, ".")
+		}
+		cookie := &http.Cookie{
+			Name:     e.Name,
+			Value:    e.Value,
+			Domain:   domain,
+			Path:     e.Path,
+			Secure:   e.Secure,
+			HttpOnly: e.HttpOnly,
+			SameSite: parseSameSite(e.SameSite),
+		}
+		expiry := e.ExpirationDate
+		if expiry == nil {
+			expiry = e.Expires
+		}
+		// session cookies have no expiry, or one of -1 when exported by Puppeteer and Playwright
+		if expiry != nil && *expiry > 0 && !e.Session {
+			seconds, fraction := math.Modf(*expiry)
+			cookie.Expires = time.Unix(int64(seconds), int64(fraction*float64(time.Second)))
+		}
+		cookies = append(cookies, cookie)
+	}
+	return cookies, nil
+}
+
+// parseSameSite interprets the SameSite attributes named by browsers, such as "lax",
+// "no_restriction", and "Strict".
+func parseSameSite(sameSite string) http.SameSite {
+	switch strings.ToLower(sameSite) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none", "no_restriction":
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteDefaultMode
+}
+
+// newCookieJar returns the jar of the Loader's client, importing the cookies it was configured
+// with, or nil if it was configured with neither.
+func (l *Loader) newCookieJar() http.CookieJar {
+	jar := l.cookieJar
+	if jar == nil && len(l.cookies) > 0 {
+		// creating a jar without a public suffix list cannot fail
+		jar, _ = cookiejar.New(nil)
+	}
+	if jar != nil {
+		ImportCookies(jar, l.cookies)
+	}
+	return jar
+}
@@ -0,0 +1,354 @@
+package restify
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCrawlConcurrency is the number of pages fetched at once when not configured.
+const DefaultCrawlConcurrency = 2
+
+// UnlimitedDepth is the MaxDepth of a Crawler that follows links however far they are from the
+// seeds.
+const UnlimitedDepth = -1
+
+// sharedFrontierPollInterval is the time between checks of an empty SharedFrontier while other
+// crawlers have requests pending.
+const sharedFrontierPollInterval = time.Second
+
+// CrawlResult is the outcome of crawling a single page.
+type CrawlResult struct {
+	// Request is the frontier entry that was crawled
+	Request CrawlRequest
+	// Response is the fetched page, nil if Err is set
+	Response *Response
+	// Err is set if the page could not be fetched
+	Err error
+	// Links are the links found on the page that were within scope, whether or not they had
+	// already been seen
+	Links []*url.URL
+	// Records are extracted from the page by the crawler's Ruleset, or by the ruleset of its
+	// Rulesets registered for the page's host
+	Records []Record
+	// ErrorPage is why IsErrorPage flagged the page as a soft 404 or blocked page, or empty if
+	// it did not
+	ErrorPage ErrorPageReason
+	// ExtractErr is set if extracting Records failed, including if they failed validation, in
+	// which case the Records are still given
+	ExtractErr error
+}
+
+// Crawler fetches pages starting from seed URLs and follows their links, within the configured
+// scope, until the frontier is exhausted. Configure its fields before calling Run.
+type Crawler struct {
+	// Loader fetches the pages, a default Loader if nil
+	Loader *Loader
+	// AllowedDomains restricts followed links to these hosts and their subdomains. Seeds are
+	// always crawled. If empty, links to any host are followed.
+	AllowedDomains []string
+	// AllowedPathPrefixes restricts followed links to URL paths with one of these prefixes.
+	// If empty, links with any path are followed.
+	AllowedPathPrefixes []string
+	// MaxDepth is the number of links to follow away from the seeds, so that zero crawls only
+	// the seeds. It is unlimited if negative, such as UnlimitedDepth.
+	MaxDepth int
+	// MaxPages stops the crawl after fetching this many pages, unlimited if zero
+	MaxPages int
+	// Concurrency is the number of pages fetched at once, DefaultCrawlConcurrency if zero
+	Concurrency int
+	// Frontier holds the URLs waiting to be crawled, a MemoryFrontier if nil
+	Frontier FrontierStore
+	// Deduper records the URLs that have been queued, a MemoryDeduper if nil
+	Deduper Deduper
+	// Handler is called with the result of each crawled page, one at a time. Returning an
+	// error stops the crawl and is returned by Run.
+	Handler func(result *CrawlResult) error
+	// Ruleset, when set, extracts the Records of each page that was fetched
+	Ruleset *Ruleset
+	// PreferredLanguages, when set, crawls the variant of each page in the first of these
+	// languages that it declares an alternate for, as with WithPreferredLanguages, instead of
+	// the page itself. The variant is then not crawled again when linked to.
+	PreferredLanguages []string
+	// SkipErrorPages does not extract Records from pages flagged by IsErrorPage, nor follow
+	// their links, so that their content is not stored. Their results are still given to the
+	// Handler, with ErrorPage set.
+	SkipErrorPages bool
+	// Rulesets, when set and Ruleset is not, extracts the Records of each page that was fetched
+	// with the ruleset registered for its host. Pages of other hosts have no records.
+	Rulesets *RulesetRegistry
+	// Sink, when set, stores the Records of each page as it is crawled, before they are given
+	// to the Handler. Failing to write a record stops the crawl and is returned by Run.
+	Sink Sink
+	// StatePath, when set, is the file that a checkpoint of the crawl is saved to, as with
+	// SaveState, every CheckpointInterval and when Run returns. Pass it to ResumeFrom to
+	// continue the crawl.
+	StatePath string
+	// CheckpointInterval is the time between saves to StatePath, DefaultCheckpointInterval if
+	// zero
+	CheckpointInterval time.Duration
+	// URLNormalizer normalizes the URLs crawled, so that variations of the URLs of pages already
+	// seen are recognized, DefaultURLNormalizer if nil. When the Loader was configured
+	// WithURLNormalizer, its normalizer is used instead, so that pages are recognized by the URLs
+	// the Loader requests. The Loader created when Loader is nil is configured with this one.
+	URLNormalizer *URLNormalizer
+
+	// normalizer is the URLNormalizer in use, resolved by init
+	normalizer *URLNormalizer
+
+	mu           sync.Mutex
+	inFlight     map[string]CrawlRequest
+	visited      map[string]CrawlStatus
+	resumedPages int
+}
+
+// Run crawls from the given seed URLs until the frontier is exhausted, MaxPages is reached,
+// the Handler returns an error, or ctx is done. A SharedFrontier is only exhausted once no
+// crawler has requests pending. Failing to fetch a page does not stop the crawl;
+// the failure is reported in the page's CrawlResult.
+func (c *Crawler) Run(ctx context.Context, seeds ...*url.URL) error {
+	err := c.run(ctx, seeds...)
+	if c.StatePath != "" {
+		if saveErr := c.SaveState(c.StatePath); err == nil {
+			err = saveErr
+		}
+	}
+	return err
+}
+
+func (c *Crawler) run(ctx context.Context, seeds ...*url.URL) error {
+	c.init()
+	checkpointInterval := c.CheckpointInterval
+	if checkpointInterval <= 0 {
+		checkpointInterval = DefaultCheckpointInterval
+	}
+	checkpointed := time.Now()
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultCrawlConcurrency
+	}
+
+	for _, seed := range seeds {
+		if err := c.enqueue(seed, 0, ""); err != nil {
+			return err
+		}
+	}
+
+	// results is buffered so that fetches in flight can always finish, even once Run returns
+	results := make(chan *CrawlResult, concurrency)
+	active, started := 0, c.resumedPages
+	for {
+		exhausted := false
+		for active < concurrency && (c.MaxPages == 0 || started < c.MaxPages) && ctx.Err() == nil {
+			request, ok, err := c.pop()
+			if err != nil {
+				return fmt.Errorf("Failed to pop from frontier: %w", err)
+			}
+			if !ok {
+				exhausted = true
+				break
+			}
+			active++
+			started++
+			go func(request CrawlRequest) {
+				results <- c.crawl(ctx, request)
+			}(request)
+		}
+		if active == 0 {
+			if !exhausted || ctx.Err() != nil {
+				return ctx.Err()
+			}
+			waited, err := c.awaitOthers(ctx)
+			if err != nil || !waited {
+				return err
+			}
+			continue
+		}
+
+		result := <-results
+		active--
+		if err := c.handle(ctx, result); err != nil {
+			return err
+		}
+		if shared, ok := c.Frontier.(SharedFrontier); ok {
+			if err := shared.Done(result.Request); err != nil {
+				return fmt.Errorf("Failed to finish request in frontier: %w", err)
+			}
+		}
+		if c.StatePath != "" && time.Since(checkpointed) >= checkpointInterval {
+			if err := c.SaveState(c.StatePath); err != nil {
+				return err
+			}
+			checkpointed = time.Now()
+		}
+	}
+}
+
+// awaitOthers waits for other crawlers to queue more requests while they have requests pending
+// in a SharedFrontier, reporting whether it waited.
+func (c *Crawler) awaitOthers(ctx context.Context) (bool, error) {
+	shared, ok := c.Frontier.(SharedFrontier)
+	if !ok {
+		return false, nil
+	}
+	pending, err := shared.Pending()
+	if err != nil {
+		return false, fmt.Errorf("Failed to check pending requests of frontier: %w", err)
+	}
+	if pending == 0 {
+		return false, nil
+	}
+	select {
+	case <-time.After(sharedFrontierPollInterval):
+	case <-ctx.Done():
+	}
+	return true, nil
+}
+
+// crawl fetches a single page and gathers its in-scope links.
+func (c *Crawler) crawl(ctx context.Context, request CrawlRequest) *CrawlResult {
+	result := &CrawlResult{Request: request}
+
+	pageUrl, err := url.Parse(request.URL)
+	if err != nil {
+		result.Err = fmt.Errorf("Failed to parse crawl URL: %w", err)
+		return result
+	}
+	resp, err := c.Loader.Fetch(ctx, pageUrl)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if len(c.PreferredLanguages) > 0 && resp.Language == "" {
+		resp = c.Loader.preferLanguage(ctx, resp, c.PreferredLanguages)
+		if resp.Language != "" {
+			if key, err := c.normalize(resp.URL); err == nil {
+				if _, err := c.Deduper.MarkSeen(key); err != nil {
+					result.Err = fmt.Errorf("Failed to mark URL as seen: %w", err)
+					return result
+				}
+			}
+		}
+	}
+	result.Response = resp
+	if _, reason := IsErrorPage(resp.Root, resp); reason != "" {
+		result.ErrorPage = reason
+		if c.SkipErrorPages {
+			return result
+		}
+	}
+
+	rules := c.Ruleset
+	if rules == nil && c.Rulesets != nil {
+		rules, _ = c.Rulesets.Lookup(resp.URL)
+	}
+	if rules != nil {
+		result.Records, result.ExtractErr = rules.Extract(resp.Root)
+	}
+
+	for _, link := range extractLinks(resp.Root, resp.URL, c.normalizer) {
+		if c.inScope(link) {
+			result.Links = append(result.Links, link)
+		}
+	}
+	return result
+}
+
+// handle stores and reports a result and queues its links.
+func (c *Crawler) handle(ctx context.Context, result *CrawlResult) error {
+	if c.Sink != nil {
+		if err := writeRecords(ctx, c.Sink, result.Records); err != nil {
+			return err
+		}
+	}
+	if c.Handler != nil {
+		if err := c.Handler(result); err != nil {
+			return err
+		}
+	}
+
+	// the links are queued along with recording the visit so that checkpoints include both or
+	// neither
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.visit(result)
+	depth := result.Request.Depth + 1
+	if c.MaxDepth >= 0 && depth > c.MaxDepth {
+		return nil
+	}
+	for _, link := range result.Links {
+		if err := c.enqueue(link, depth, result.Request.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enqueue adds the normalized link to the frontier unless it has already been seen.
+func (c *Crawler) enqueue(link *url.URL, depth int, referrer string) error {
+	key, err := c.normalize(link)
+	if err != nil {
+		return err
+	}
+	added, err := c.Deduper.MarkSeen(key)
+	if err != nil {
+		return fmt.Errorf("Failed to mark URL as seen: %w", err)
+	}
+	if !added {
+		return nil
+	}
+
+	if err := c.Frontier.Push(CrawlRequest{URL: key, Depth: depth, Referrer: referrer}); err != nil {
+		return fmt.Errorf("Failed to push to frontier: %w", err)
+	}
+	return nil
+}
+
+// inScope reports whether a discovered link may be followed.
+func (c *Crawler) inScope(link *url.URL) bool {
+	if link.Scheme != "http" && link.Scheme != "https" {
+		return false
+	}
+
+	if len(c.AllowedDomains) > 0 {
+		host := strings.ToLower(link.Hostname())
+		allowed := false
+		for _, domain := range c.AllowedDomains {
+			domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+
+	if len(c.AllowedPathPrefixes) > 0 {
+		path := link.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		for _, prefix := range c.AllowedPathPrefixes {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// normalize reduces the variations of a URL with the crawler's URLNormalizer, so that visited
+// pages are recognized.
+func (c *Crawler) normalize(link *url.URL) (string, error) {
+	normalized, err := c.normalizer.Normalize(link)
+	if err != nil {
+		return "", err
+	}
+	return normalized.String(), nil
+}
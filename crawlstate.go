@@ -0,0 +1,194 @@
+package restify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultCheckpointInterval is the time between saves of a Crawler's state to its StatePath
+// when not configured.
+const DefaultCheckpointInterval = 30 * time.Second
+
+// CrawlStatus is the outcome of a page already crawled, as kept in a CrawlState.
+type CrawlStatus struct {
+	// StatusCode is the HTTP status of the page, zero if it could not be fetched
+	StatusCode int `json:"statusCode,omitempty"`
+	// Err describes why the page could not be fetched
+	Err string `json:"error,omitempty"`
+	// Records is the number of records extracted from the page
+	Records int `json:"records,omitempty"`
+	// Time is when the page was crawled
+	Time time.Time `json:"time"`
+}
+
+// CrawlState is a checkpoint of a crawl, from which it can be resumed.
+type CrawlState struct {
+	// Pending are the requests waiting to be crawled, including those that were being fetched
+	Pending []CrawlRequest `json:"pending"`
+	// Seen are the normalized URLs that have been queued, when the Deduper is a MemoryDeduper
+	Seen []string `json:"seen,omitempty"`
+	// Visited holds the outcome of each page crawled, keyed by normalized URL
+	Visited map[string]CrawlStatus `json:"visited"`
+}
+
+// State returns a checkpoint of the crawl. It may be called while the crawler is running,
+// such as from the Handler or another goroutine. The queue and visited set are only included
+// when the Frontier and Deduper are the in-memory implementations; other implementations are
+// expected to persist themselves, such as those of the redisstore package.
+func (c *Crawler) State() *CrawlState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := &CrawlState{Visited: make(map[string]CrawlStatus, len(c.visited))}
+	for key, status := range c.visited {
+		state.Visited[key] = status
+	}
+	for _, request := range c.inFlight {
+		state.Pending = append(state.Pending, request)
+	}
+	sort.Slice(state.Pending, func(i, j int) bool {
+		return state.Pending[i].URL < state.Pending[j].URL
+	})
+
+	if frontier, ok := c.Frontier.(*MemoryFrontier); ok {
+		frontier.mu.Lock()
+		state.Pending = append(state.Pending, frontier.queue...)
+		frontier.mu.Unlock()
+	}
+	if deduper, ok := c.Deduper.(*MemoryDeduper); ok {
+		deduper.mu.Lock()
+		for key := range deduper.seen {
+			state.Seen = append(state.Seen, key)
+		}
+		deduper.mu.Unlock()
+		sort.Strings(state.Seen)
+	}
+	return state
+}
+
+// SaveState writes a checkpoint of the crawl to the file at path as JSON, replacing it
+// atomically so that a crash while saving leaves the previous checkpoint intact. Setting the
+// StatePath of the crawler saves it periodically instead.
+func (c *Crawler) SaveState(path string) error {
+	content, err := json.Marshal(c.State())
+	if err != nil {
+		return fmt.Errorf("Failed to encode crawl state: %w", err)
+	}
+
+	temp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("Failed to save crawl state: %w", err)
+	}
+	_, err = temp.Write(content)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		//goland:noinspection GoUnhandledErrorResult
+		os.Remove(temp.Name())
+		return fmt.Errorf("Failed to save crawl state: %w", err)
+	}
+	return nil
+}
+
+// ResumeFrom restores the checkpoint saved at path, so that the next Run continues the crawl
+// rather than starting over: the pending requests are queued, the seen URLs are marked, and
+// pages already visited count towards MaxPages. Seeds given to Run that were already seen are
+// not crawled again. Call it before Run. If the file does not exist the error satisfies
+// os.IsNotExist, and the crawl can instead be started afresh.
+func (c *Crawler) ResumeFrom(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var state CrawlState
+	if err := json.Unmarshal(content, &state); err != nil {
+		return fmt.Errorf("Failed to decode crawl state: %w", err)
+	}
+	c.init()
+
+	for _, key := range state.Seen {
+		if _, err := c.Deduper.MarkSeen(key); err != nil {
+			return fmt.Errorf("Failed to mark URL as seen: %w", err)
+		}
+	}
+	for _, request := range state.Pending {
+		if _, err := c.Deduper.MarkSeen(request.URL); err != nil {
+			return fmt.Errorf("Failed to mark URL as seen: %w", err)
+		}
+		if err := c.Frontier.Push(request); err != nil {
+			return fmt.Errorf("Failed to push to frontier: %w", err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, status := range state.Visited {
+		c.visited[key] = status
+	}
+	c.resumedPages += len(state.Visited)
+	return nil
+}
+
+// init creates the defaults of the crawler that were not configured.
+func (c *Crawler) init() {
+	normalizer := c.URLNormalizer
+	if normalizer == nil {
+		normalizer = DefaultURLNormalizer
+	}
+	if c.Loader == nil {
+		c.Loader = NewLoader(WithURLNormalizer(normalizer))
+	}
+	if c.Loader.urlNormalizer != nil {
+		normalizer = c.Loader.urlNormalizer
+	}
+	c.normalizer = normalizer
+	if c.Frontier == nil {
+		c.Frontier = NewMemoryFrontier()
+	}
+	if c.Deduper == nil {
+		c.Deduper = NewMemoryDeduper()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inFlight == nil {
+		c.inFlight = make(map[string]CrawlRequest)
+	}
+	if c.visited == nil {
+		c.visited = make(map[string]CrawlStatus)
+	}
+}
+
+// pop removes the next request from the frontier, tracking it as in flight so that it is
+// included in checkpoints until it has been handled.
+func (c *Crawler) pop() (CrawlRequest, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	request, ok, err := c.Frontier.Pop()
+	if ok && err == nil {
+		c.inFlight[request.URL] = request
+	}
+	return request, ok, err
+}
+
+// visit records the outcome of a crawled page. The crawler's mu must be held.
+func (c *Crawler) visit(result *CrawlResult) {
+	status := CrawlStatus{Records: len(result.Records), Time: time.Now()}
+	if result.Response != nil {
+		status.StatusCode = result.Response.StatusCode
+	}
+	if result.Err != nil {
+		status.Err = result.Err.Error()
+	}
+	delete(c.inFlight, result.Request.URL)
+	c.visited[result.Request.URL] = status
+}
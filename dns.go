@@ -0,0 +1,506 @@
+package restify
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DefaultDnsCacheTTL is the time that addresses are cached by a CachingResolver when the
+// resolver they came from did not report their TTL, as the system resolver does not.
+const DefaultDnsCacheTTL = time.Minute
+
+// UnknownTTL is the TTL reported by a Resolver that does not know how long the addresses it
+// resolved may be cached for, as distinct from a TTL of zero, which forbids caching them.
+const UnknownTTL time.Duration = -1
+
+// dnsQueryTimeout is the time allowed for each query to a DNS server.
+const dnsQueryTimeout = 5 * time.Second
+
+// maxDnsUdpSize is the size of DNS responses accepted over UDP, as advertised with EDNS.
+const maxDnsUdpSize = 4096
+
+// Resolver resolves host names to addresses for the connections of a Loader configured
+// WithResolver.
+type Resolver interface {
+	// LookupIP returns the addresses of host, along with the time they may be cached for, or
+	// UnknownTTL if unknown.
+	LookupIP(ctx context.Context, host string) (addresses []net.IP, ttl time.Duration, err error)
+}
+
+// WithResolver resolves the host names of the Loader's connections with resolver, in place of
+// the system's resolver, such as to use particular DNS servers or DNS over HTTPS. Use a
+// CachingResolver, or also WithDnsCache, to avoid resolving each host name for every connection.
+// It applies when the Loader's transport is an *http.Transport, as it is by default.
+func WithResolver(resolver Resolver) LoaderOption {
+	return func(l *Loader) {
+		l.resolver = resolver
+	}
+}
+
+// WithDnsCache caches the addresses resolved for the Loader's connections for as long as their
+// TTL allows, since large crawls otherwise resolve the same hosts over and over. It wraps the
+// resolver given WithResolver, in either order, or the system's resolver, with a
+// CachingResolver. It applies when the Loader's transport is an *http.Transport.
+func WithDnsCache() LoaderOption {
+	return func(l *Loader) {
+		l.dnsCache = true
+	}
+}
+
+// SystemResolver is a Resolver using the system's resolver, which does not report TTLs.
+type SystemResolver struct {
+	// Resolver is the underlying resolver, net.DefaultResolver if nil
+	Resolver *net.Resolver
+}
+
+// LookupIP implements Resolver.
+func (r *SystemResolver) LookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addresses, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, 0, err
+	}
+	ips := make([]net.IP, len(addresses))
+	for i, address := range addresses {
+		ips[i] = address.IP
+	}
+	return ips, UnknownTTL, nil
+}
+
+// DnsResolver is a Resolver querying particular DNS servers directly over UDP, falling back to
+// TCP for responses too large for UDP.
+type DnsResolver struct {
+	servers []string
+}
+
+// NewDnsResolver creates a DnsResolver querying the given servers, such as "1.1.1.1" or
+// "[2001:4860:4860::8888]:53", in order until one responds. The port defaults to 53.
+func NewDnsResolver(servers ...string) *DnsResolver {
+	r := &DnsResolver{}
+	for _, server := range servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+		}
+		r.servers = append(r.servers, server)
+	}
+	return r
+}
+
+// LookupIP implements Resolver.
+func (r *DnsResolver) LookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	if len(r.servers) == 0 {
+		return nil, 0, errors.New("No DNS servers configured")
+	}
+	return lookupIP(ctx, host, func(ctx context.Context, query []byte) ([]byte, error) {
+		var err error
+		for _, server := range r.servers {
+			var response []byte
+			if response, err = exchangeDns(ctx, server, query); err == nil {
+				return response, nil
+			}
+		}
+		return nil, err
+	})
+}
+
+// DohResolver is a Resolver using DNS over HTTPS, as described by RFC 8484.
+type DohResolver struct {
+	// URL is the endpoint queried, such as "https://cloudflare-dns.com/dns-query"
+	URL string
+	// Client sends the queries, a client with a timeout of dnsQueryTimeout if nil. It must not
+	// itself use a Loader configured with this resolver.
+	Client *http.Client
+}
+
+// NewDohResolver creates a DohResolver querying the endpoint at url.
+func NewDohResolver(url string) *DohResolver {
+	return &DohResolver{URL: url}
+}
+
+// LookupIP implements Resolver.
+func (r *DohResolver) LookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	client := r.Client
+	if client == nil {
+		client = &http.Client{Timeout: dnsQueryTimeout}
+	}
+	return lookupIP(ctx, host, func(ctx context.Context, query []byte) ([]byte, error) {
+		// the ID is zero so that responses can be cached by HTTP caches, as RFC 8484 recommends
+		query[0], query[1] = 0, 0
+		request, err := http.NewRequestWithContext(ctx, "POST", r.URL, bytes.NewReader(query))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to request: %w", err)
+		}
+		request.Header.Set("content-type", "application/dns-message")
+		request.Header.Set("accept", "application/dns-message")
+
+		resp, err := client.Do(request)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to query DNS over HTTPS: %w", err)
+		}
+		//goland:noinspection GoUnhandledErrorResult
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("DNS over HTTPS responded with status %d", resp.StatusCode)
+		}
+		response, err := ioutil.ReadAll(io.LimitReader(resp.Body, 65535))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read DNS over HTTPS response: %w", err)
+		}
+		if len(response) >= 2 {
+			// restore the ID so the response matches the query
+			response[0], response[1] = query[0], query[1]
+		}
+		return response, nil
+	})
+}
+
+// lookupIP resolves the IPv4 and IPv6 addresses of host by sending queries through exchange,
+// returning them with the smallest TTL of the answers.
+func lookupIP(ctx context.Context, host string, exchange func(ctx context.Context, query []byte) ([]byte, error)) ([]net.IP, time.Duration, error) {
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		return []net.IP{ip}, UnknownTTL, nil
+	}
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: "invalid host name", Name: host}
+	}
+
+	var addresses []net.IP
+	var ttl uint32
+	var lastErr error
+	for _, queryType := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		id := uint16(rand.Uint32())
+		query, err := buildDnsQuery(id, name, queryType)
+		if err != nil {
+			return nil, 0, fmt.Errorf("Failed to build DNS query: %w", err)
+		}
+		response, err := exchange(ctx, query)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		found, answerTtl, err := parseDnsResponse(response, id, host)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(found) > 0 && (len(addresses) == 0 || answerTtl < ttl) {
+			ttl = answerTtl
+		}
+		addresses = append(addresses, found...)
+	}
+
+	if len(addresses) == 0 {
+		if lastErr == nil {
+			lastErr = &net.DNSError{Err: "no addresses found", Name: host, IsNotFound: true}
+		}
+		return nil, 0, lastErr
+	}
+	return addresses, time.Duration(ttl) * time.Second, nil
+}
+
+// buildDnsQuery encodes a recursive query for records of queryType named name.
+func buildDnsQuery(id uint16, name dnsmessage.Name, queryType dnsmessage.Type) ([]byte, error) {
+	builder := dnsmessage.NewBuilder(make([]byte, 0, 512), dnsmessage.Header{ID: id, RecursionDesired: true})
+	builder.EnableCompression()
+	if err := builder.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := builder.Question(dnsmessage.Question{Name: name, Type: queryType, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	if err := builder.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(maxDnsUdpSize, dnsmessage.RCodeSuccess, false); err != nil {
+		return nil, err
+	}
+	if err := builder.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+		return nil, err
+	}
+	return builder.Finish()
+}
+
+// parseDnsResponse decodes the addresses answering the query with the given id, along with the
+// smallest TTL of the answers.
+func parseDnsResponse(response []byte, id uint16, host string) ([]net.IP, uint32, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(response)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Failed to parse DNS response: %w", err)
+	}
+	if header.ID != id || !header.Response {
+		return nil, 0, errors.New("DNS response does not match its query")
+	}
+	switch header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	default:
+		return nil, 0, &net.DNSError{Err: "server failure: " + header.RCode.String(), Name: host, IsTemporary: true}
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		return nil, 0, fmt.Errorf("Failed to parse DNS response: %w", err)
+	}
+
+	var addresses []net.IP
+	var ttl uint32
+	for {
+		answer, err := parser.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("Failed to parse DNS response: %w", err)
+		}
+		var ip net.IP
+		switch answer.Type {
+		case dnsmessage.TypeA:
+			resource, err := parser.AResource()
+			if err != nil {
+				return nil, 0, fmt.Errorf("Failed to parse DNS response: %w", err)
+			}
+			ip = net.IP(resource.A[:])
+		case dnsmessage.TypeAAAA:
+			resource, err := parser.AAAAResource()
+			if err != nil {
+				return nil, 0, fmt.Errorf("Failed to parse DNS response: %w", err)
+			}
+			ip = net.IP(resource.AAAA[:])
+		default:
+			// CNAME records lead to the addresses that follow them
+			if err := parser.SkipAnswer(); err != nil {
+				return nil, 0, fmt.Errorf("Failed to parse DNS response: %w", err)
+			}
+			continue
+		}
+		if len(addresses) == 0 || answer.TTL < ttl {
+			ttl = answer.TTL
+		}
+		addresses = append(addresses, ip)
+	}
+	return addresses, ttl, nil
+}
+
+// exchangeDns sends query to server over UDP, retrying over TCP if the response is truncated.
+func exchangeDns(ctx context.Context, server string, query []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsQueryTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to DNS server: %w", err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		//goland:noinspection GoUnhandledErrorResult
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, fmt.Errorf("Failed to query DNS server: %w", err)
+	}
+
+	response := make([]byte, maxDnsUdpSize)
+	for {
+		n, err := conn.Read(response)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read DNS response: %w", err)
+		}
+		// responses to other queries, such as spoofed ones, are ignored
+		if n < 3 || response[0] != query[0] || response[1] != query[1] {
+			continue
+		}
+		if response[2]&0x02 == 0 {
+			return response[:n], nil
+		}
+		break
+	}
+
+	// the response was truncated, so the query is repeated over TCP
+	tcp, err := dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to DNS server: %w", err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer tcp.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		//goland:noinspection GoUnhandledErrorResult
+		tcp.SetDeadline(deadline)
+	}
+	framed := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(framed, uint16(len(query)))
+	copy(framed[2:], query)
+	if _, err := tcp.Write(framed); err != nil {
+		return nil, fmt.Errorf("Failed to query DNS server: %w", err)
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(tcp, length[:]); err != nil {
+		return nil, fmt.Errorf("Failed to read DNS response: %w", err)
+	}
+	response = make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(tcp, response); err != nil {
+		return nil, fmt.Errorf("Failed to read DNS response: %w", err)
+	}
+	return response, nil
+}
+
+// CachingResolver caches the addresses resolved by another Resolver for as long as their TTL
+// allows, or DefaultDnsCacheTTL when it is UnknownTTL. Concurrent lookups of the same host share
+// a single query, which runs apart from the context of any one lookup, so that canceling it does
+// not fail the others. Failures, and addresses with a TTL of zero, are not cached, and expired
+// addresses are evicted. It is safe for concurrent use.
+type CachingResolver struct {
+	next Resolver
+
+	mu      sync.Mutex
+	entries map[string]*dnsCacheEntry
+	// swept is when expired entries were last evicted
+	swept time.Time
+}
+
+type dnsCacheEntry struct {
+	// ready is closed once the lookup has finished
+	ready     chan struct{}
+	addresses []net.IP
+	err       error
+	expires   time.Time
+}
+
+// NewCachingResolver creates a CachingResolver caching the addresses resolved by next, or by the
+// system's resolver if nil.
+func NewCachingResolver(next Resolver) *CachingResolver {
+	if next == nil {
+		next = &SystemResolver{}
+	}
+	return &CachingResolver{next: next, entries: make(map[string]*dnsCacheEntry), swept: time.Now()}
+}
+
+// LookupIP implements Resolver.
+func (r *CachingResolver) LookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	key := strings.ToLower(host)
+	now := time.Now()
+	r.mu.Lock()
+	if now.Sub(r.swept) >= DefaultDnsCacheTTL {
+		r.sweep(now)
+	}
+	entry, ok := r.entries[key]
+	if ok {
+		select {
+		case <-entry.ready:
+			if now.After(entry.expires) {
+				ok = false
+			}
+		default:
+		}
+	}
+	if !ok {
+		entry = &dnsCacheEntry{ready: make(chan struct{})}
+		r.entries[key] = entry
+		go r.resolve(key, host, entry)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-entry.ready:
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+	if entry.err != nil {
+		return nil, 0, entry.err
+	}
+	ttl := time.Until(entry.expires)
+	if ttl < 0 {
+		ttl = 0
+	}
+	return entry.addresses, ttl, nil
+}
+
+// resolve looks up host for entry, bounded by dnsQueryTimeout rather than the context of the
+// lookup that started it, and removes entry if it is not to be cached.
+func (r *CachingResolver) resolve(key, host string, entry *dnsCacheEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsQueryTimeout)
+	defer cancel()
+	addresses, ttl, err := r.next.LookupIP(ctx, host)
+	if ttl < 0 {
+		ttl = DefaultDnsCacheTTL
+	}
+	entry.addresses, entry.err, entry.expires = addresses, err, time.Now().Add(ttl)
+	close(entry.ready)
+	if err != nil || ttl == 0 {
+		// lookups already waiting share the result, but later ones query again
+		r.mu.Lock()
+		if r.entries[key] == entry {
+			delete(r.entries, key)
+		}
+		r.mu.Unlock()
+	}
+}
+
+// sweep evicts the entries that expired by now. The resolver must be locked.
+func (r *CachingResolver) sweep(now time.Time) {
+	for key, entry := range r.entries {
+		select {
+		case <-entry.ready:
+			if now.After(entry.expires) {
+				delete(r.entries, key)
+			}
+		default:
+		}
+	}
+	r.swept = now
+}
+
+// Flush removes every cached address.
+func (r *CachingResolver) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = make(map[string]*dnsCacheEntry)
+}
+
+// dialContext connects to addr, resolving its host with the Loader's resolver when it has one
+// and trying each address in turn, and checking the addresses against the Loader's host filter.
+func (l *Loader) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := l.dialer()
+	if l.resolver == nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	addresses, _, err := l.resolver.LookupIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	err = &net.DNSError{Err: "no addresses found", Name: host, IsNotFound: true}
+	for _, ip := range addresses {
+		var conn net.Conn
+		if conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port)); err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
@@ -4,4 +4,16 @@
 //
 // The main function in the cmd package can be referenced as an example use of the functions
 // provided here.
+//
+// # Allocations
+//
+// Parsing is performed by golang.org/x/net/html, which allocates a node for each element, text,
+// and comment, along with their attributes, and accounts for nearly all allocations of a load.
+// Its tokenizer cannot be reused between documents. The buffers restify uses around the parser,
+// for sniffing character sets and decompressing responses, are pooled, so a Loader adds only a
+// few small allocations per load beyond the parse and those of net/http. ConvertHtmlToJson
+// allocates a JsonNode per element together with its attribute map and joined text, when it
+// has them. Where only parts of a large document are needed, StreamMatches avoids building
+// the whole tree, and where the same pages are loaded repeatedly, WithDocumentStore avoids
+// parsing them again.
 package restify
@@ -0,0 +1,329 @@
+package restify
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Approximate sizes, in bytes, of the structures of a parsed document, used to estimate the
+// memory held by a DocumentStore.
+const (
+	documentNodeSize      = 120
+	documentAttributeSize = 48
+)
+
+// DocumentStoreOptions configures the eviction of a DocumentStore. Limits of zero are
+// unlimited.
+type DocumentStoreOptions struct {
+	// MaxEntries is the number of documents kept
+	MaxEntries int
+	// MaxBytes is the estimated memory that the kept documents may hold, including their
+	// parsed trees and any retained bodies
+	MaxBytes int64
+	// TTL is how long a document is used without requesting it again. Once it has passed, the
+	// document is revalidated by a conditional request on its ETag and Last-Modified, being used
+	// again if the server responds that it is not modified. When zero, documents are always
+	// revalidated, so only those with an ETag or Last-Modified are kept.
+	TTL time.Duration
+}
+
+// DocumentStoreStats counts the use of a DocumentStore.
+type DocumentStoreStats struct {
+	// Entries is the number of documents kept
+	Entries int `json:"entries"`
+	// Bytes is the estimated memory held by the documents kept
+	Bytes int64 `json:"bytes"`
+	// Hits counts the loads served by documents within their TTL, without a request
+	Hits int64 `json:"hits"`
+	// Revalidations counts the loads served by documents the server responded were not modified
+	Revalidations int64 `json:"revalidations"`
+	// Misses counts the loads that retrieved and parsed the page
+	Misses int64 `json:"misses"`
+	// Evictions counts the documents removed to keep within the limits
+	Evictions int64 `json:"evictions"`
+}
+
+// DocumentStore keeps the documents recently loaded by a Loader in memory, so that loading the
+// same pages again, such as the hub pages of repeated crawls, neither parses them again nor,
+// within their TTL, requests them. The least recently used documents are evicted to keep within
+// its limits. Each DocumentStore belongs to the Loader configured WithDocumentStore, so that
+// pages loaded with the headers, cookies, and transforms of one Loader are never served to
+// another. Share the Loader, which is safe for concurrent use, such as between Crawlers, to
+// share its store.
+//
+// The Responses given for a kept page are copies, but their documents are shared by every load
+// of the page, so must not be modified.
+type DocumentStore struct {
+	options DocumentStoreOptions
+	// normalizer normalizes the URLs of pages as the Loader requests them
+	normalizer *URLNormalizer
+
+	mu sync.Mutex
+	// entries index the elements of order, which holds *storedDocument with the most recently
+	// used first
+	entries map[string]*list.Element
+	order   *list.List
+	stats   DocumentStoreStats
+}
+
+type storedDocument struct {
+	key      string
+	response *Response
+	// state holds the validators of the response for conditional requests
+	state   PageState
+	size    int64
+	fetched time.Time
+}
+
+// newDocumentStore creates an empty DocumentStore limited by options, for a Loader normalizing
+// URLs with normalizer.
+func newDocumentStore(options DocumentStoreOptions, normalizer *URLNormalizer) *DocumentStore {
+	return &DocumentStore{
+		options:    options,
+		normalizer: normalizer,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// WithDocumentStore keeps the pages loaded by the Loader in a DocumentStore limited by options,
+// serving them from it when they are loaded again. Only successful HTTP responses are kept,
+// unless their Cache-Control forbids storing them or they were reached by HTML redirects, or by
+// preferring other versions or languages of pages. Loads by LoadIfChanged bypass the store. The
+// store is given by Loader.DocumentStore.
+func WithDocumentStore(options DocumentStoreOptions) LoaderOption {
+	return func(l *Loader) {
+		l.documentOptions = &options
+	}
+}
+
+// DocumentStore returns the store of the pages loaded, or nil if the Loader was not configured
+// WithDocumentStore.
+func (l *Loader) DocumentStore() *DocumentStore {
+	return l.documents
+}
+
+// Get returns a copy of the kept Response of the page at u, if it is within its TTL.
+func (s *DocumentStore) Get(u *url.URL) (*Response, bool) {
+	key, ok := s.key(u)
+	if !ok {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	document := element.Value.(*storedDocument)
+	if time.Since(document.fetched) >= s.options.TTL {
+		return nil, false
+	}
+	s.order.MoveToFront(element)
+	return copyResponse(document.response), true
+}
+
+// Remove discards the kept document of the page at u, if any.
+func (s *DocumentStore) Remove(u *url.URL) {
+	key, ok := s.key(u)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if element, ok := s.entries[key]; ok {
+		s.remove(element)
+	}
+}
+
+// key returns the key of the page at u, its URL normalized as the Loader requests it.
+func (s *DocumentStore) key(u *url.URL) (string, bool) {
+	normalized, err := s.normalizer.Normalize(u)
+	if err != nil {
+		return "", false
+	}
+	return normalized.String(), true
+}
+
+// Flush discards every kept document.
+func (s *DocumentStore) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = make(map[string]*list.Element)
+	s.order.Init()
+	s.stats.Entries = 0
+	s.stats.Bytes = 0
+}
+
+// Stats reports the use of the store.
+func (s *DocumentStore) Stats() DocumentStoreStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// lookup returns the document kept for key and whether it is within its TTL. Documents that
+// are stale and cannot be revalidated are discarded.
+func (s *DocumentStore) lookup(key string) (document *storedDocument, fresh bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	document = element.Value.(*storedDocument)
+	if time.Since(document.fetched) < s.options.TTL {
+		s.stats.Hits++
+		s.order.MoveToFront(element)
+		return document, true
+	}
+	if document.state.ETag == "" && document.state.LastModified == "" {
+		s.remove(element)
+		return nil, false
+	}
+	return document, false
+}
+
+// revalidated renews the TTL of document once the server responded that it is not modified.
+func (s *DocumentStore) revalidated(document *storedDocument) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Revalidations++
+	if element, ok := s.entries[document.key]; ok && element.Value == document {
+		document.fetched = time.Now()
+		s.order.MoveToFront(element)
+	}
+}
+
+// store records a load that retrieved resp for key and, if keep is set, keeps it in place of any
+// document kept before, evicting the least recently used documents beyond the limits.
+func (s *DocumentStore) store(key string, resp *Response, keep bool) {
+	state := PageState{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	size := responseSize(resp)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if element, ok := s.entries[key]; ok {
+		s.remove(element)
+	}
+	s.stats.Misses++
+	if !keep {
+		return
+	}
+	if s.options.TTL <= 0 && state.ETag == "" && state.LastModified == "" {
+		// the document could never be used
+		return
+	}
+	if s.options.MaxBytes > 0 && size > s.options.MaxBytes {
+		return
+	}
+
+	// the caller keeps resp, so the store keeps a copy it cannot modify
+	document := &storedDocument{key: key, response: copyResponse(resp), state: state, size: size, fetched: time.Now()}
+	s.entries[key] = s.order.PushFront(document)
+	s.stats.Entries++
+	s.stats.Bytes += size
+	for (s.options.MaxEntries > 0 && s.stats.Entries > s.options.MaxEntries) ||
+		(s.options.MaxBytes > 0 && s.stats.Bytes > s.options.MaxBytes) {
+		s.remove(s.order.Back())
+		s.stats.Evictions++
+	}
+}
+
+// remove discards the document of element. The store must be locked.
+func (s *DocumentStore) remove(element *list.Element) {
+	document := s.order.Remove(element).(*storedDocument)
+	delete(s.entries, document.key)
+	s.stats.Entries--
+	s.stats.Bytes -= document.size
+}
+
+// fetchStored is Fetch for a Loader configured WithDocumentStore.
+func (l *Loader) fetchStored(ctx context.Context, url *url.URL) (*Response, error) {
+	key, ok := l.documents.key(url)
+	if !ok {
+		return l.fetch(ctx, url)
+	}
+
+	document, fresh := l.documents.lookup(key)
+	if fresh {
+		return copyResponse(document.response), nil
+	}
+	if document != nil {
+		ctx = context.WithValue(ctx, conditionalKey{}, document.state)
+	}
+	resp, err := l.fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && document != nil {
+		l.documents.revalidated(document)
+		return copyResponse(document.response), nil
+	}
+	l.documents.store(key, resp, storable(resp))
+	return resp, nil
+}
+
+// copyResponse copies resp, along with its slices and headers, so that the copy can be modified
+// without affecting resp. The documents are shared.
+func copyResponse(resp *Response) *Response {
+	copied := *resp
+	copied.Header = resp.Header.Clone()
+	copied.Redirects = append([]Redirect(nil), resp.Redirects...)
+	copied.Frames = append([]*Frame(nil), resp.Frames...)
+	if resp.Body != nil {
+		copied.Body = append([]byte(nil), resp.Body...)
+	}
+	return &copied
+}
+
+// storable reports whether resp may be kept by a DocumentStore.
+func storable(resp *Response) bool {
+	if resp.StatusCode != http.StatusOK || resp.Language != "" || resp.Version != VersionCanonical {
+		return false
+	}
+	for _, redirect := range resp.Redirects {
+		if redirect.Kind != RedirectHttp {
+			return false
+		}
+	}
+	for _, directive := range strings.Split(resp.Header.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-store") {
+			return false
+		}
+	}
+	return true
+}
+
+// responseSize estimates the memory held by resp and its frames.
+func responseSize(resp *Response) int64 {
+	size := int64(len(resp.Body) + len(resp.Text))
+	if resp.Root != nil {
+		size += documentSize(resp.Root)
+	}
+	for _, frame := range resp.Frames {
+		if frame.Response != nil {
+			size += responseSize(frame.Response)
+		}
+	}
+	return size
+}
+
+// documentSize estimates the memory held by the tree at root.
+func documentSize(root *html.Node) int64 {
+	size := int64(documentNodeSize + len(root.Data) + len(root.Namespace))
+	for _, attr := range root.Attr {
+		size += int64(documentAttributeSize + len(attr.Namespace) + len(attr.Key) + len(attr.Val))
+	}
+	for child := root.FirstChild; child != nil; child = child.NextSibling {
+		size += documentSize(child)
+	}
+	return size
+}
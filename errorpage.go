@@ -0,0 +1,119 @@
+package restify
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ErrorPageReason describes why IsErrorPage flagged a page.
+type ErrorPageReason string
+
+const (
+	// ErrorPageStatus means the page responded with an error status
+	ErrorPageStatus ErrorPageReason = "status"
+	// ErrorPageBlocked means the page asks the visitor to prove they are human, or says that
+	// access was denied
+	ErrorPageBlocked ErrorPageReason = "blocked"
+	// ErrorPageTitle means the title of the page says that it was not found or failed
+	ErrorPageTitle ErrorPageReason = "title"
+	// ErrorPageText means the short text of the page says that it was not found
+	ErrorPageText ErrorPageReason = "text"
+	// ErrorPageRedirect means the page redirected to the home page of its site, as sites often
+	// do for pages that no longer exist
+	ErrorPageRedirect ErrorPageReason = "redirect"
+	// ErrorPageCanonical means the canonical URL of the page is the home page of its site or an
+	// error page, rather than the page itself
+	ErrorPageCanonical ErrorPageReason = "canonical"
+	// ErrorPageEmpty means the page has almost no text
+	ErrorPageEmpty ErrorPageReason = "empty"
+)
+
+// minPageWords is the number of words of visible text below which a page without images is
+// considered empty.
+const minPageWords = 10
+
+// maxErrorTextWords is the number of words of visible text above which the text of a page is not
+// searched for error phrases, since long pages that mention them are usually about something else.
+const maxErrorTextWords = 150
+
+var (
+	// titles starting with a status code, such as "404 Best Lists", are only errors when the
+	// code stands alone or is followed or preceded by an error phrase
+	errorTitlePattern = regexp.MustCompile(`(?i)^\W*(?:http\s*)?(?:40[0-9]|41[0-9]|5[0-9][0-9])\W*(?:$|` +
+		`(?:error|not\s+found|forbidden|unauthori[sz]ed|gone|bad\s+(?:request|gateway)|` +
+		`(?:service|temporarily)\s+unavailable|gateway\s+time-?out)\b)|` +
+		`\berror\s*:?\s*(?:40[0-9]|41[0-9]|5[0-9][0-9])\b|` +
+		`\bnot\s+found\b|\bpage\s+(?:can(?:not|'t|’t)|could\s*n(?:o|')t)\s+be\s+found\b|` +
+		`\bdoes\s*n(?:o|'|’)t\s+exist\b|\bno\s+longer\s+(?:exists|available)\b|` +
+		`\bpage\s+(?:unavailable|missing)\b|\bsomething\s+went\s+wrong\b|\binternal\s+server\s+error\b`)
+	errorTextPattern = regexp.MustCompile(`(?i)\b(?:404|page)\b.{0,40}\bnot\s+(?:be\s+)?found\b|` +
+		`\bpage\s+(?:you(?:'re|’re|\s+are)\s+looking\s+for|you\s+requested)\b.{0,60}` +
+		`\b(?:does\s*n(?:o|'|’)t\s+exist|not\s+(?:be\s+)?found|(?:has\s+been|was)\s+(?:moved|removed|deleted))|` +
+		`\bno\s+longer\s+(?:exists|available)\b`)
+	blockedPattern = regexp.MustCompile(`(?i)\bcaptcha\b|\bare\s+you\s+(?:a\s+)?(?:human|robot)\b|` +
+		`\bverify(?:ing)?\s+(?:that\s+)?you\s+are\s+(?:a\s+)?human\b|\bunusual\s+traffic\b|` +
+		`\baccess\s+(?:to\s+this\s+page\s+(?:has\s+been|is)\s+)?denied\b|\brequest\s+(?:was\s+)?blocked\b`)
+	errorPathPattern = regexp.MustCompile(`(?i)(?:^|[/_.-])(?:404|not-?found|error)(?:$|[/_.-])`)
+)
+
+// IsErrorPage uses heuristics to detect pages that are not the content requested even though
+// they may have responded successfully, such as soft 404s and pages blocking automated visitors,
+// so that their content is not stored. The resp the page at root was retrieved with is
+// optional, but without it fewer heuristics apply.
+//
+// A page is an error page if it responded with an error status; it asks the visitor to solve
+// a CAPTCHA or says access was denied; its title says it was not found; its text is short and
+// says it was not found; it redirected, or declares its canonical URL to be, the home page of
+// its site or an error page; or it is HTML with almost no text and no images. The reason is
+// the first of these that applies.
+func IsErrorPage(root *html.Node, resp *Response) (bool, ErrorPageReason) {
+	if resp != nil && resp.StatusCode >= 400 {
+		return true, ErrorPageStatus
+	}
+
+	title := ""
+	if n, ok := scrape.Find(root, scrape.ByTag(atom.Title)); ok {
+		title = strings.Join(strings.Fields(scrape.Text(n)), " ")
+	}
+	text := visibleText(root)
+	words := len(strings.Fields(text))
+
+	if words <= maxErrorTextWords && (blockedPattern.MatchString(title) || blockedPattern.MatchString(text)) {
+		return true, ErrorPageBlocked
+	}
+	if errorTitlePattern.MatchString(title) {
+		return true, ErrorPageTitle
+	}
+	if words <= maxErrorTextWords && errorTextPattern.MatchString(text) {
+		return true, ErrorPageText
+	}
+
+	if resp != nil && resp.URL != nil {
+		if len(resp.Redirects) > 0 && isHomePath(resp.URL.Path) && resp.Redirects[0].URL != nil &&
+			!isHomePath(resp.Redirects[0].URL.Path) {
+			return true, ErrorPageRedirect
+		}
+		canonical := CanonicalURL(root, resp.URL)
+		if !isHomePath(resp.URL.Path) && canonical.Host == resp.URL.Host &&
+			(isHomePath(canonical.Path) || errorPathPattern.MatchString(canonical.Path)) {
+			return true, ErrorPageCanonical
+		}
+	}
+
+	isHtml := resp == nil || resp.Kind == ContentHtml || resp.Kind == ContentXhtml || resp.Kind == ""
+	if isHtml && words < minPageWords {
+		if _, hasImage := scrape.Find(root, scrape.ByTag(atom.Img)); !hasImage {
+			return true, ErrorPageEmpty
+		}
+	}
+	return false, ""
+}
+
+// isHomePath reports whether a URL path is that of the home page of a site.
+func isHomePath(path string) bool {
+	return path == "" || path == "/" || strings.EqualFold(path, "/index.html")
+}
@@ -0,0 +1,206 @@
+package restify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+)
+
+// maxNearMisses limits how many near-miss candidates are reported per selector group.
+const maxNearMisses = 5
+
+// Explanation reports how a selector was evaluated against a document. It is intended to help
+// work out why a selector matches nothing, such as after a site changes its markup.
+type Explanation struct {
+	// Selector is the selector that was explained
+	Selector string
+	// Steps reports the number of matches of each successively longer portion of the selector
+	Steps []ExplainStep
+	// NearMisses are elements that almost matched the first step that matched nothing
+	NearMisses []NearMiss
+}
+
+// ExplainStep is the result of evaluating a portion of a selector.
+type ExplainStep struct {
+	// Selector is the portion of the selector, from its start, evaluated by this step
+	Selector string
+	// Matched is the number of elements matched, including those nested within other matches
+	Matched int
+}
+
+// NearMiss is an element that failed only some of the conditions of a selector step.
+type NearMiss struct {
+	// Node is the element that almost matched
+	Node *html.Node
+	// Step is the portion of the selector that the element failed
+	Step string
+	// Reasons describe each condition that the element failed
+	Reasons []string
+}
+
+// Explain evaluates the given CSS selector against root, reporting how many elements matched
+// each step of the selector and, for the first step that matched nothing, which elements came
+// closest along with the reasons they failed.
+func Explain(root *html.Node, selector string) (*Explanation, error) {
+	s, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	elements := scrape.FindAllNested(root, func(n *html.Node) bool {
+		return n.Type == html.ElementNode
+	})
+
+	explanation := &Explanation{Selector: selector}
+	for g := range s.groups {
+		group := &s.groups[g]
+		for step := range group.steps {
+			matched := 0
+			for _, n := range elements {
+				if group.match(n, step) {
+					matched++
+				}
+			}
+			explanation.Steps = append(explanation.Steps, ExplainStep{
+				Selector: group.prefix(step),
+				Matched:  matched,
+			})
+
+			if matched == 0 {
+				explanation.NearMisses = append(explanation.NearMisses, group.nearMisses(elements, step)...)
+				break
+			}
+		}
+	}
+
+	return explanation, nil
+}
+
+// nearMisses finds the elements that fail the fewest conditions of the given step, as long as
+// they satisfy at least one of them.
+func (c *complexSelector) nearMisses(elements []*html.Node, step int) []NearMiss {
+	compound := &c.steps[step]
+	conditions := len(compound.simples)
+	if step > 0 {
+		conditions++
+	}
+
+	var best []NearMiss
+	fewest := conditions
+	for _, n := range elements {
+		var reasons []string
+		for i := range compound.simples {
+			simple := &compound.simples[i]
+			if !simple.match(n) {
+				reasons = append(reasons, simple.explainMiss(n))
+			}
+		}
+		if step > 0 && !c.related(n, step) {
+			relation := "descendant"
+			if c.combinators[step] == '>' {
+				relation = "child"
+			}
+			reasons = append(reasons, fmt.Sprintf("is not a %s of %q", relation, c.prefix(step-1)))
+		}
+
+		switch {
+		case len(reasons) == 0 || len(reasons) > fewest:
+			continue
+		case len(reasons) < fewest:
+			fewest = len(reasons)
+			best = best[:0]
+		}
+		if len(best) < maxNearMisses {
+			best = append(best, NearMiss{Node: n, Step: c.prefix(step), Reasons: reasons})
+		}
+	}
+
+	if fewest == conditions {
+		// nothing satisfied any condition, so none of these are meaningfully near
+		return nil
+	}
+	return best
+}
+
+// related reports whether node has the relationship to the previous step required by the
+// combinator preceding step.
+func (c *complexSelector) related(node *html.Node, step int) bool {
+	if c.combinators[step] == '>' {
+		return node.Parent != nil && c.match(node.Parent, step-1)
+	}
+	for ancestor := node.Parent; ancestor != nil; ancestor = ancestor.Parent {
+		if c.match(ancestor, step-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// explainMiss describes why node fails s, noting when a sibling would have satisfied it.
+func (s *simpleSelector) explainMiss(node *html.Node) string {
+	var reason string
+	switch s.kind {
+	case simpleTag:
+		return fmt.Sprintf("is a <%s> rather than a <%s>", node.Data, s.name)
+
+	case simpleId:
+		if id := scrape.Attr(node, "id"); id != "" {
+			reason = fmt.Sprintf("has id %q rather than %q", id, s.name)
+		} else {
+			reason = fmt.Sprintf("lacks id %q", s.name)
+		}
+
+	case simpleClass:
+		reason = fmt.Sprintf("lacks class %q", s.name)
+
+	default:
+		if value, ok := attrValue(node, s.name); ok && s.op == AttributeEquals {
+			reason = fmt.Sprintf("has %s=%q rather than %q", s.name, value, s.value)
+		} else if ok {
+			reason = fmt.Sprintf("has %s=%q, which fails %s%q", s.name, value, s.op, s.value)
+		} else {
+			reason = fmt.Sprintf("lacks attribute %s", s.name)
+		}
+	}
+
+	if node.Parent != nil {
+		for sibling := node.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+			if sibling != node && sibling.Type == html.ElementNode && s.match(sibling) {
+				return fmt.Sprintf("%s, which is present on sibling %s", reason, describeNode(sibling))
+			}
+		}
+	}
+	return reason
+}
+
+// describeNode renders an element briefly, such as <div#main.card.wide>, for use in messages.
+func describeNode(node *html.Node) string {
+	var b strings.Builder
+	b.WriteString("<")
+	b.WriteString(node.Data)
+	if id := scrape.Attr(node, "id"); id != "" {
+		b.WriteString("#")
+		b.WriteString(id)
+	}
+	for _, c := range strings.Fields(scrape.Attr(node, "class")) {
+		b.WriteString(".")
+		b.WriteString(c)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// String renders the explanation as human-readable text.
+func (e *Explanation) String() string {
+	var b strings.Builder
+	for _, step := range e.Steps {
+		fmt.Fprintf(&b, "%s: %d matched\n", step.Selector, step.Matched)
+	}
+	for _, miss := range e.NearMisses {
+		fmt.Fprintf(&b, "  near miss for %s: %s %s\n", miss.Step, describeNode(miss.Node),
+			strings.Join(miss.Reasons, "; "))
+	}
+	return b.String()
+}
@@ -0,0 +1,276 @@
+package restify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ErrNoFavicon is returned by Loader.VerifyFavicon when none of the icons of a page exist.
+var ErrNoFavicon = errors.New("no favicon found")
+
+// ErrNoManifest is returned by Loader.LoadManifest when a page does not link to a web app
+// manifest.
+var ErrNoManifest = errors.New("no manifest linked")
+
+// appleTouchIconSize is the size assumed of apple-touch-icon links without sizes, which is the
+// size iOS uses.
+const appleTouchIconSize = 180
+
+// Icon is an icon of a site, as declared by a page or its web app manifest.
+type Icon struct {
+	// URL locates the image
+	URL *url.URL
+	// Rel is how the icon was declared: "icon", "apple-touch-icon", or "manifest"
+	Rel string
+	// Type is the declared media type of the image, if any
+	Type string
+	// Size is the width of the largest size declared, or zero if unknown
+	Size int
+	// Scalable is set when the image is declared to be of any size, as vector images are
+	Scalable bool
+}
+
+// Icons finds the icons declared by <link> elements of the page at root, which was retrieved
+// from pageUrl: rel="icon" in its various forms, such as "shortcut icon", and apple-touch-icon.
+// They are ordered best first: largest first, with scalable icons after those of 64 pixels or
+// more, since previews usually want a raster image that is not tiny. Mask icons, which are
+// monochrome, are omitted.
+func Icons(root *html.Node, pageUrl *url.URL) []Icon {
+	var icons []Icon
+	links := scrape.FindAll(root, func(n *html.Node) bool {
+		return n.DataAtom == atom.Link && (hasRel(n, "icon") || hasRel(n, "apple-touch-icon") ||
+			hasRel(n, "apple-touch-icon-precomposed"))
+	})
+	for _, link := range links {
+		href := strings.TrimSpace(scrape.Attr(link, "href"))
+		if href == "" {
+			continue
+		}
+		iconUrl, ok := resolveReference(pageUrl, href)
+		if !ok {
+			continue
+		}
+		icon := Icon{URL: iconUrl, Rel: "icon", Type: scrape.Attr(link, "type")}
+		icon.Size, icon.Scalable = parseIconSizes(scrape.Attr(link, "sizes"))
+		if !hasRel(link, "icon") {
+			icon.Rel = "apple-touch-icon"
+			if icon.Size == 0 && !icon.Scalable {
+				icon.Size = appleTouchIconSize
+			}
+		}
+		if icon.Type == "image/svg+xml" || strings.HasSuffix(strings.ToLower(iconUrl.Path), ".svg") {
+			icon.Scalable = true
+		}
+		icons = append(icons, icon)
+	}
+	sortIcons(icons)
+	return icons
+}
+
+// ResolveFavicon chooses the best icon declared by the page at root, which was retrieved from
+// pageUrl, as ordered by Icons. When the page declares none, the /favicon.ico that browsers
+// fall back to is used, though it may not exist; use Loader.VerifyFavicon to check.
+func ResolveFavicon(root *html.Node, pageUrl *url.URL) *url.URL {
+	if icons := Icons(root, pageUrl); len(icons) > 0 {
+		return icons[0].URL
+	}
+	return defaultFavicon(pageUrl)
+}
+
+// VerifyFavicon chooses the best icon of the page at root, which was retrieved from pageUrl,
+// that exists, as checked by a HEAD request. The icons declared by the page are tried in the
+// order of Icons, and then /favicon.ico. If none exist, ErrNoFavicon is returned.
+func (l *Loader) VerifyFavicon(ctx context.Context, root *html.Node, pageUrl *url.URL) (*url.URL, error) {
+	candidates := []*url.URL{}
+	for _, icon := range Icons(root, pageUrl) {
+		candidates = append(candidates, icon.URL)
+	}
+	candidates = append(candidates, defaultFavicon(pageUrl))
+
+	for _, candidate := range candidates {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if status, err := l.probe(ctx, candidate); err == nil && status < 400 {
+			return candidate, nil
+		}
+	}
+	return nil, ErrNoFavicon
+}
+
+// defaultFavicon is the location browsers request an icon from when a page declares none.
+func defaultFavicon(pageUrl *url.URL) *url.URL {
+	return &url.URL{Scheme: pageUrl.Scheme, User: pageUrl.User, Host: pageUrl.Host, Path: "/favicon.ico"}
+}
+
+// SiteName finds the name of the site that the page at root belongs to, as declared by its
+// og:site_name, application-name, or apple-mobile-web-app-title meta tags, or empty if none.
+// Use Loader.LoadManifest for the name declared by the site's web app manifest.
+func SiteName(root *html.Node) string {
+	for _, property := range []string{"og:site_name", "application-name", "apple-mobile-web-app-title"} {
+		if meta, ok := scrape.Find(root, matchMetaProperty(property)); ok {
+			if name := strings.TrimSpace(scrape.Attr(meta, "content")); name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// WebManifest is the web app manifest of a site, as linked by <link rel="manifest">.
+type WebManifest struct {
+	// URL is the location the manifest was loaded from, against which its icons are resolved
+	URL *url.URL `json:"-"`
+	// Name is the full name of the site
+	Name string `json:"name"`
+	// ShortName is the name of the site for where there is little space
+	ShortName string `json:"short_name"`
+	// Description describes the site
+	Description string `json:"description"`
+	// ThemeColor is the color of the site's interface, such as "#ff0000"
+	ThemeColor string `json:"theme_color"`
+	// Icons are the icons as declared by the manifest
+	Icons []ManifestIcon `json:"icons"`
+}
+
+// ManifestIcon is an icon as declared by a web app manifest.
+type ManifestIcon struct {
+	// Src is the location of the image, relative to the manifest
+	Src string `json:"src"`
+	// Sizes are the sizes of the image, such as "48x48 96x96" or "any"
+	Sizes string `json:"sizes"`
+	// Type is the media type of the image
+	Type string `json:"type"`
+}
+
+// ResolveIcons returns the manifest's icons with their URLs resolved, ordered best first as
+// with the Icons function.
+func (m *WebManifest) ResolveIcons() []Icon {
+	var icons []Icon
+	for _, raw := range m.Icons {
+		if strings.TrimSpace(raw.Src) == "" {
+			continue
+		}
+		iconUrl, ok := resolveReference(m.URL, raw.Src)
+		if !ok {
+			continue
+		}
+		icon := Icon{URL: iconUrl, Rel: "manifest", Type: raw.Type}
+		icon.Size, icon.Scalable = parseIconSizes(raw.Sizes)
+		icons = append(icons, icon)
+	}
+	sortIcons(icons)
+	return icons
+}
+
+// LoadManifest loads the web app manifest linked by the page at root, which was retrieved from
+// pageUrl. If the page does not link to one, ErrNoManifest is returned.
+func (l *Loader) LoadManifest(ctx context.Context, root *html.Node, pageUrl *url.URL) (*WebManifest, error) {
+	link, ok := scrape.Find(root, matchLinkRel("manifest"))
+	if !ok || strings.TrimSpace(scrape.Attr(link, "href")) == "" {
+		return nil, ErrNoManifest
+	}
+	manifestUrl, ok := resolveReference(pageUrl, scrape.Attr(link, "href"))
+	if !ok {
+		return nil, ErrNoManifest
+	}
+
+	resp, err := l.Fetch(ctx, manifestUrl)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("Manifest responded with status %d", resp.StatusCode)
+	}
+	if resp.Kind != ContentJson {
+		return nil, fmt.Errorf("Manifest has content type %q rather than JSON", resp.ContentType)
+	}
+	// the content has already been decoded generically, so is re-encoded into the manifest
+	content, err := json.Marshal(resp.Json)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode manifest: %w", err)
+	}
+	manifest := &WebManifest{URL: resp.URL}
+	if err := json.Unmarshal(content, manifest); err != nil {
+		return nil, fmt.Errorf("Failed to decode manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// probe requests the headers of target, falling back to GET for servers that do not support
+// HEAD, and returns the status of the response.
+func (l *Loader) probe(ctx context.Context, target *url.URL) (int, error) {
+	var status int
+	for _, method := range []string{"HEAD", "GET"} {
+		request, err := http.NewRequestWithContext(ctx, method, target.String(), nil)
+		if err != nil {
+			return 0, fmt.Errorf("Failed to request: %w", err)
+		}
+		l.applyProfile(request)
+		if userAgent := l.nextUserAgent(); userAgent != "" {
+			request.Header.Set("user-agent", userAgent)
+		}
+		for _, config := range l.configs {
+			config(request)
+		}
+
+		resp, err := l.client.Do(request)
+		if err != nil {
+			return 0, fmt.Errorf("Failed to retrieve response: %w", err)
+		}
+		// drain a little of the body so the connection can be reused
+		//goland:noinspection GoUnhandledErrorResult
+		io.CopyN(ioutil.Discard, resp.Body, 4096)
+		//goland:noinspection GoUnhandledErrorResult
+		resp.Body.Close()
+
+		status = resp.StatusCode
+		if status != http.StatusMethodNotAllowed && status != http.StatusNotImplemented {
+			break
+		}
+	}
+	return status, nil
+}
+
+// parseIconSizes parses a sizes attribute such as "16x16 32x32" or "any", returning the width
+// of the largest size and whether any size is allowed.
+func parseIconSizes(sizes string) (size int, scalable bool) {
+	for _, token := range strings.Fields(strings.ToLower(sizes)) {
+		if token == "any" {
+			scalable = true
+			continue
+		}
+		width, err := strconv.Atoi(strings.SplitN(token, "x", 2)[0])
+		if err == nil && width > size {
+			size = width
+		}
+	}
+	return size, scalable
+}
+
+// sortIcons orders icons best first, as described by Icons.
+func sortIcons(icons []Icon) {
+	// scalable icons rank as if they were 64 pixels, between small and large raster icons
+	rank := func(icon Icon) int {
+		if icon.Size == 0 && icon.Scalable {
+			return 63
+		}
+		return icon.Size
+	}
+	sort.SliceStable(icons, func(i, j int) bool {
+		return rank(icons[i]) > rank(icons[j])
+	})
+}
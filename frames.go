@@ -0,0 +1,145 @@
+package restify
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// FrameMode selects how a Loader handles the documents embedded by <iframe> elements.
+type FrameMode string
+
+const (
+	// FramesIgnore leaves <iframe> elements as they are
+	FramesIgnore FrameMode = ""
+	// FramesInline loads each frame and moves its <html> element into the <iframe> element, in
+	// place of the iframe's fallback content, so that the frame can be searched as part of the
+	// page. Frames responding with an error status are not inlined.
+	FramesInline FrameMode = "inline"
+	// FramesDocuments loads each frame as a separate document, reported in Response.Frames
+	FramesDocuments FrameMode = "documents"
+)
+
+// maxFrameDepth limits how deeply frames within frames are loaded.
+const maxFrameDepth = 3
+
+// Frame is a document embedded within a page by an <iframe> element.
+type Frame struct {
+	// Element is the <iframe> element of the parent document
+	Element *html.Node
+	// URL is the location of the frame, or nil if its content was given by a srcdoc attribute
+	URL *url.URL
+	// Response is the loaded frame, which has its own Frames, or nil if Err is set
+	Response *Response
+	// Err is set if the frame could not be loaded
+	Err error
+}
+
+// WithFrames loads the documents embedded by the <iframe> elements of each page, with the same
+// configuration, as selected by mode. Embedded widgets often hold the content of interest.
+// Frames within frames are loaded too, up to a few levels deep. Frames given by a srcdoc
+// attribute are parsed from it rather than loaded, and frames whose src is empty, about:blank,
+// or not an http or https URL are skipped. File URLs are loaded only as frames of pages that are
+// file URLs themselves.
+func WithFrames(mode FrameMode) LoaderOption {
+	return func(l *Loader) {
+		l.frameMode = mode
+	}
+}
+
+type frameDepthKey struct{}
+
+type refererKey struct{}
+
+// originKey holds the URL of the page that led to a load, such as by embedding it as a frame.
+type originKey struct{}
+
+// origin returns the URL of the page that led to the load bound to ctx, or nil if there is none.
+func origin(ctx context.Context) *url.URL {
+	from, _ := ctx.Value(originKey{}).(*url.URL)
+	return from
+}
+
+// FindFrames locates the <iframe> elements within root that embed a document, along with the
+// location of each resolved against pageUrl. The URL of a srcdoc frame is nil. Frames must be
+// http or https URLs, or file URLs when pageUrl is a file URL too.
+func FindFrames(root *html.Node, pageUrl *url.URL) []Frame {
+	var frames []Frame
+	for _, iframe := range scrape.FindAllNested(root, scrape.ByTag(atom.Iframe)) {
+		if _, ok := attrValue(iframe, "srcdoc"); ok {
+			frames = append(frames, Frame{Element: iframe})
+			continue
+		}
+
+		src := strings.TrimSpace(scrape.Attr(iframe, "src"))
+		if src == "" || strings.EqualFold(src, "about:blank") {
+			continue
+		}
+		frameUrl, ok := resolveReference(pageUrl, src)
+		if !ok || !followable(pageUrl, frameUrl) {
+			continue
+		}
+		frames = append(frames, Frame{Element: iframe, URL: frameUrl})
+	}
+	return frames
+}
+
+// loadFrames loads the frames of resp according to the Loader's frame mode. Failures to load
+// a frame are reported by the frame, leaving the <iframe> element as it was.
+func (l *Loader) loadFrames(ctx context.Context, resp *Response) {
+	if resp.Kind != ContentHtml && resp.Kind != ContentXhtml {
+		return
+	}
+	depth, _ := ctx.Value(frameDepthKey{}).(int)
+	if depth >= maxFrameDepth {
+		return
+	}
+	ctx = context.WithValue(ctx, frameDepthKey{}, depth+1)
+	ctx = context.WithValue(ctx, refererKey{}, resp.URL.String())
+	ctx = context.WithValue(ctx, originKey{}, resp.URL)
+
+	for _, frame := range FindFrames(resp.Root, resp.URL) {
+		frame := frame
+		if frame.URL == nil {
+			srcdoc, _ := attrValue(frame.Element, "srcdoc")
+			root, err := html.Parse(strings.NewReader(srcdoc))
+			if err != nil {
+				frame.Err = fmt.Errorf("Failed to parse frame: %w", err)
+			} else {
+				frame.Response = &Response{Root: root, URL: resp.URL, Header: resp.Header, Kind: ContentHtml}
+				l.loadFrames(ctx, frame.Response)
+			}
+		} else if frame.URL.String() == resp.URL.String() {
+			continue
+		} else {
+			frame.Response, frame.Err = l.Fetch(ctx, frame.URL)
+		}
+
+		if l.frameMode == FramesInline {
+			if frame.Err == nil && frame.Response.StatusCode < 400 {
+				inlineFrame(frame.Element, frame.Response.Root)
+			}
+			continue
+		}
+		resp.Frames = append(resp.Frames, &frame)
+	}
+}
+
+// inlineFrame replaces the fallback content of iframe with the <html> element of the frame's
+// document.
+func inlineFrame(iframe *html.Node, root *html.Node) {
+	htmlElem, ok := scrape.Find(root, scrape.ByTag(atom.Html))
+	if !ok {
+		return
+	}
+	for child := iframe.FirstChild; child != nil; child = iframe.FirstChild {
+		iframe.RemoveChild(child)
+	}
+	htmlElem.Parent.RemoveChild(htmlElem)
+	iframe.AppendChild(htmlElem)
+}
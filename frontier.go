@@ -0,0 +1,106 @@
+package restify
+
+import "sync"
+
+// CrawlRequest is a URL waiting in a crawl frontier.
+type CrawlRequest struct {
+	// URL is the normalized location to crawl
+	URL string `json:"url"`
+	// Depth is the number of links followed from a seed URL, which has a depth of zero
+	Depth int `json:"depth"`
+	// Referrer is the URL of the page the link was found on, empty for seed URLs
+	Referrer string `json:"referrer,omitempty"`
+}
+
+// FrontierStore holds the queue of requests waiting to be crawled. Implementations backed by
+// external storage, such as Bolt or Redis, allow a crawl's queue to outlive the process.
+type FrontierStore interface {
+	// Push adds a request to the queue.
+	Push(request CrawlRequest) error
+	// Pop removes and returns the next request. If the queue is empty, then ok will be false.
+	Pop() (request CrawlRequest, ok bool, err error)
+	// Len reports the number of queued requests.
+	Len() (int, error)
+}
+
+// SharedFrontier is a FrontierStore shared by several crawlers, such as workers in separate
+// processes, which tracks the requests they have popped but not yet finished. A Crawler whose
+// queue is empty keeps waiting while others have requests pending, since crawling them may
+// queue more, rather than stopping while the crawl goes on.
+type SharedFrontier interface {
+	FrontierStore
+	// Done records that a popped request was crawled and its links pushed.
+	Done(request CrawlRequest) error
+	// Pending reports the number of requests popped by any crawler that are not yet done.
+	Pending() (int, error)
+}
+
+// MemoryFrontier is a first-in first-out FrontierStore held in memory, so that pages are crawled
+// breadth-first. It is safe for concurrent use.
+type MemoryFrontier struct {
+	mu    sync.Mutex
+	queue []CrawlRequest
+}
+
+// NewMemoryFrontier creates an empty MemoryFrontier.
+func NewMemoryFrontier() *MemoryFrontier {
+	return &MemoryFrontier{}
+}
+
+// Push implements FrontierStore.
+func (f *MemoryFrontier) Push(request CrawlRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queue = append(f.queue, request)
+	return nil
+}
+
+// Pop implements FrontierStore.
+func (f *MemoryFrontier) Pop() (CrawlRequest, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.queue) == 0 {
+		return CrawlRequest{}, false, nil
+	}
+	request := f.queue[0]
+	f.queue[0] = CrawlRequest{}
+	f.queue = f.queue[1:]
+	return request, true, nil
+}
+
+// Len implements FrontierStore.
+func (f *MemoryFrontier) Len() (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.queue), nil
+}
+
+// Deduper records which URLs a crawl has already queued, so that each is crawled once. Sharing
+// a Deduper and FrontierStore between processes, such as with the redisstore package, allows
+// several workers to cooperate on one crawl.
+type Deduper interface {
+	// MarkSeen records key, reporting whether it was newly added rather than already seen.
+	MarkSeen(key string) (added bool, err error)
+}
+
+// MemoryDeduper is a Deduper held in memory. It is safe for concurrent use.
+type MemoryDeduper struct {
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+// NewMemoryDeduper creates an empty MemoryDeduper.
+func NewMemoryDeduper() *MemoryDeduper {
+	return &MemoryDeduper{seen: make(map[string]bool)}
+}
+
+// MarkSeen implements Deduper.
+func (d *MemoryDeduper) MarkSeen(key string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.seen[key] {
+		return false, nil
+	}
+	d.seen[key] = true
+	return true, nil
+}
@@ -10,6 +10,7 @@ require (
 	github.com/stretchr/testify v1.4.0 // indirect
 	github.com/yhat/scrape v0.0.0-20161128144610-24b7890b0945
 	golang.org/x/net v0.20.0
+	golang.org/x/text v0.14.0
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
 )
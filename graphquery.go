@@ -0,0 +1,282 @@
+package restify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+)
+
+// GraphQuery is a parsed GraphQL-like description of the data to extract from a page. It is
+// experimental and its syntax may change. A query lists the fields to resolve, each with
+// arguments locating its value, and each optionally selecting nested fields:
+//
+//	{
+//	  title(selector: "h1")
+//	  products(selector: ".product", multiple: true) {
+//	    name(selector: ".name")
+//	    link(selector: "a", attr: "href")
+//	    tags(selector: ".tag", multiple: true)
+//	  }
+//	}
+//
+// The arguments of a field are:
+//
+//   - selector, a CSS selector as accepted by ParseSelector, locating the field among the
+//     descendants of the node resolving the enclosing field. When omitted, that node is used.
+//   - attr, naming an attribute whose value is used rather than the text.
+//   - multiple, resolving every match into a list rather than only the first.
+//
+// A field with nested fields resolves to an object, and otherwise to a string. Fields that
+// match nothing resolve to null, or an empty list if multiple. Comments start with #.
+type GraphQuery struct {
+	fields []graphField
+}
+
+type graphField struct {
+	name     string
+	selector *Selector
+	attr     string
+	multiple bool
+	fields   []graphField
+	// nested is true when the field has a selection set, even an empty one
+	nested bool
+}
+
+// ParseGraphQuery parses the given query, as described by GraphQuery.
+func ParseGraphQuery(query string) (*GraphQuery, error) {
+	p := &graphQueryParser{query: query}
+	p.skip()
+	// the outer braces are optional, as with GraphQL's query shorthand
+	braced := p.consume('{')
+	fields, err := p.parseFields(braced)
+	if err != nil {
+		return nil, err
+	}
+	if braced && !p.consume('}') {
+		return nil, p.errorf("expected }")
+	}
+	if p.pos < len(p.query) {
+		return nil, p.errorf("unexpected %q", p.query[p.pos])
+	}
+	return &GraphQuery{fields: fields}, nil
+}
+
+// Resolve evaluates the query against the document at root.
+func (q *GraphQuery) Resolve(root *html.Node) map[string]interface{} {
+	return resolveGraphFields(q.fields, root)
+}
+
+// ResolveGraphQuery parses query, as described by GraphQuery, and resolves it against the
+// document at root into JSON.
+func ResolveGraphQuery(root *html.Node, query string) ([]byte, error) {
+	q, err := ParseGraphQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(q.Resolve(root))
+}
+
+func resolveGraphFields(fields []graphField, node *html.Node) map[string]interface{} {
+	result := make(map[string]interface{}, len(fields))
+	for i := range fields {
+		result[fields[i].name] = fields[i].resolve(node)
+	}
+	return result
+}
+
+func (f *graphField) resolve(node *html.Node) interface{} {
+	matches := Select(node)
+	if f.selector != nil {
+		matches = matches.Find(f.selector.Matcher())
+	}
+
+	values := make([]interface{}, 0, matches.Len())
+	for _, match := range matches.Nodes {
+		values = append(values, f.value(match))
+		if !f.multiple {
+			break
+		}
+	}
+
+	if f.multiple {
+		return values
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+func (f *graphField) value(node *html.Node) interface{} {
+	switch {
+	case f.nested:
+		return resolveGraphFields(f.fields, node)
+	case f.attr != "":
+		value, _ := attrValue(node, f.attr)
+		return strings.TrimSpace(value)
+	default:
+		return scrape.Text(node)
+	}
+}
+
+// graphQueryParser parses the query syntax described by GraphQuery.
+type graphQueryParser struct {
+	query string
+	pos   int
+}
+
+func (p *graphQueryParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("Invalid query at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// skip passes over whitespace, commas, which GraphQL treats as whitespace, and comments.
+func (p *graphQueryParser) skip() {
+	for p.pos < len(p.query) {
+		switch c := p.query[p.pos]; {
+		case c == ',' || unicode.IsSpace(rune(c)):
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.query) && p.query[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *graphQueryParser) consume(c byte) bool {
+	if p.pos < len(p.query) && p.query[p.pos] == c {
+		p.pos++
+		p.skip()
+		return true
+	}
+	return false
+}
+
+func (p *graphQueryParser) parseName() string {
+	start := p.pos
+	for p.pos < len(p.query) {
+		c := p.query[p.pos]
+		if c != '_' && !unicode.IsLetter(rune(c)) && !(p.pos > start && unicode.IsDigit(rune(c))) {
+			break
+		}
+		p.pos++
+	}
+	name := p.query[start:p.pos]
+	p.skip()
+	return name
+}
+
+// parseFields parses fields until the end of the selection set, or of the query if not braced.
+func (p *graphQueryParser) parseFields(braced bool) ([]graphField, error) {
+	var fields []graphField
+	seen := make(map[string]bool)
+	for p.pos < len(p.query) && !(braced && p.query[p.pos] == '}') {
+		field, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		if seen[field.name] {
+			return nil, p.errorf("field %q is repeated", field.name)
+		}
+		seen[field.name] = true
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func (p *graphQueryParser) parseField() (graphField, error) {
+	field := graphField{name: p.parseName()}
+	if field.name == "" {
+		return field, p.errorf("expected a field name")
+	}
+
+	if p.consume('(') {
+		for !p.consume(')') {
+			if err := p.parseArgument(&field); err != nil {
+				return field, err
+			}
+		}
+	}
+
+	if p.consume('{') {
+		fields, err := p.parseFields(true)
+		if err != nil {
+			return field, err
+		}
+		if !p.consume('}') {
+			return field, p.errorf("expected } to end the fields of %q", field.name)
+		}
+		field.fields = fields
+		field.nested = true
+	}
+	return field, nil
+}
+
+func (p *graphQueryParser) parseArgument(field *graphField) error {
+	name := p.parseName()
+	if name == "" {
+		return p.errorf("expected an argument name or )")
+	}
+	if !p.consume(':') {
+		return p.errorf("expected : after argument %q", name)
+	}
+
+	switch name {
+	case "selector":
+		text, err := p.parseString()
+		if err != nil {
+			return err
+		}
+		if field.selector, err = ParseSelector(text); err != nil {
+			return fmt.Errorf("Invalid selector of field %q: %w", field.name, err)
+		}
+	case "attr":
+		text, err := p.parseString()
+		if err != nil {
+			return err
+		}
+		field.attr = text
+	case "multiple":
+		switch p.parseName() {
+		case "true":
+			field.multiple = true
+		case "false":
+			field.multiple = false
+		default:
+			return p.errorf("expected true or false for multiple")
+		}
+	default:
+		return p.errorf("unknown argument %q", name)
+	}
+	return nil
+}
+
+func (p *graphQueryParser) parseString() (string, error) {
+	if p.pos >= len(p.query) || p.query[p.pos] != '"' {
+		return "", p.errorf("expected a string")
+	}
+	end := p.pos + 1
+	for end < len(p.query) && p.query[end] != '"' {
+		if p.query[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	if end >= len(p.query) {
+		return "", p.errorf("unterminated string")
+	}
+	text, err := strconv.Unquote(p.query[p.pos : end+1])
+	if err != nil {
+		return "", p.errorf("invalid string: %v", err)
+	}
+	p.pos = end + 1
+	p.skip()
+	return text, nil
+}
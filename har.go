@@ -0,0 +1,340 @@
+package restify
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrNotRecorded is returned by a HarTransport when no recorded response matches a request.
+var ErrNotRecorded = errors.New("no recorded response")
+
+// Har is an HTTP Archive as described by http://www.softwareishard.com/blog/har-12-spec/
+// Only the fields needed to replay responses are modeled.
+type Har struct {
+	Log HarLog `json:"log"`
+}
+
+// HarLog is the root of the recorded HTTP Archive data.
+type HarLog struct {
+	Version string      `json:"version"`
+	Creator HarCreator  `json:"creator"`
+	Entries []*HarEntry `json:"entries"`
+}
+
+// HarCreator identifies the application that created the archive.
+type HarCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HarEntry is a single recorded request and response exchange.
+type HarEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HarRequest  `json:"request"`
+	Response        HarResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HarTimings  `json:"timings"`
+}
+
+// HarRequest is the request portion of a HarEntry.
+type HarRequest struct {
+	Method      string      `json:"method"`
+	Url         string      `json:"url"`
+	HttpVersion string      `json:"httpVersion"`
+	Headers     []HarHeader `json:"headers"`
+	QueryString []HarHeader `json:"queryString"`
+	Cookies     []HarHeader `json:"cookies"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+// HarResponse is the response portion of a HarEntry.
+type HarResponse struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HttpVersion string      `json:"httpVersion"`
+	Headers     []HarHeader `json:"headers"`
+	Cookies     []HarHeader `json:"cookies"`
+	Content     HarContent  `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+// HarHeader is a name and value pair used for headers, query parameters, and cookies.
+type HarHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HarContent is the body of a recorded response.
+type HarContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	// Encoding is "base64" when Text holds binary content
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HarTimings reports durations of the exchange in milliseconds.
+type HarTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// LoadHar reads the HTTP Archive file at the given path.
+func LoadHar(path string) (*Har, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open HAR file: %w", err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer file.Close()
+
+	return ReadHar(file)
+}
+
+// ReadHar decodes an HTTP Archive from the given reader.
+func ReadHar(reader io.Reader) (*Har, error) {
+	var har Har
+	if err := json.NewDecoder(reader).Decode(&har); err != nil {
+		return nil, fmt.Errorf("Failed to decode HAR: %w", err)
+	}
+	return &har, nil
+}
+
+// Save writes the archive as JSON to the file at the given path.
+func (h *Har) Save(path string) error {
+	content, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to encode HAR: %w", err)
+	}
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("Failed to write HAR file: %w", err)
+	}
+	return nil
+}
+
+// HarTransport is an http.RoundTripper that serves responses recorded in a Har rather than
+// using the network. Use it with WithTransport to test scraping logic deterministically.
+//
+// Requests are matched by method and full URL. When the same URL was recorded several times
+// the recordings are served in order, with the last one repeated once the others are used up.
+// Requests with no recording fail with ErrNotRecorded.
+type HarTransport struct {
+	mu      sync.Mutex
+	entries map[string][]*HarEntry
+	served  map[string]int
+}
+
+// NewHarTransport creates a HarTransport that replays the entries of the given archive.
+func NewHarTransport(har *Har) *HarTransport {
+	t := &HarTransport{
+		entries: make(map[string][]*HarEntry),
+		served:  make(map[string]int),
+	}
+	for _, entry := range har.Log.Entries {
+		key := harKey(entry.Request.Method, entry.Request.Url)
+		t.entries[key] = append(t.entries[key], entry)
+	}
+	return t
+}
+
+func harKey(method, url string) string {
+	return method + " " + url
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *HarTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	key := harKey(request.Method, request.URL.String())
+
+	t.mu.Lock()
+	candidates := t.entries[key]
+	if len(candidates) == 0 {
+		t.mu.Unlock()
+		return nil, ErrNotRecorded
+	}
+	index := t.served[key]
+	if index >= len(candidates) {
+		index = len(candidates) - 1
+	} else {
+		t.served[key] = index + 1
+	}
+	entry := candidates[index]
+	t.mu.Unlock()
+
+	return entry.Response.toHttp(request)
+}
+
+func (r *HarResponse) toHttp(request *http.Request) (*http.Response, error) {
+	body := []byte(r.Content.Text)
+	if r.Content.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(r.Content.Text)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode recorded body: %w", err)
+		}
+		body = decoded
+	}
+
+	header := make(http.Header)
+	for _, h := range r.Headers {
+		header.Add(h.Name, h.Value)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	protoMajor, protoMinor, ok := http.ParseHTTPVersion(r.HttpVersion)
+	if !ok {
+		protoMajor, protoMinor = 1, 1
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, r.StatusText),
+		StatusCode:    r.Status,
+		Proto:         fmt.Sprintf("HTTP/%d.%d", protoMajor, protoMinor),
+		ProtoMajor:    protoMajor,
+		ProtoMinor:    protoMinor,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       request,
+	}, nil
+}
+
+// HarRecorder captures the exchanges of a Loader configured WithHarRecorder so they can be
+// saved and later replayed with a HarTransport. It is safe for concurrent use.
+type HarRecorder struct {
+	mu      sync.Mutex
+	entries []*HarEntry
+}
+
+// NewHarRecorder creates an empty HarRecorder.
+func NewHarRecorder() *HarRecorder {
+	return &HarRecorder{}
+}
+
+// Wrap decorates the given transport so that its exchanges are recorded.
+func (r *HarRecorder) Wrap(transport http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		started := time.Now()
+		resp, err := transport.RoundTrip(request)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		//goland:noinspection GoUnhandledErrorResult
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read response for recording: %w", err)
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		r.record(request, resp, body, started, time.Since(started))
+		return resp, nil
+	})
+}
+
+func (r *HarRecorder) record(request *http.Request, resp *http.Response, body []byte, started time.Time, elapsed time.Duration) {
+	content := HarContent{
+		Size:     len(body),
+		MimeType: resp.Header.Get("Content-Type"),
+	}
+	if utf8.Valid(body) {
+		content.Text = string(body)
+	} else {
+		content.Text = base64.StdEncoding.EncodeToString(body)
+		content.Encoding = "base64"
+	}
+
+	millis := float64(elapsed) / float64(time.Millisecond)
+	entry := &HarEntry{
+		StartedDateTime: started,
+		Time:            millis,
+		Request: HarRequest{
+			Method:      request.Method,
+			Url:         request.URL.String(),
+			HttpVersion: request.Proto,
+			Headers:     harHeaders(request.Header),
+			QueryString: harHeaders(request.URL.Query()),
+			Cookies:     []HarHeader{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Response: HarResponse{
+			Status:      resp.StatusCode,
+			StatusText:  http.StatusText(resp.StatusCode),
+			HttpVersion: resp.Proto,
+			Headers:     harHeaders(resp.Header),
+			Cookies:     []HarHeader{},
+			Content:     content,
+			RedirectURL: resp.Header.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    len(body),
+		},
+		Timings: HarTimings{Wait: millis},
+	}
+
+	r.mu.Lock()
+	r.entries = append(r.entries, entry)
+	r.mu.Unlock()
+}
+
+// harHeaders flattens the given headers or query parameters, ordered by name so that
+// recordings are stable.
+func harHeaders(values map[string][]string) []HarHeader {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := []HarHeader{}
+	for _, name := range names {
+		for _, value := range values[name] {
+			headers = append(headers, HarHeader{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+// Har returns an archive of the exchanges recorded so far.
+func (r *HarRecorder) Har() *Har {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := make([]*HarEntry, len(r.entries))
+	copy(entries, r.entries)
+	return &Har{
+		Log: HarLog{
+			Version: "1.2",
+			Creator: HarCreator{Name: "restify", Version: "1"},
+			Entries: entries,
+		},
+	}
+}
+
+// Save writes the exchanges recorded so far as an HTTP Archive file at the given path.
+func (r *HarRecorder) Save(path string) error {
+	return r.Har().Save(path)
+}
+
+// roundTripperFunc adapts a function into an http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
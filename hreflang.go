@@ -0,0 +1,110 @@
+package restify
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HreflangDefault is the key of LanguageAlternates for the page shown to visitors whose
+// language has no variant of its own, as declared by hreflang="x-default".
+const HreflangDefault = "x-default"
+
+// LanguageAlternates are the language variants of a page, keyed by BCP-47 language tag
+// normalized as by NormalizeLanguageTag, or HreflangDefault.
+type LanguageAlternates map[string]*url.URL
+
+// Alternates finds the language variants of the page at root, which was retrieved from pageUrl,
+// as declared by <link rel="alternate" hreflang="..."> elements. When a language is declared
+// more than once, the first is used. Variants that are not http or https URLs are ignored.
+func Alternates(root *html.Node, pageUrl *url.URL) LanguageAlternates {
+	alternates := make(LanguageAlternates)
+	links := scrape.FindAll(root, func(n *html.Node) bool {
+		return n.DataAtom == atom.Link && hasRel(n, "alternate") && scrape.Attr(n, "hreflang") != ""
+	})
+	for _, link := range links {
+		tag := strings.TrimSpace(scrape.Attr(link, "hreflang"))
+		if strings.EqualFold(tag, HreflangDefault) {
+			tag = HreflangDefault
+		} else if tag = NormalizeLanguageTag(tag); tag == "" {
+			continue
+		}
+		if _, ok := alternates[tag]; ok {
+			continue
+		}
+		alternateUrl, ok := resolveReference(pageUrl, scrape.Attr(link, "href"))
+		if ok && (alternateUrl.Scheme == "http" || alternateUrl.Scheme == "https") {
+			alternates[tag] = alternateUrl
+		}
+	}
+	return alternates
+}
+
+// Best chooses the variant for the first of the given languages that has one. A variant
+// matches a language if their tags are the same, or failing that if their primary languages
+// are, so that "pt" matches "pt-BR" and "en-GB" matches "en". The tag of the chosen variant is
+// returned along with it. If no language has a variant, then ok will be false.
+func (a LanguageAlternates) Best(languages ...string) (alternateUrl *url.URL, tag string, ok bool) {
+	tags := make([]string, 0, len(a))
+	for tag := range a {
+		tags = append(tags, tag)
+	}
+	// shorter, less specific tags first, so that "pt" is preferred to "pt-PT" for "pt-BR"
+	sort.Slice(tags, func(i, j int) bool {
+		if len(tags[i]) != len(tags[j]) {
+			return len(tags[i]) < len(tags[j])
+		}
+		return tags[i] < tags[j]
+	})
+
+	for _, language := range languages {
+		language = NormalizeLanguageTag(language)
+		if language == "" {
+			continue
+		}
+		if alternateUrl, ok := a[language]; ok {
+			return alternateUrl, language, true
+		}
+		for _, tag := range tags {
+			if tag != HreflangDefault && primaryLanguage(tag) == primaryLanguage(language) {
+				return a[tag], tag, true
+			}
+		}
+	}
+	return nil, "", false
+}
+
+// WithPreferredLanguages loads the variant of each page in the first of the given languages,
+// BCP-47 tags such as "en" or "pt-BR", that the page declares as an alternate, instead of the
+// page itself. This avoids extracting from the wrong language of sites that serve one chosen
+// by location or by default. When the page declares no variant in the languages, is itself the
+// variant, or loading the variant fails, the page itself is used. Response.Language reports the
+// tag of the variant that was loaded.
+func WithPreferredLanguages(languages ...string) LoaderOption {
+	return func(l *Loader) {
+		l.preferredLanguages = append(l.preferredLanguages, languages...)
+	}
+}
+
+// preferLanguage swaps resp for its variant in the first of languages that can be loaded.
+func (l *Loader) preferLanguage(ctx context.Context, resp *Response, languages []string) *Response {
+	alternateUrl, tag, ok := Alternates(resp.Root, resp.URL).Best(languages...)
+	if !ok || alternateUrl.String() == resp.URL.String() {
+		return resp
+	}
+
+	var redirects []Redirect
+	alternate, err := l.fetchOnce(ctx, resp.URL, alternateUrl, &redirects)
+	if err != nil || alternate.StatusCode >= 400 {
+		return resp
+	}
+	alternate.Redirects = alternateRedirects(resp, redirects)
+	alternate.Version = resp.Version
+	alternate.Language = tag
+	return alternate
+}
@@ -3,17 +3,22 @@ package restify
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"golang.org/x/net/html"
-	"log"
 	"strings"
 )
 
 // ConvertHtmlToJson the given HTML nodes into JSON content where each
-// HTML node is represented by the JsonNode structure.
+// HTML node is represented by the JsonNode structure. Each of the nodes
+// must be an element or document, so an error is returned for others,
+// such as the text nodes of a fragment given by LoadFragment.
 func ConvertHtmlToJson(nodes []*html.Node) ([]byte, error) {
 	rootJsonNodes := make([]JsonNode, len(nodes))
 
 	for i, n := range nodes {
+		if n.Type != html.ElementNode && n.Type != html.DocumentNode {
+			return nil, fmt.Errorf("Unable to convert node %d to JSON as it is not an element or document", i)
+		}
 		rootJsonNodes[i].populateFrom(n)
 	}
 
@@ -46,15 +51,9 @@ func (n *JsonNode) populateFrom(htmlNode *html.Node) *JsonNode {
 
 	case html.DocumentNode:
 		break
-
-	default:
-		log.Fatal("Given node needs to be an element or document")
 	}
 
-	var textBuffer bytes.Buffer
-
 	if len(htmlNode.Attr) > 0 {
-		n.Attributes = make(map[string]string)
 		var a html.Attribute
 		for _, a = range htmlNode.Attr {
 			switch a.Key {
@@ -68,11 +67,26 @@ func (n *JsonNode) populateFrom(htmlNode *html.Node) *JsonNode {
 				n.Href = a.Val
 
 			default:
+				if n.Attributes == nil {
+					n.Attributes = make(map[string]string, len(htmlNode.Attr))
+				}
 				n.Attributes[a.Key] = a.Val
 			}
 		}
 	}
 
+	elementCount := 0
+	for e := htmlNode.FirstChild; e != nil; e = e.NextSibling {
+		if e.Type == html.ElementNode {
+			elementCount++
+		}
+	}
+	if elementCount > 0 {
+		n.Elements = make([]JsonNode, 0, elementCount)
+	}
+
+	// a single piece of text is used as is, and only several are joined in a pooled buffer
+	var textBuffer *bytes.Buffer
 	e := htmlNode.FirstChild
 	for e != nil {
 		switch e.Type {
@@ -80,26 +94,30 @@ func (n *JsonNode) populateFrom(htmlNode *html.Node) *JsonNode {
 			trimmed := strings.TrimSpace(e.Data)
 			if len(trimmed) > 0 {
 				// mimic HTML text normalizing
-				if textBuffer.Len() > 0 {
+				switch {
+				case n.Text == "":
+					n.Text = trimmed
+				case textBuffer == nil:
+					textBuffer = getBuffer()
+					textBuffer.WriteString(n.Text)
+					fallthrough
+				default:
 					textBuffer.WriteString(" ")
+					textBuffer.WriteString(trimmed)
 				}
-				textBuffer.WriteString(trimmed)
 			}
 
 		case html.ElementNode:
-			if n.Elements == nil {
-				n.Elements = make([]JsonNode, 0)
-			}
-			var jsonElemNode JsonNode
-			jsonElemNode.populateFrom(e)
-			n.Elements = append(n.Elements, jsonElemNode)
+			n.Elements = append(n.Elements, JsonNode{})
+			n.Elements[len(n.Elements)-1].populateFrom(e)
 		}
 
 		e = e.NextSibling
 	}
 
-	if textBuffer.Len() > 0 {
+	if textBuffer != nil {
 		n.Text = textBuffer.String()
+		putBuffer(textBuffer)
 	}
 
 	return n
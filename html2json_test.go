@@ -0,0 +1,24 @@
+package restify
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func BenchmarkConvertHtmlToJson(b *testing.B) {
+	root, err := html.Parse(bytes.NewReader(benchmarkPage(300)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	nodes := []*html.Node{root}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ConvertHtmlToJson(nodes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
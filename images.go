@@ -0,0 +1,257 @@
+package restify
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ImageCandidate is one of the image sources offered by a srcset attribute.
+type ImageCandidate struct {
+	// URL is the image location as written, which may be relative
+	URL string `json:"url"`
+	// Width is the value of a width descriptor, such as 640 for "640w", or zero if none
+	Width int `json:"width,omitempty"`
+	// Density is the value of a pixel density descriptor, such as 2 for "2x". It is 1 when
+	// neither a width nor a density is given.
+	Density float64 `json:"density,omitempty"`
+}
+
+// SourceSize is one entry of a sizes attribute.
+type SourceSize struct {
+	// Media is the media condition, such as "(max-width: 600px)", or empty for the default size
+	Media string `json:"media,omitempty"`
+	// Length is the CSS length of the image slot, such as "100vw" or "480px"
+	Length string `json:"length"`
+}
+
+// ParseSrcset parses the value of a srcset attribute into its candidates, following
+// https://html.spec.whatwg.org/multipage/images.html#parsing-a-srcset-attribute
+// Candidates with invalid descriptors are skipped.
+func ParseSrcset(srcset string) []ImageCandidate {
+	var candidates []ImageCandidate
+	s := srcset
+	for {
+		s = strings.TrimLeft(s, " \t\n\r\f,")
+		if s == "" {
+			return candidates
+		}
+
+		end := strings.IndexAny(s, " \t\n\r\f")
+		if end < 0 {
+			end = len(s)
+		}
+		url := s[:end]
+		s = s[end:]
+
+		var descriptors []string
+		if strings.HasSuffix(url, ",") {
+			url = strings.TrimRight(url, ",")
+		} else {
+			// descriptors run until a comma that is not within parentheses
+			depth := 0
+			i := 0
+		scan:
+			for ; i < len(s); i++ {
+				switch s[i] {
+				case '(':
+					depth++
+				case ')':
+					depth--
+				case ',':
+					if depth <= 0 {
+						break scan
+					}
+				}
+			}
+			descriptors = strings.Fields(s[:i])
+			s = s[i:]
+		}
+
+		if candidate, ok := parseCandidate(url, descriptors); ok {
+			candidates = append(candidates, candidate)
+		}
+	}
+}
+
+func parseCandidate(url string, descriptors []string) (ImageCandidate, bool) {
+	candidate := ImageCandidate{URL: url}
+	if url == "" {
+		return candidate, false
+	}
+	for _, d := range descriptors {
+		if len(d) < 2 {
+			return candidate, false
+		}
+		value := d[:len(d)-1]
+		switch d[len(d)-1] {
+		case 'w':
+			width, err := strconv.Atoi(value)
+			if err != nil || width <= 0 || candidate.Width != 0 || candidate.Density != 0 {
+				return candidate, false
+			}
+			candidate.Width = width
+		case 'x':
+			density, err := strconv.ParseFloat(value, 64)
+			if err != nil || density <= 0 || candidate.Width != 0 || candidate.Density != 0 {
+				return candidate, false
+			}
+			candidate.Density = density
+		case 'h':
+			// future-compatible height descriptor, ignored
+		default:
+			return candidate, false
+		}
+	}
+	if candidate.Width == 0 && candidate.Density == 0 {
+		candidate.Density = 1
+	}
+	return candidate, true
+}
+
+// ParseSizes parses the value of a sizes attribute into its entries, in order.
+func ParseSizes(sizes string) []SourceSize {
+	var entries []SourceSize
+	for _, entry := range splitOutsideParens(sizes) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		start := lastComponent(entry)
+		entries = append(entries, SourceSize{
+			Media:  strings.TrimSpace(entry[:start]),
+			Length: entry[start:],
+		})
+	}
+	return entries
+}
+
+// lastComponent finds the start of the final whitespace separated component of s, treating a
+// function such as calc(100vw - 2em) as a single component.
+func lastComponent(s string) int {
+	i := len(s)
+	if strings.HasSuffix(s, ")") {
+		depth := 0
+		for i = len(s) - 1; i >= 0; i-- {
+			if s[i] == ')' {
+				depth++
+			} else if s[i] == '(' {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+	}
+	for i > 0 && strings.IndexByte(" \t\n\r\f", s[i-1]) < 0 {
+		i--
+	}
+	return i
+}
+
+// splitOutsideParens splits s at the commas that are not within parentheses.
+func splitOutsideParens(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth <= 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// ImageCandidates gathers the candidates offered by an <img> element, or by a <picture> element's
+// <source> and <img> children. The lazy-loading conventions data-srcset and data-src are used
+// when srcset and src are absent. Sources with a media condition are included, since the
+// viewport they depend on is unknown.
+func ImageCandidates(node *html.Node) []ImageCandidate {
+	var elements []*html.Node
+	switch node.DataAtom {
+	case atom.Picture:
+		elements = scrape.FindAll(node, func(n *html.Node) bool {
+			return n.DataAtom == atom.Source || n.DataAtom == atom.Img
+		})
+	default:
+		elements = []*html.Node{node}
+	}
+
+	var candidates []ImageCandidate
+	for _, e := range elements {
+		srcset := firstAttr(e, "srcset", "data-srcset")
+		candidates = append(candidates, ParseSrcset(srcset)...)
+		if src := firstAttr(e, "src", "data-src"); src != "" && e.DataAtom != atom.Source {
+			candidates = append(candidates, ImageCandidate{URL: src, Density: 1})
+		}
+	}
+	return candidates
+}
+
+// firstAttr returns the value of the first of the given attributes that is present and non-empty.
+func firstAttr(node *html.Node, keys ...string) string {
+	for _, key := range keys {
+		if value := strings.TrimSpace(scrape.Attr(node, key)); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// BestImage chooses the URL of the image candidate of node, as gathered by ImageCandidates, best
+// suited to display at targetWidth CSS pixels: the narrowest that is at least that wide, or
+// else the widest. The width of a density candidate is its density multiplied by the element's
+// width attribute, when present. A targetWidth of zero or less chooses the widest candidate.
+// If there are no candidates, then ok will be false.
+func BestImage(node *html.Node, targetWidth int) (url string, ok bool) {
+	candidates := ImageCandidates(node)
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	baseWidth := 0
+	if img, found := scrape.Find(node, scrape.ByTag(atom.Img)); found {
+		baseWidth, _ = strconv.Atoi(scrape.Attr(img, "width"))
+	}
+	effectiveWidth := func(c ImageCandidate) float64 {
+		if c.Width > 0 {
+			return float64(c.Width)
+		}
+		if baseWidth > 0 {
+			return c.Density * float64(baseWidth)
+		}
+		// without a known width, rank densities relative to one another
+		return c.Density
+	}
+
+	var best *ImageCandidate
+	var bestWidth float64
+	for i := range candidates {
+		c := &candidates[i]
+		width := effectiveWidth(*c)
+		switch {
+		case best == nil:
+		case targetWidth > 0 && bestWidth >= float64(targetWidth):
+			// already wide enough, so only a narrower but still sufficient candidate is better
+			if width < float64(targetWidth) || width >= bestWidth {
+				continue
+			}
+		default:
+			if width <= bestWidth {
+				continue
+			}
+		}
+		best, bestWidth = c, width
+	}
+	return best.URL, true
+}
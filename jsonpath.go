@@ -0,0 +1,450 @@
+package restify
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Query evaluates a JSONPath expression against a JSON document, such as one produced by
+// ConvertHtmlToJson, returning the matching values in document order. Objects are
+// map[string]interface{}, arrays are []interface{}, and numbers are float64, as decoded by
+// encoding/json. For example,
+//
+//	prices, err := restify.Query(doc, "$..elements[?(@.class == 'price')].text")
+//
+// The supported subset is the root $, child members (.name and ['name']), wildcards (.* and
+// [*]), array indexes ([0] and [-1]), slices ([start:end]), recursive descent (..name and ..*),
+// and filters comparing a member of the current value to a literal ([?(@.name == 'value')])
+// or testing that a member exists ([?(@.name)]). The members of an object matched by a wildcard
+// are visited in order of their names.
+func Query(jsonDoc []byte, path string) ([]interface{}, error) {
+	steps, err := parseJsonPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(jsonDoc, &doc); err != nil {
+		return nil, fmt.Errorf("Failed to parse JSON document: %w", err)
+	}
+
+	values := []interface{}{doc}
+	for _, step := range steps {
+		var next []interface{}
+		for _, value := range values {
+			if step.recursive {
+				for _, descendant := range jsonDescendants(value, nil) {
+					next = step.selector.apply(descendant, next)
+				}
+			} else {
+				next = step.selector.apply(value, next)
+			}
+		}
+		values = next
+	}
+	return values, nil
+}
+
+type jsonPathStep struct {
+	// recursive applies the selector to the value and all of its descendants
+	recursive bool
+	selector  jsonPathSelector
+}
+
+type jsonSelectorKind int
+
+const (
+	jsonSelectName jsonSelectorKind = iota
+	jsonSelectWildcard
+	jsonSelectIndex
+	jsonSelectSlice
+	jsonSelectFilter
+)
+
+type jsonPathSelector struct {
+	kind jsonSelectorKind
+	name string
+	// index is the array index, or the start of a slice
+	index int
+	// end is the end of a slice, with hasStart and hasEnd telling whether each bound was given
+	end              int
+	hasStart, hasEnd bool
+	filter           *jsonPathFilter
+}
+
+// jsonPathFilter tests the value at a member path of each candidate, either for existence when
+// op is empty or by comparison with a literal.
+type jsonPathFilter struct {
+	members []string
+	op      string
+	literal interface{}
+}
+
+// apply appends the results of the selector on value to results.
+func (s *jsonPathSelector) apply(value interface{}, results []interface{}) []interface{} {
+	switch s.kind {
+	case jsonSelectName:
+		if object, ok := value.(map[string]interface{}); ok {
+			if member, ok := object[s.name]; ok {
+				results = append(results, member)
+			}
+		}
+
+	case jsonSelectWildcard:
+		results = append(results, jsonChildren(value)...)
+
+	case jsonSelectIndex:
+		if array, ok := value.([]interface{}); ok {
+			i := s.index
+			if i < 0 {
+				i += len(array)
+			}
+			if i >= 0 && i < len(array) {
+				results = append(results, array[i])
+			}
+		}
+
+	case jsonSelectSlice:
+		if array, ok := value.([]interface{}); ok {
+			start, end := 0, len(array)
+			if s.hasStart {
+				start = clampSliceBound(s.index, len(array))
+			}
+			if s.hasEnd {
+				end = clampSliceBound(s.end, len(array))
+			}
+			for i := start; i < end; i++ {
+				results = append(results, array[i])
+			}
+		}
+
+	case jsonSelectFilter:
+		for _, child := range jsonChildren(value) {
+			if s.filter.match(child) {
+				results = append(results, child)
+			}
+		}
+	}
+	return results
+}
+
+func clampSliceBound(bound, length int) int {
+	if bound < 0 {
+		bound += length
+	}
+	if bound < 0 {
+		return 0
+	}
+	if bound > length {
+		return length
+	}
+	return bound
+}
+
+// jsonChildren lists the elements of an array or the members of an object, ordered by name.
+func jsonChildren(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		children := make([]interface{}, len(names))
+		for i, name := range names {
+			children[i] = v[name]
+		}
+		return children
+	default:
+		return nil
+	}
+}
+
+// jsonDescendants appends value and, in document order, all the values nested within it.
+func jsonDescendants(value interface{}, results []interface{}) []interface{} {
+	results = append(results, value)
+	for _, child := range jsonChildren(value) {
+		results = jsonDescendants(child, results)
+	}
+	return results
+}
+
+func (f *jsonPathFilter) match(value interface{}) bool {
+	for _, member := range f.members {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if value, ok = object[member]; !ok {
+			return false
+		}
+	}
+	if f.op == "" {
+		return true
+	}
+
+	if number, ok := value.(float64); ok {
+		if literal, ok := f.literal.(float64); ok {
+			switch f.op {
+			case "<":
+				return number < literal
+			case "<=":
+				return number <= literal
+			case ">":
+				return number > literal
+			case ">=":
+				return number >= literal
+			}
+		}
+	}
+	if text, ok := value.(string); ok {
+		if literal, ok := f.literal.(string); ok {
+			switch f.op {
+			case "<":
+				return text < literal
+			case "<=":
+				return text <= literal
+			case ">":
+				return text > literal
+			case ">=":
+				return text >= literal
+			}
+		}
+	}
+	switch f.op {
+	case "==":
+		return value == f.literal
+	case "!=":
+		return value != f.literal
+	}
+	return false
+}
+
+// jsonPathParser parses the JSONPath subset accepted by Query.
+type jsonPathParser struct {
+	path string
+	pos  int
+}
+
+func parseJsonPath(path string) ([]jsonPathStep, error) {
+	p := &jsonPathParser{path: strings.TrimSpace(path)}
+	if !p.consume("$") {
+		return nil, p.errorf("expected $")
+	}
+
+	var steps []jsonPathStep
+	for p.pos < len(p.path) {
+		var step jsonPathStep
+		var err error
+		switch {
+		case p.consume(".."):
+			step.recursive = true
+			if p.peek() == '[' {
+				step.selector, err = p.parseBracket()
+			} else {
+				step.selector, err = p.parseDotted()
+			}
+		case p.consume("."):
+			step.selector, err = p.parseDotted()
+		case p.peek() == '[':
+			step.selector, err = p.parseBracket()
+		default:
+			err = p.errorf("unexpected %q", p.path[p.pos])
+		}
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func (p *jsonPathParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("Invalid JSONPath %q at offset %d: %s", p.path, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *jsonPathParser) peek() byte {
+	if p.pos < len(p.path) {
+		return p.path[p.pos]
+	}
+	return 0
+}
+
+func (p *jsonPathParser) consume(s string) bool {
+	if strings.HasPrefix(p.path[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *jsonPathParser) skipSpace() {
+	for p.pos < len(p.path) && p.path[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// parseDotted parses the member name or wildcard following a dot.
+func (p *jsonPathParser) parseDotted() (jsonPathSelector, error) {
+	if p.consume("*") {
+		return jsonPathSelector{kind: jsonSelectWildcard}, nil
+	}
+	name := p.parseName()
+	if name == "" {
+		return jsonPathSelector{}, p.errorf("expected a member name")
+	}
+	return jsonPathSelector{kind: jsonSelectName, name: name}, nil
+}
+
+func (p *jsonPathParser) parseName() string {
+	start := p.pos
+	for p.pos < len(p.path) && strings.IndexByte(".[]()=!<>'\" ", p.path[p.pos]) < 0 {
+		p.pos++
+	}
+	return p.path[start:p.pos]
+}
+
+func (p *jsonPathParser) parseBracket() (jsonPathSelector, error) {
+	var selector jsonPathSelector
+	p.consume("[")
+	p.skipSpace()
+
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		selector.kind = jsonSelectWildcard
+
+	case c == '\'' || c == '"':
+		name, err := p.parseString()
+		if err != nil {
+			return selector, err
+		}
+		selector = jsonPathSelector{kind: jsonSelectName, name: name}
+
+	case c == '?':
+		p.pos++
+		filter, err := p.parseFilter()
+		if err != nil {
+			return selector, err
+		}
+		selector = jsonPathSelector{kind: jsonSelectFilter, filter: filter}
+
+	default:
+		start, hasStart := p.parseInt()
+		p.skipSpace()
+		if !p.consume(":") {
+			if !hasStart {
+				return selector, p.errorf("expected an index, name, wildcard, slice, or filter")
+			}
+			selector = jsonPathSelector{kind: jsonSelectIndex, index: start}
+			break
+		}
+		p.skipSpace()
+		end, hasEnd := p.parseInt()
+		selector = jsonPathSelector{kind: jsonSelectSlice, index: start, hasStart: hasStart, end: end, hasEnd: hasEnd}
+	}
+
+	p.skipSpace()
+	if !p.consume("]") {
+		return selector, p.errorf("expected ]")
+	}
+	return selector, nil
+}
+
+func (p *jsonPathParser) parseInt() (int, bool) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.path) && p.path[p.pos] >= '0' && p.path[p.pos] <= '9' {
+		p.pos++
+	}
+	value, err := strconv.Atoi(p.path[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return 0, false
+	}
+	return value, true
+}
+
+func (p *jsonPathParser) parseString() (string, error) {
+	quote := p.path[p.pos]
+	end := strings.IndexByte(p.path[p.pos+1:], quote)
+	if end < 0 {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.path[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	return s, nil
+}
+
+// parseFilter parses a filter expression such as (@.price > 10), following the ?.
+func (p *jsonPathParser) parseFilter() (*jsonPathFilter, error) {
+	if !p.consume("(") {
+		return nil, p.errorf("expected ( to begin filter")
+	}
+	p.skipSpace()
+	if !p.consume("@") {
+		return nil, p.errorf("expected @ in filter")
+	}
+
+	filter := &jsonPathFilter{}
+	for p.consume(".") {
+		name := p.parseName()
+		if name == "" {
+			return nil, p.errorf("expected a member name in filter")
+		}
+		filter.members = append(filter.members, name)
+	}
+
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			filter.op = op
+			break
+		}
+	}
+	if filter.op != "" {
+		p.skipSpace()
+		literal, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		filter.literal = literal
+		p.skipSpace()
+	}
+
+	if !p.consume(")") {
+		return nil, p.errorf("expected ) to end filter")
+	}
+	return filter, nil
+}
+
+func (p *jsonPathParser) parseLiteral() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		return p.parseString()
+	case p.consume("true"):
+		return true, nil
+	case p.consume("false"):
+		return false, nil
+	case p.consume("null"):
+		return nil, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.path) && strings.IndexByte("+-.0123456789eE", p.path[p.pos]) >= 0 {
+		p.pos++
+	}
+	number, err := strconv.ParseFloat(p.path[start:p.pos], 64)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("expected a string, number, boolean, or null")
+	}
+	return number, nil
+}
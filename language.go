@@ -0,0 +1,278 @@
+package restify
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Sources of a LanguageGuess.
+const (
+	// LanguageSourceDeclared means the language was declared by the page's markup alone
+	LanguageSourceDeclared = "declared"
+	// LanguageSourceText means the language was detected statistically from the page's text alone
+	LanguageSourceText = "text"
+	// LanguageSourceBoth means the declared language agrees with the detected one
+	LanguageSourceBoth = "declared+text"
+)
+
+// minDetectionWords is the number of words below which statistical detection is not attempted
+// for languages written in the Latin script.
+const minDetectionWords = 20
+
+// LanguageGuess is the probable language of a page.
+type LanguageGuess struct {
+	// Tag is a BCP-47 language tag, such as "en" or "pt-BR", or empty if unknown
+	Tag string `json:"tag"`
+	// Confidence ranges from 0, for unknown, to 1
+	Confidence float64 `json:"confidence"`
+	// Source is one of LanguageSourceDeclared, LanguageSourceText, or LanguageSourceBoth
+	Source string `json:"source,omitempty"`
+}
+
+// DetectLanguage determines the language of the page at root. The language declared by the
+// <html lang> attribute, or failing that by content-language, language, or og:locale meta
+// tags, is combined with statistical detection over the visible text. Statistical detection
+// recognizes languages by their script, and common Western European languages by the frequency
+// of their most common words.
+//
+// When the declared and detected languages disagree, the detected language is preferred only if
+// the text gives strong evidence for it, since pages are often built from templates that declare
+// a default language.
+func DetectLanguage(root *html.Node) LanguageGuess {
+	declared := declaredLanguage(root)
+	detected := DetectTextLanguage(visibleText(root))
+
+	switch {
+	case declared == "" && detected.Tag == "":
+		return LanguageGuess{}
+
+	case declared == "":
+		return detected
+
+	case detected.Tag == "":
+		return LanguageGuess{Tag: declared, Confidence: 0.7, Source: LanguageSourceDeclared}
+
+	case primaryLanguage(declared) == primaryLanguage(detected.Tag):
+		// the declared tag is usually more specific, such as including a region
+		return LanguageGuess{
+			Tag:        declared,
+			Confidence: 1 - (1-detected.Confidence)*0.3,
+			Source:     LanguageSourceBoth,
+		}
+
+	case detected.Confidence >= 0.8:
+		return detected
+
+	default:
+		return LanguageGuess{Tag: declared, Confidence: 0.5, Source: LanguageSourceDeclared}
+	}
+}
+
+// declaredLanguage finds the language declared by the markup, normalized as a BCP-47 tag.
+func declaredLanguage(root *html.Node) string {
+	if htmlElem, ok := scrape.Find(root, scrape.ByTag(atom.Html)); ok {
+		if lang := NormalizeLanguageTag(scrape.Attr(htmlElem, "lang")); lang != "" {
+			return lang
+		}
+	}
+
+	metas := []scrape.Matcher{
+		func(n *html.Node) bool {
+			return n.DataAtom == atom.Meta && strings.EqualFold(scrape.Attr(n, "http-equiv"), "content-language")
+		},
+		matchMetaProperty("language"),
+		matchMetaProperty("og:locale"),
+	}
+	for _, matcher := range metas {
+		if meta, ok := scrape.Find(root, matcher); ok {
+			// content-language may list several languages
+			content := strings.Split(scrape.Attr(meta, "content"), ",")[0]
+			if lang := NormalizeLanguageTag(content); lang != "" {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// NormalizeLanguageTag converts loosely written language tags, such as "EN_us", into BCP-47
+// form, such as "en-US". An empty string is returned for values that are not language tags.
+func NormalizeLanguageTag(tag string) string {
+	parts := strings.FieldsFunc(strings.TrimSpace(tag), func(r rune) bool {
+		return r == '-' || r == '_'
+	})
+	if len(parts) == 0 || len(parts[0]) < 2 || len(parts[0]) > 3 {
+		return ""
+	}
+	for i, part := range parts {
+		for _, r := range part {
+			if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				return ""
+			}
+		}
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(part)
+		case len(part) == 2:
+			parts[i] = strings.ToUpper(part)
+		case len(part) == 4:
+			parts[i] = strings.ToUpper(part[:1]) + strings.ToLower(part[1:])
+		default:
+			parts[i] = strings.ToLower(part)
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+func primaryLanguage(tag string) string {
+	return strings.SplitN(tag, "-", 2)[0]
+}
+
+// scriptLanguages maps scripts that are predominantly used by a single language to that language.
+var scriptLanguages = []struct {
+	script *unicode.RangeTable
+	tag    string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Thai, "th"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Arabic, "ar"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Armenian, "hy"},
+	{unicode.Georgian, "ka"},
+}
+
+// stopwords are the most frequent words of each language detected statistically from Latin or
+// Cyrillic text, chosen to be reasonably distinct between the languages.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "it", "with", "for", "was", "on", "are", "this", "be"},
+	"fr": {"le", "la", "les", "et", "des", "est", "une", "du", "que", "pour", "dans", "qui", "pas", "sur", "au"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "den", "von", "zu", "ein", "sich", "auf", "für", "dem"},
+	"es": {"el", "la", "de", "que", "y", "los", "las", "por", "una", "con", "para", "del", "se", "es", "como"},
+	"it": {"il", "di", "che", "e", "la", "per", "un", "non", "sono", "della", "del", "gli", "una", "con", "è"},
+	"pt": {"o", "de", "que", "e", "do", "da", "em", "um", "para", "não", "uma", "os", "com", "no", "mais"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "op", "te", "zijn", "voor", "met", "ook", "er"},
+	"sv": {"och", "att", "det", "som", "en", "på", "är", "av", "för", "med", "till", "den", "har", "inte", "om"},
+	"pl": {"i", "w", "się", "nie", "na", "że", "do", "jest", "to", "z", "jak", "co", "ale", "tak", "czy"},
+	"tr": {"ve", "bir", "bu", "da", "de", "için", "ile", "çok", "ne", "daha", "gibi", "olan", "ama", "var", "mi"},
+	"ru": {"и", "в", "не", "на", "что", "я", "с", "он", "как", "это", "по", "но", "из", "все", "так"},
+	"uk": {"і", "в", "не", "на", "що", "з", "як", "це", "та", "до", "від", "він", "але", "його", "є"},
+}
+
+// stopwordIndex maps each stopword to the languages that use it.
+var stopwordIndex = func() map[string][]string {
+	index := make(map[string][]string)
+	for tag, words := range stopwords {
+		for _, word := range words {
+			index[word] = append(index[word], tag)
+		}
+	}
+	return index
+}()
+
+// DetectTextLanguage statistically determines the language of the given text, as described by
+// DetectLanguage. The result's Tag is empty if there is too little text to decide.
+func DetectTextLanguage(text string) LanguageGuess {
+	var letters, han, kana, cyrillic, latin int
+	scriptCounts := make([]int, len(scriptLanguages))
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		default:
+			for i, s := range scriptLanguages {
+				if unicode.Is(s.script, r) {
+					scriptCounts[i]++
+					break
+				}
+			}
+		}
+	}
+	if letters == 0 {
+		return LanguageGuess{}
+	}
+
+	// scripts without word separators or with a single dominant language are decided by script alone
+	share := func(count int) float64 {
+		return float64(count) / float64(letters)
+	}
+	if kana > 0 && share(kana+han) > 0.5 {
+		return LanguageGuess{Tag: "ja", Confidence: share(kana + han), Source: LanguageSourceText}
+	}
+	if share(han) > 0.5 {
+		return LanguageGuess{Tag: "zh", Confidence: share(han), Source: LanguageSourceText}
+	}
+	for i, s := range scriptLanguages {
+		if share(scriptCounts[i]) > 0.5 {
+			return LanguageGuess{Tag: s.tag, Confidence: share(scriptCounts[i]), Source: LanguageSourceText}
+		}
+	}
+	if share(latin+cyrillic) <= 0.5 {
+		return LanguageGuess{}
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(words) < minDetectionWords {
+		return LanguageGuess{}
+	}
+
+	scores := make(map[string]int)
+	for _, word := range words {
+		for _, tag := range stopwordIndex[word] {
+			scores[tag]++
+		}
+	}
+
+	type scored struct {
+		tag   string
+		score int
+	}
+	var ranked []scored
+	for tag, score := range scores {
+		ranked = append(ranked, scored{tag, score})
+	}
+	if len(ranked) == 0 {
+		return LanguageGuess{}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].tag < ranked[j].tag
+	})
+
+	best := ranked[0]
+	runnerUp := 0
+	if len(ranked) > 1 {
+		runnerUp = ranked[1].score
+	}
+	// confidence reflects both how distinct the winner is and how much of the text are stopwords
+	margin := float64(best.score-runnerUp) / float64(best.score)
+	coverage := float64(best.score) / float64(len(words)) * 4
+	if coverage > 1 {
+		coverage = 1
+	}
+	return LanguageGuess{
+		Tag:        best.tag,
+		Confidence: 0.5 + 0.5*margin*coverage,
+		Source:     LanguageSourceText,
+	}
+}
@@ -0,0 +1,191 @@
+package restify
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DefaultLinkCheckConcurrency is the number of links checked at once when not configured.
+const DefaultLinkCheckConcurrency = 4
+
+// ExtractLinks retrieves the distinct outbound links of the <a> and <area> elements within root,
+// resolved against baseURL. Fragment-only, javascript:, mailto:, and tel: links are skipped, as
+// are file links unless baseURL is a file URL itself. The links are normalized by the zero
+// URLNormalizer, which removes their fragments, and are returned in document order. Use
+// Loader.ExtractLinks to normalize them as a Loader requests them.
+func ExtractLinks(root *html.Node, baseURL *url.URL) []*url.URL {
+	return extractLinks(root, baseURL, &URLNormalizer{})
+}
+
+// ExtractLinks retrieves the links within root as the package-level ExtractLinks does, but
+// normalized by the Loader's URLNormalizer, so that they are the URLs the Loader requests.
+func (l *Loader) ExtractLinks(root *html.Node, baseURL *url.URL) []*url.URL {
+	return extractLinks(root, baseURL, l.normalizer())
+}
+
+// extractLinks is ExtractLinks with the links normalized by normalizer.
+func extractLinks(root *html.Node, baseURL *url.URL, normalizer *URLNormalizer) []*url.URL {
+	anchors := scrape.FindAllNested(root, func(n *html.Node) bool {
+		return (n.DataAtom == atom.A || n.DataAtom == atom.Area) && scrape.Attr(n, "href") != ""
+	})
+
+	seen := make(map[string]bool)
+	var links []*url.URL
+	for _, anchor := range anchors {
+		href := strings.TrimSpace(scrape.Attr(anchor, "href"))
+		if strings.HasPrefix(href, "#") {
+			continue
+		}
+		parsed, err := url.Parse(href)
+		if err != nil {
+			continue
+		}
+		resolved := baseURL.ResolveReference(parsed)
+		if !followable(baseURL, resolved) {
+			continue
+		}
+		if resolved, err = normalizer.Normalize(resolved); err != nil {
+			continue
+		}
+
+		key := resolved.String()
+		if !seen[key] {
+			seen[key] = true
+			links = append(links, resolved)
+		}
+	}
+	return links
+}
+
+// LinkCheckOptions configures CheckLinks.
+type LinkCheckOptions struct {
+	// Client is used to issue requests. If nil, a client with a timeout of HttpRequestTimeout is used.
+	Client *http.Client
+	// Concurrency is the number of links checked at once, DefaultLinkCheckConcurrency if zero
+	Concurrency int
+	// Interval is the minimum time between starting requests, for rate limiting. Zero means no limit.
+	Interval time.Duration
+	// UserAgent is the optional user-agent header to send with each request
+	UserAgent string
+}
+
+// LinkStatus is the outcome of checking a single link.
+type LinkStatus struct {
+	// URL is the link that was checked
+	URL *url.URL
+	// StatusCode is the HTTP status of the final response, zero if Err is set
+	StatusCode int
+	// Err is set if the link could not be retrieved at all
+	Err error
+}
+
+// Broken reports whether the link failed to be retrieved or responded with an error status.
+func (s LinkStatus) Broken() bool {
+	return s.Err != nil || s.StatusCode >= 400
+}
+
+// CheckLinks extracts the links within root, as with ExtractLinks, and checks each of them
+// concurrently. A HEAD request is tried first and, since many servers mishandle HEAD, a GET
+// request is used when it fails or responds with an error status. Redirects are followed. The
+// statuses are returned in the same order as the links appear in the document. File URLs, which
+// are only extracted from pages that are file URLs themselves, are checked for existence.
+func CheckLinks(root *html.Node, baseURL *url.URL, opts LinkCheckOptions) []LinkStatus {
+	links := ExtractLinks(root, baseURL)
+	statuses := make([]LinkStatus, len(links))
+
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: HttpRequestTimeout}
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultLinkCheckConcurrency
+	}
+
+	var ticker *time.Ticker
+	if opts.Interval > 0 {
+		ticker = time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				statuses[i] = checkLink(client, links[i], opts.UserAgent)
+			}
+		}()
+	}
+
+	for i := range links {
+		if ticker != nil && i > 0 {
+			<-ticker.C
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return statuses
+}
+
+func checkLink(client *http.Client, link *url.URL, userAgent string) LinkStatus {
+	status := LinkStatus{URL: link}
+
+	if link.Scheme == "file" {
+		path, err := FilePath(link)
+		if err == nil {
+			_, err = os.Stat(path)
+		}
+		if err != nil {
+			status.Err = err
+		} else {
+			status.StatusCode = http.StatusOK
+		}
+		return status
+	}
+
+	for _, method := range []string{"HEAD", "GET"} {
+		request, err := http.NewRequest(method, link.String(), nil)
+		if err != nil {
+			status.Err = fmt.Errorf("Failed to request: %w", err)
+			return status
+		}
+		if userAgent != "" {
+			request.Header.Set("user-agent", userAgent)
+		}
+
+		resp, err := client.Do(request)
+		if err != nil {
+			status.StatusCode = 0
+			status.Err = fmt.Errorf("Failed to retrieve response: %w", err)
+			continue
+		}
+		status.Err = nil
+		// drain a little of the body so the connection can be reused
+		//goland:noinspection GoUnhandledErrorResult
+		io.CopyN(ioutil.Discard, resp.Body, 4096)
+		//goland:noinspection GoUnhandledErrorResult
+		resp.Body.Close()
+
+		status.StatusCode = resp.StatusCode
+		if resp.StatusCode < 400 {
+			break
+		}
+	}
+	return status
+}
@@ -1,11 +1,16 @@
 package restify
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
 	"time"
 
@@ -14,6 +19,7 @@ import (
 	"golang.org/x/net/html/atom"
 )
 
+// HttpRequestTimeout is the default time allowed for a load, including reading the response body.
 const HttpRequestTimeout = time.Second * 60
 
 type RequestConfig func(*http.Request)
@@ -27,9 +33,48 @@ func WithHeaders(headers map[string]string) RequestConfig {
 	}
 }
 
-// LoadFile retrieves the HTML content from the given file URL.
+// WithBasicAuth configures HTTP basic authentication with the given credentials in each
+// request to a host matching hostPattern, as described by WithHostPolicy, so that they are not
+// sent to the other hosts that pages redirect to or embed.
+func WithBasicAuth(hostPattern, username, password string) RequestConfig {
+	hostPattern = strings.ToLower(hostPattern)
+	return func(request *http.Request) {
+		if matchHostPattern(hostPattern, strings.ToLower(request.URL.Hostname())) {
+			request.SetBasicAuth(username, password)
+		}
+	}
+}
+
+// WithBearerToken configures the given bearer token as the authorization of each request to a
+// host matching hostPattern, as described by WithHostPolicy, so that it is not sent to the other
+// hosts that pages redirect to or embed.
+func WithBearerToken(hostPattern, token string) RequestConfig {
+	hostPattern = strings.ToLower(hostPattern)
+	return func(request *http.Request) {
+		if matchHostPattern(hostPattern, strings.ToLower(request.URL.Hostname())) {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+}
+
+// LoadBuffer parses the HTML content in the given buffer. The buffer is read in place rather
+// than copied, and it is not retained once LoadBuffer returns, since the parsed nodes hold their
+// own copies of the content, so the caller may reuse or modify it afterwards. Content that is
+// compressed, as recognized by RegisterDecompressor, is decompressed first. Only gzip and bzip2
+// are built in, so zstd content needs a decoder registered with RegisterDecompressor.
 func LoadBuffer(buffer []byte) (*html.Node, error) {
-	root, err := html.Parse(strings.NewReader(string(buffer)))
+	var reader io.Reader = bytes.NewReader(buffer)
+	if _, _, compressed := findCompression(buffer, ""); compressed {
+		decompressed, closeDecompressed, err := decompress(reader, "")
+		if err != nil {
+			return nil, err
+		}
+		//goland:noinspection GoUnhandledErrorResult
+		defer closeDecompressed()
+		reader = decompressed
+	}
+
+	root, err := html.Parse(reader)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to parse buffer: %w", err)
 	}
@@ -37,6 +82,30 @@ func LoadBuffer(buffer []byte) (*html.Node, error) {
 	return root, nil
 }
 
+// LoadFragment parses the given HTML snippet, such as markup stored in a database column, as the
+// content of an element of type context, without the <html>, <head>, and <body> wrapping that
+// LoadBuffer would introduce. A context of zero parses the snippet as the content of a <body>.
+// The returned nodes are the top-level nodes of the snippet and have no parent. They include
+// any text and comments outside of its elements, which ConvertHtmlToJson refuses, so wrap a
+// snippet in an element, such as "<div>"+s+"</div>", to convert its text too.
+func LoadFragment(s string, context atom.Atom) ([]*html.Node, error) {
+	if context == 0 {
+		context = atom.Body
+	}
+	contextNode := &html.Node{
+		Type:     html.ElementNode,
+		DataAtom: context,
+		Data:     context.String(),
+	}
+
+	nodes, err := html.ParseFragment(strings.NewReader(s), contextNode)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse fragment: %w", err)
+	}
+
+	return nodes, nil
+}
+
 func LoadReader(reader io.Reader) (*html.Node, error) {
 	root, err := html.Parse(reader)
 	if err != nil {
@@ -46,57 +115,297 @@ func LoadReader(reader io.Reader) (*html.Node, error) {
 	return root, nil
 }
 
+// LoadFile retrieves the HTML content from the given file URL. The userAgent and configs are
+// accepted for symmetry with LoadContent and are ignored.
+//
+// Absolute URLs such as file:///home/me/page.html and file:///C:/pages/page.html are supported
+// along with relative forms such as file:pages/page.html and file://./pages/page.html.
+// Percent-encoded characters in the path are unescaped. Compressed files are decompressed as
+// LoadPath does.
 func LoadFile(url *url.URL, userAgent string, configs ...RequestConfig) (*html.Node, error) {
+	path, err := FilePath(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadPath(path)
+}
+
+// FilePath converts the given file URL into a local filesystem path.
+func FilePath(fileUrl *url.URL) (string, error) {
+	var path string
+	switch {
+	case fileUrl.Opaque != "":
+		// relative reference such as file:pages/page.html
+		unescaped, err := url.PathUnescape(fileUrl.Opaque)
+		if err != nil {
+			return "", fmt.Errorf("Failed to unescape file URL: %w", err)
+		}
+		path = unescaped
+
+	case fileUrl.Host == "." || fileUrl.Host == "..":
+		// relative reference such as file://./pages/page.html
+		path = fileUrl.Host + fileUrl.Path
+
+	case fileUrl.Host == "" || fileUrl.Host == "localhost":
+		path = fileUrl.Path
+		if runtime.GOOS == "windows" && windowsDrivePath.MatchString(path) {
+			// file:///C:/pages/page.html has a path of /C:/pages/page.html
+			path = path[1:]
+		}
 
-	// open the file as a io.reader
-	filePointer, err := os.Open(url.Path)
+	default:
+		if runtime.GOOS != "windows" {
+			return "", fmt.Errorf("Unsupported file URL host: %s", fileUrl.Host)
+		}
+		// UNC path such as file://server/share/page.html
+		path = "//" + fileUrl.Host + fileUrl.Path
+	}
+
+	if path == "" {
+		return "", fmt.Errorf("File URL is missing a path: %s", fileUrl)
+	}
+
+	return filepath.FromSlash(path), nil
+}
+
+var windowsDrivePath = regexp.MustCompile(`^/[A-Za-z]:`)
+
+// LoadPath retrieves the HTML content from the file at the given local path. Compressed files,
+// such as page.html.gz, are decompressed as they are read, as described by RegisterDecompressor.
+// Only gzip and bzip2 are built in, so .zst files need a zstd decoder registered with
+// RegisterDecompressor.
+func LoadPath(path string) (*html.Node, error) {
+	file, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to open file: %w", err)
 	}
 	//goland:noinspection GoUnhandledErrorResult
+	defer file.Close()
 
-	root, err := html.Parse(filePointer)
+	content, closeContent, err := decompress(file, path)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to parse file: %w", err)
+		return nil, fmt.Errorf("Failed to read file %s: %w", path, err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer closeContent()
+
+	root, err := html.Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse file %s: %w", path, err)
 	}
 
 	return root, nil
 }
 
+// LocalDocument is an HTML document that was loaded from the local filesystem.
+type LocalDocument struct {
+	// Path is the filesystem path the document was loaded from
+	Path string
+	// Root is the parsed document
+	Root *html.Node
+}
+
+// LoadFiles retrieves the HTML content of every file matching the given glob pattern,
+// which uses the syntax of filepath.Match. The documents are returned in lexical order of
+// their paths. A pattern that matches no files results in an empty slice and no error.
+func LoadFiles(glob string) ([]LocalDocument, error) {
+	paths, err := filepath.Glob(glob)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to expand glob %s: %w", glob, err)
+	}
+
+	documents := make([]LocalDocument, 0, len(paths))
+	for _, path := range paths {
+		root, err := LoadPath(path)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, LocalDocument{Path: path, Root: root})
+	}
+
+	return documents, nil
+}
+
 // LoadContent retrieves the HTML content from the given url.
 // The userAgent is optional, but if provided should conform with https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/User-Agent
 func LoadContent(url *url.URL, userAgent string, configs ...RequestConfig) (*html.Node, error) {
-	if url.Scheme == "file" {
-		return LoadFile(url, userAgent, configs...)
+	return NewLoader(WithUserAgent(userAgent), WithRequestConfigs(configs...)).Load(url)
+}
+
+// Loader retrieves HTML content with a reusable configuration. Unlike LoadContent, a Loader
+// holds onto its HTTP client, so connections are reused across loads. Create one with NewLoader.
+type Loader struct {
+	client    *http.Client
+	transport http.RoundTripper
+	// wrappers decorate the transport in the order they were configured
+	wrappers  []func(http.RoundTripper) http.RoundTripper
+	userAgent string
+	// userAgents, when set, are rotated through per request using userAgentIndex
+	userAgents     []string
+	userAgentIndex uint32
+	configs        []RequestConfig
+	timeout        time.Duration
+	// maxHtmlRedirects is the number of meta refresh and JavaScript redirects to follow
+	maxHtmlRedirects int
+	// preferredVersions are the simpler versions of pages to load instead, in order of preference
+	preferredVersions []PageVersion
+	// transportTunings adjust a copy of transport, when it is an *http.Transport
+	transportTunings []func(*http.Transport)
+	// protocol selects the version of HTTP, when transport is an *http.Transport
+	protocol Protocol
+	// profile, when set, supplies browser-like request headers
+	profile *BrowserProfile
+	// hostPolicies limit the requests to each host, the first matching pattern applying
+	hostPolicies []hostPolicy
+	// pdfExtractor, when set, extracts the text of PDF responses
+	pdfExtractor PdfExtractor
+	// frameMode selects how the documents embedded by <iframe> elements are loaded
+	frameMode FrameMode
+	// transforms adjust each parsed HTML document, in the order they were configured
+	transforms []func(*html.Node)
+	// preferredLanguages are the language variants of pages to load instead, in order of preference
+	preferredLanguages []string
+	// retainBody keeps the raw content of each response
+	retainBody bool
+	// bodyTee, when set, opens a writer for the raw content of each response
+	bodyTee func(resp *Response) io.Writer
+	// detectChallenges fails loads of anti-bot challenge pages
+	detectChallenges bool
+	// hostFilter, when set, restricts the hosts and addresses connected to
+	hostFilter *hostFilter
+	// resolver, when set, resolves the host names connected to
+	resolver Resolver
+	// dnsCache caches the addresses resolved for connections
+	dnsCache bool
+	// hashOptions, when set, normalizes pages before LoadIfChanged hashes them
+	hashOptions *NormalizeOptions
+	// retryPolicy, when set, retries failed requests
+	retryPolicy *RetryPolicy
+	// urlNormalizer, when set, normalizes the URLs requested in place of the zero URLNormalizer
+	urlNormalizer *URLNormalizer
+	// cookieJar, when set, stores and sends cookies
+	cookieJar http.CookieJar
+	// cookies are imported into the cookie jar
+	cookies []*http.Cookie
+	// documentOptions, when set, configure documents
+	documentOptions *DocumentStoreOptions
+	// documents, when set, keeps the pages loaded to serve them again
+	documents *DocumentStore
+}
+
+// LoaderOption configures a Loader created by NewLoader.
+type LoaderOption func(*Loader)
+
+// NewLoader creates a Loader configured with the given options.
+func NewLoader(options ...LoaderOption) *Loader {
+	l := &Loader{
+		transport: http.DefaultTransport,
+		timeout:   HttpRequestTimeout,
+	}
+	for _, option := range options {
+		option(l)
+	}
+	if l.dnsCache {
+		if _, cached := l.resolver.(*CachingResolver); !cached {
+			l.resolver = NewCachingResolver(l.resolver)
+		}
+	}
+	if l.documentOptions != nil {
+		l.documents = newDocumentStore(*l.documentOptions, l.normalizer())
+	}
+	if l.customDialing() {
+		l.transportTunings = append(l.transportTunings, func(transport *http.Transport) {
+			transport.DialContext = l.dialContext
+			transport.DialTLSContext = nil
+		})
 	}
 
-	request, err := http.NewRequest("GET", url.String(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to request: %w", err)
+	transport := l.protocolTransport(l.tunedTransport())
+	if len(l.hostPolicies) > 0 {
+		transport = newPoliteTransport(transport, l.hostPolicies)
+	}
+	if l.hostFilter != nil {
+		transport = l.hostFilter.wrap(transport)
+	}
+	if l.retryPolicy != nil {
+		transport = l.retryPolicy.wrap(transport)
 	}
+	for _, wrap := range l.wrappers {
+		transport = wrap(transport)
+	}
+	l.client = &http.Client{
+		Transport:     transport,
+		CheckRedirect: recordRedirect,
+		Jar:           l.newCookieJar(),
+	}
+
+	return l
+}
 
-	request.Header.Set("accept", "*/*")
-	if userAgent != "" {
-		request.Header.Set("user-agent", userAgent)
+// WithUserAgent sets the user-agent header sent with each request.
+// It should conform with https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/User-Agent
+func WithUserAgent(userAgent string) LoaderOption {
+	return func(l *Loader) {
+		l.userAgent = userAgent
 	}
-	for _, config := range configs {
-		config(request)
+}
+
+// WithRequestConfigs applies the given configs to each request issued by the Loader.
+func WithRequestConfigs(configs ...RequestConfig) LoaderOption {
+	return func(l *Loader) {
+		l.configs = append(l.configs, configs...)
 	}
+}
 
-	http.DefaultClient.Timeout = HttpRequestTimeout
-	resp, err := http.DefaultClient.Do(request)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to retrieve response: %w", err)
+// WithTimeout sets the time allowed for each load, including reading the response body,
+// in place of HttpRequestTimeout. A timeout of zero disables the deadline, leaving only
+// the limits of any context passed to LoadContext.
+func WithTimeout(timeout time.Duration) LoaderOption {
+	return func(l *Loader) {
+		l.timeout = timeout
 	}
-	//goland:noinspection GoUnhandledErrorResult
-	defer resp.Body.Close()
+}
 
-	root, err := html.Parse(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to parse response body: %w", err)
+// WithTransport replaces the transport used to issue requests, such as with a HarTransport
+// to serve previously recorded responses.
+func WithTransport(transport http.RoundTripper) LoaderOption {
+	return func(l *Loader) {
+		l.transport = transport
 	}
+}
 
-	return root, nil
+// WithProxy sends requests through the proxy at proxyUrl, such as "http://proxy:3128" or
+// "socks5://proxy:1080", in place of any proxy configured by the environment. It applies when the
+// Loader's transport is an *http.Transport, as it is by default.
+func WithProxy(proxyUrl *url.URL) LoaderOption {
+	return func(l *Loader) {
+		l.transportTunings = append(l.transportTunings, func(transport *http.Transport) {
+			transport.Proxy = http.ProxyURL(proxyUrl)
+		})
+	}
+}
+
+// WithHarRecorder records every request issued by the Loader, and its response, into recorder.
+func WithHarRecorder(recorder *HarRecorder) LoaderOption {
+	return func(l *Loader) {
+		l.wrappers = append(l.wrappers, recorder.Wrap)
+	}
+}
+
+// Load retrieves the HTML content from the given url. File URLs are loaded with LoadFile.
+func (l *Loader) Load(url *url.URL) (*html.Node, error) {
+	return l.LoadContext(context.Background(), url)
+}
+
+// LoadContext is like Load but the request is bound to the given context, in addition to the
+// Loader's timeout.
+func (l *Loader) LoadContext(ctx context.Context, url *url.URL) (*html.Node, error) {
+	resp, err := l.Fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Root, nil
 }
 
 // FindSubsetById locates the HTML node within the given root that has an id attribute of given value.
@@ -118,7 +427,89 @@ func FindSubsetByAttributeName(root *html.Node, attribute string) []*html.Node {
 
 // FindSubsetByAttributeNameValue retrieves the HTML nodes that have the requested attribute with a specific value.
 func FindSubsetByAttributeNameValue(root *html.Node, attribute string, value string) []*html.Node {
-	return scrape.FindAll(root, matchByAttribute(attribute, value))
+	return scrape.FindAll(root, ByAttribute(attribute, value))
+}
+
+// FindSubsetByAttributePrefix retrieves the HTML nodes that have the requested attribute with a
+// value starting with prefix, such as href values starting with "https://".
+func FindSubsetByAttributePrefix(root *html.Node, attribute string, prefix string) []*html.Node {
+	return FindSubsetByAttributeOperator(root, attribute, AttributePrefix, prefix)
+}
+
+// FindSubsetByAttributeSuffix retrieves the HTML nodes that have the requested attribute with a
+// value ending with suffix.
+func FindSubsetByAttributeSuffix(root *html.Node, attribute string, suffix string) []*html.Node {
+	return FindSubsetByAttributeOperator(root, attribute, AttributeSuffix, suffix)
+}
+
+// FindSubsetByAttributeContains retrieves the HTML nodes that have the requested attribute with a
+// value containing substring.
+func FindSubsetByAttributeContains(root *html.Node, attribute string, substring string) []*html.Node {
+	return FindSubsetByAttributeOperator(root, attribute, AttributeContains, substring)
+}
+
+// FindSubsetByAttributeWord retrieves the HTML nodes that have the requested attribute with a
+// whitespace separated list of values that includes word, such as a rel attribute including
+// "nofollow".
+func FindSubsetByAttributeWord(root *html.Node, attribute string, word string) []*html.Node {
+	return FindSubsetByAttributeOperator(root, attribute, AttributeWord, word)
+}
+
+// FindSubsetByAttributeMatch retrieves the HTML nodes that have the requested attribute with a
+// value matched by pattern.
+func FindSubsetByAttributeMatch(root *html.Node, attribute string, pattern *regexp.Regexp) []*html.Node {
+	return scrape.FindAll(root, ByAttributeMatch(attribute, pattern))
+}
+
+// AttributeOperator compares attribute values, following the operators of CSS attribute selectors.
+type AttributeOperator string
+
+const (
+	// AttributeEquals matches values equal to the operand, as with [attr=value]
+	AttributeEquals AttributeOperator = "="
+	// AttributePrefix matches values starting with the operand, as with [attr^=value]
+	AttributePrefix AttributeOperator = "^="
+	// AttributeSuffix matches values ending with the operand, as with [attr$=value]
+	AttributeSuffix AttributeOperator = "$="
+	// AttributeContains matches values containing the operand, as with [attr*=value]
+	AttributeContains AttributeOperator = "*="
+	// AttributeWord matches whitespace separated lists including the operand, as with [attr~=value]
+	AttributeWord AttributeOperator = "~="
+	// AttributeLang matches values equal to the operand or starting with it followed by a
+	// hyphen, such as lang values of a language, as with [attr|=value]
+	AttributeLang AttributeOperator = "|="
+)
+
+// Match reports whether value satisfies the operator with the given operand. As in CSS, an empty
+// operand is never satisfied except by AttributeEquals and AttributeLang.
+func (op AttributeOperator) Match(value, operand string) bool {
+	switch op {
+	case AttributeEquals:
+		return value == operand
+	case AttributePrefix:
+		return operand != "" && strings.HasPrefix(value, operand)
+	case AttributeSuffix:
+		return operand != "" && strings.HasSuffix(value, operand)
+	case AttributeContains:
+		return operand != "" && strings.Contains(value, operand)
+	case AttributeWord:
+		for _, word := range strings.Fields(value) {
+			if word == operand {
+				return true
+			}
+		}
+		return false
+	case AttributeLang:
+		return value == operand || strings.HasPrefix(value, operand+"-")
+	default:
+		return false
+	}
+}
+
+// FindSubsetByAttributeOperator retrieves the HTML nodes that have the requested attribute with a
+// value satisfying op with the given operand.
+func FindSubsetByAttributeOperator(root *html.Node, attribute string, op AttributeOperator, operand string) []*html.Node {
+	return scrape.FindAll(root, ByAttributeOperator(attribute, op, operand))
 }
 
 // FindSubsetByTagName retrieves the HTML nodes with the given tagName
@@ -126,7 +517,20 @@ func FindSubsetByTagName(root *html.Node, tagName string) []*html.Node {
 	return scrape.FindAll(root, scrape.ByTag(atom.Lookup([]byte(tagName))))
 }
 
-func matchByAttribute(key, value string) scrape.Matcher {
+// FindWithin retrieves the nodes satisfying matcher among the descendants of the given nodes,
+// such as the price within each of a set of product cards, in document order and without
+// duplicates. As with the FindSubset functions, the descendants of a matched node are not
+// themselves considered. The matchers of the FindSubset functions are scrape.ById,
+// scrape.ByClass, scrape.ByTag, ByAttribute, ByAttributeOperator, ByAttributeMatch, and
+// Selector.Matcher. The other Find functions, such as FindByText and FindComments, search within
+// several nodes with Selection.FindWith. To search each node separately, use Selection.Each.
+func FindWithin(nodes []*html.Node, matcher scrape.Matcher) []*html.Node {
+	return Select(nodes...).Find(matcher).Nodes
+}
+
+// ByAttribute matches elements that have the given attribute, with the given value unless value
+// is empty, as with FindSubsetByAttributeNameValue.
+func ByAttribute(key, value string) scrape.Matcher {
 	return func(node *html.Node) bool {
 		if node.Type == html.ElementNode {
 			result := scrape.Attr(node, key)
@@ -137,3 +541,27 @@ func matchByAttribute(key, value string) scrape.Matcher {
 		return false
 	}
 }
+
+// ByAttributeOperator matches elements that have the given attribute with a value satisfying op
+// with the given operand, as with FindSubsetByAttributeOperator.
+func ByAttributeOperator(key string, op AttributeOperator, operand string) scrape.Matcher {
+	return matchAttributeValue(key, func(value string) bool {
+		return op.Match(value, operand)
+	})
+}
+
+// ByAttributeMatch matches elements that have the given attribute with a value matched by
+// pattern, as with FindSubsetByAttributeMatch.
+func ByAttributeMatch(key string, pattern *regexp.Regexp) scrape.Matcher {
+	return matchAttributeValue(key, pattern.MatchString)
+}
+
+func matchAttributeValue(key string, test func(value string) bool) scrape.Matcher {
+	return func(node *html.Node) bool {
+		if node.Type != html.ElementNode {
+			return false
+		}
+		value, ok := attrValue(node, key)
+		return ok && test(value)
+	}
+}
@@ -0,0 +1,18 @@
+package restify
+
+import "testing"
+
+// BenchmarkLoadBuffer measures parsing a buffer in place, whose bytes per operation do not
+// include a copy of the input.
+func BenchmarkLoadBuffer(b *testing.B) {
+	page := benchmarkPage(300)
+	b.SetBytes(int64(len(page)))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := LoadBuffer(page); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
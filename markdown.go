@@ -0,0 +1,631 @@
+package restify
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// MarkdownOptions adjusts how ToMarkdown converts HTML.
+type MarkdownOptions struct {
+	// BaseURL, when set, resolves relative link and image URLs
+	BaseURL *url.URL
+	// SkipImages omits images, keeping the text of links around them
+	SkipImages bool
+}
+
+// ToMarkdown converts the HTML at node, such as an extracted article, into GitHub flavored
+// Markdown. Headings, paragraphs, emphasis, links, images, lists, block quotes, code, and tables
+// are converted; the content of other elements is kept as text. Scripts, styles, forms, and
+// the document head are omitted. Text is escaped so that characters such as * and [ are not
+// mistaken for Markdown, and the result is valid UTF-8 without control characters.
+func ToMarkdown(node *html.Node, opts MarkdownOptions) string {
+	c := htmlConverter{markdown: opts}
+	return joinBlocks(c.blocks([]*html.Node{node}), "\n\n")
+}
+
+// markdownBlock is a block of converted Markdown.
+type markdownBlock struct {
+	text string
+	// list is set for lists, which are kept tight against the text of the item containing them
+	list bool
+}
+
+func joinBlocks(blocks []markdownBlock, separator string) string {
+	var b strings.Builder
+	for i, block := range blocks {
+		if i > 0 {
+			if block.list && separator == "\n" {
+				b.WriteString("\n")
+			} else {
+				b.WriteString(separator)
+			}
+		}
+		b.WriteString(block.text)
+	}
+	return b.String()
+}
+
+// htmlConverter converts HTML into Markdown, or into the plain text of ToText.
+type htmlConverter struct {
+	markdown MarkdownOptions
+	// plain selects plain text, configured by text, rather than Markdown
+	plain bool
+	text  TextOptions
+	// indent is the width of the prefixes of the blocks being converted, such as of list items
+	indent int
+	// footnotes are the link URLs of plain text, numbered in the order first referenced
+	footnotes       []string
+	footnoteNumbers map[string]int
+}
+
+// convertSkipped are the elements whose content is omitted from conversions.
+var convertSkipped = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Iframe: true, atom.Object: true, atom.Embed: true, atom.Svg: true, atom.Canvas: true,
+	atom.Input: true, atom.Select: true, atom.Textarea: true, atom.Button: true,
+}
+
+// convertContainers are the block elements whose content is converted as blocks in turn.
+var convertContainers = map[atom.Atom]bool{
+	atom.Html: true, atom.Body: true, atom.Div: true, atom.P: true, atom.Section: true,
+	atom.Article: true, atom.Main: true, atom.Header: true, atom.Footer: true, atom.Aside: true,
+	atom.Nav: true, atom.Figure: true, atom.Figcaption: true, atom.Form: true, atom.Fieldset: true,
+	atom.Legend: true, atom.Address: true, atom.Details: true, atom.Summary: true, atom.Center: true,
+	atom.Dl: true, atom.Dd: true, atom.Li: true, atom.Caption: true, atom.Hgroup: true,
+}
+
+// blocks converts nodes into blocks, gathering inline content into paragraphs.
+func (c *htmlConverter) blocks(nodes []*html.Node) []markdownBlock {
+	var blocks []markdownBlock
+	paragraph := inlineWriter{plain: c.plain}
+	flush := func() {
+		text := paragraph.String()
+		if c.plain {
+			text = wrapText(text, c.text.Width-c.indent)
+		} else {
+			text = escapeLineStarts(text)
+		}
+		if text != "" {
+			blocks = append(blocks, markdownBlock{text: text})
+		}
+		paragraph = inlineWriter{plain: c.plain}
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.DocumentNode {
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				walk(child)
+			}
+			return
+		}
+		if n.Type != html.ElementNode {
+			c.inline(n, &paragraph)
+			return
+		}
+
+		var block markdownBlock
+		switch {
+		case convertSkipped[n.DataAtom]:
+			return
+		case convertContainers[n.DataAtom]:
+			flush()
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				walk(child)
+			}
+			flush()
+			return
+		case headingLevel(n) > 0:
+			block.text = c.heading(n)
+		case n.DataAtom == atom.Dt:
+			block.text = c.inlineString(n)
+			if !c.plain && block.text != "" {
+				block.text = "**" + block.text + "**"
+			}
+		case n.DataAtom == atom.Pre && c.plain:
+			block.text = strings.TrimRight(strings.TrimPrefix(rawText(n), "\n"), "\n")
+		case n.DataAtom == atom.Pre:
+			block.text = codeBlock(n)
+		case n.DataAtom == atom.Blockquote:
+			c.indent += 2
+			block.text = prefixLines(joinBlocks(c.blocks(childNodes(n)), "\n\n"), "> ", ">")
+			c.indent -= 2
+		case n.DataAtom == atom.Ul || n.DataAtom == atom.Ol:
+			block.text, block.list = c.list(n), true
+		case n.DataAtom == atom.Table:
+			block.text = c.table(n)
+		case n.DataAtom == atom.Hr:
+			block.text = "---"
+		default:
+			c.inline(n, &paragraph)
+			return
+		}
+
+		flush()
+		if block.text != "" {
+			blocks = append(blocks, block)
+		}
+	}
+
+	for _, n := range nodes {
+		walk(n)
+	}
+	flush()
+	return blocks
+}
+
+func childNodes(n *html.Node) []*html.Node {
+	var children []*html.Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		children = append(children, child)
+	}
+	return children
+}
+
+// heading converts a heading, underlining the first two levels in plain text.
+func (c *htmlConverter) heading(n *html.Node) string {
+	text := c.singleLine(c.inlineString(n))
+	level := headingLevel(n)
+	switch {
+	case text == "":
+		return ""
+	case !c.plain:
+		return strings.Repeat("#", level) + " " + text
+	case level == 1:
+		return text + "\n" + strings.Repeat("=", utf8.RuneCountInString(text))
+	case level == 2:
+		return text + "\n" + strings.Repeat("-", utf8.RuneCountInString(text))
+	default:
+		return text
+	}
+}
+
+// singleLine replaces the line breaks of inline content with spaces.
+func (c *htmlConverter) singleLine(text string) string {
+	if c.plain {
+		return strings.Replace(text, "\n", " ", -1)
+	}
+	return strings.Replace(text, "\\\n", " ", -1)
+}
+
+func headingLevel(n *html.Node) int {
+	switch n.DataAtom {
+	case atom.H1:
+		return 1
+	case atom.H2:
+		return 2
+	case atom.H3:
+		return 3
+	case atom.H4:
+		return 4
+	case atom.H5:
+		return 5
+	case atom.H6:
+		return 6
+	}
+	return 0
+}
+
+// inline converts n as inline content, written to w.
+func (c *htmlConverter) inline(n *html.Node, w *inlineWriter) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		w.hardBreak()
+	case atom.Strong, atom.B:
+		c.emphasis(n, w, "**")
+	case atom.Em, atom.I, atom.Cite, atom.Var, atom.Dfn:
+		c.emphasis(n, w, "*")
+	case atom.Del, atom.S, atom.Strike:
+		c.emphasis(n, w, "~~")
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		code := strings.Join(strings.Fields(rawText(n)), " ")
+		if code != "" && c.plain {
+			c.emphasis(n, w, "")
+		} else if code != "" {
+			w.raw(codeSpan(code))
+		}
+	case atom.A:
+		c.link(n, w)
+	case atom.Img:
+		c.image(n, w)
+	default:
+		if convertSkipped[n.DataAtom] {
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			c.inline(child, w)
+		}
+	}
+}
+
+// inlineString converts the children of n as inline content.
+func (c *htmlConverter) inlineString(n *html.Node) string {
+	w := inlineWriter{plain: c.plain}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.inline(child, &w)
+	}
+	return w.String()
+}
+
+// emphasis wraps the content of n in marker, keeping surrounding whitespace outside of it as
+// Markdown requires.
+func (c *htmlConverter) emphasis(n *html.Node, w *inlineWriter, marker string) {
+	text := c.inlineString(n)
+	raw := rawText(n)
+	if strings.TrimLeftFunc(raw, unicode.IsSpace) != raw {
+		w.pending = true
+	}
+	if text == "" {
+		return
+	}
+	if c.plain {
+		marker = ""
+	}
+	w.raw(marker + text + marker)
+	w.pending = strings.TrimRightFunc(raw, unicode.IsSpace) != raw
+}
+
+func (c *htmlConverter) link(n *html.Node, w *inlineWriter) {
+	text := c.singleLine(c.inlineString(n))
+	href := strings.TrimSpace(scrape.Attr(n, "href"))
+	if href == "" || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		if text != "" {
+			w.raw(text)
+		}
+		return
+	}
+	if c.plain {
+		c.plainLink(text, href, w)
+		return
+	}
+	if text == "" {
+		text = escapeMarkdown(href)
+	}
+	w.raw("[" + text + "](" + c.destination(href) + markdownTitle(scrape.Attr(n, "title")) + ")")
+}
+
+func (c *htmlConverter) image(n *html.Node, w *inlineWriter) {
+	src := firstAttr(n, "src", "data-src")
+	if c.markdown.SkipImages || src == "" {
+		return
+	}
+	alt := strings.Join(strings.Fields(scrape.Attr(n, "alt")), " ")
+	if c.plain {
+		if alt != "" {
+			w.raw("[" + alt + "]")
+		}
+		return
+	}
+	alt = escapeMarkdown(alt)
+	w.raw("![" + alt + "](" + c.destination(src) + markdownTitle(scrape.Attr(n, "title")) + ")")
+}
+
+// destination formats a link destination, resolving it against the base URL.
+func (c *htmlConverter) destination(href string) string {
+	if c.markdown.BaseURL != nil {
+		if resolved, ok := resolveReference(c.markdown.BaseURL, href); ok {
+			href = resolved.String()
+		}
+	}
+	if strings.ContainsAny(href, " ()<>") {
+		href = strings.NewReplacer("<", "%3C", ">", "%3E").Replace(href)
+		return "<" + href + ">"
+	}
+	return href
+}
+
+func markdownTitle(title string) string {
+	title = strings.Join(strings.Fields(title), " ")
+	if title == "" {
+		return ""
+	}
+	return ` "` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(title) + `"`
+}
+
+func (c *htmlConverter) list(n *html.Node) string {
+	number := 1
+	if start, err := strconv.Atoi(scrape.Attr(n, "start")); err == nil {
+		number = start
+	}
+
+	var items []string
+	loose := false
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || convertSkipped[child.DataAtom] {
+			continue
+		}
+		marker := "- "
+		if c.plain {
+			marker = "* "
+		}
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+
+		c.indent += len(marker)
+		blocks := c.blocks([]*html.Node{child})
+		c.indent -= len(marker)
+		content := joinBlocks(blocks, "\n")
+		for i := 1; i < len(blocks); i++ {
+			if !blocks[i].list {
+				// paragraphs after the first must be separated by blank lines
+				content, loose = joinBlocks(blocks, "\n\n"), true
+				break
+			}
+		}
+		if content == "" {
+			continue
+		}
+		content = prefixLines(content, strings.Repeat(" ", len(marker)), "")
+		items = append(items, marker+strings.TrimPrefix(content, strings.Repeat(" ", len(marker))))
+	}
+
+	if loose {
+		return strings.Join(items, "\n\n")
+	}
+	return strings.Join(items, "\n")
+}
+
+func (c *htmlConverter) table(n *html.Node) string {
+	var rows [][]string
+	columns := 0
+	var caption string
+	var collect func(parent *html.Node)
+	collect = func(parent *html.Node) {
+		for child := parent.FirstChild; child != nil; child = child.NextSibling {
+			switch child.DataAtom {
+			case atom.Caption:
+				caption = c.inlineString(child)
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				collect(child)
+			case atom.Tr:
+				var row []string
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom != atom.Td && cell.DataAtom != atom.Th {
+						continue
+					}
+					text := c.singleLine(c.inlineString(cell))
+					if !c.plain {
+						text = strings.Replace(text, "|", `\|`, -1)
+					}
+					row = append(row, text)
+					span, _ := strconv.Atoi(scrape.Attr(cell, "colspan"))
+					for i := 1; i < span; i++ {
+						row = append(row, "")
+					}
+				}
+				if len(row) > columns {
+					columns = len(row)
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	collect(n)
+	if columns == 0 {
+		return caption
+	}
+
+	var b strings.Builder
+	if caption != "" {
+		b.WriteString(caption)
+		b.WriteString("\n\n")
+	}
+	if c.plain {
+		for _, row := range rows {
+			b.WriteString(strings.Join(row, " | ") + "\n")
+		}
+		return strings.TrimSuffix(b.String(), "\n")
+	}
+
+	writeRow := func(row []string) {
+		b.WriteString("|")
+		for i := 0; i < columns; i++ {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		b.WriteString("\n")
+	}
+	writeRow(rows[0])
+	b.WriteString(strings.Repeat("| --- ", columns) + "|\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// codeBlock converts a <pre> element into a fenced code block, with the language named by a
+// language- or lang- class of the element or of the <code> element within it.
+func codeBlock(pre *html.Node) string {
+	code := strings.TrimRight(strings.TrimPrefix(rawText(pre), "\n"), "\n")
+	if code == "" {
+		return ""
+	}
+
+	language := codeLanguage(pre)
+	if inner, ok := scrape.Find(pre, scrape.ByTag(atom.Code)); ok && language == "" {
+		language = codeLanguage(inner)
+	}
+	fenceLength := longestRun(code, '`') + 1
+	if fenceLength < 3 {
+		fenceLength = 3
+	}
+	fence := strings.Repeat("`", fenceLength)
+	return fence + language + "\n" + code + "\n" + fence
+}
+
+func codeLanguage(n *html.Node) string {
+	for _, class := range strings.Fields(scrape.Attr(n, "class")) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if strings.HasPrefix(class, prefix) {
+				return strings.TrimPrefix(class, prefix)
+			}
+		}
+	}
+	return ""
+}
+
+// codeSpan formats code inline, with enough backticks to enclose any within it.
+func codeSpan(code string) string {
+	fence := strings.Repeat("`", longestRun(code, '`')+1)
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+		code = " " + code + " "
+	}
+	return fence + code + fence
+}
+
+func longestRun(s string, c byte) int {
+	longest, run := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	return longest
+}
+
+// rawText is the text within n as written, with <br> elements as line breaks.
+func rawText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+		case n.DataAtom == atom.Br:
+			b.WriteString("\n")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+	return sanitizeText(b.String())
+}
+
+// prefixLines prefixes each line of text with prefix, or blankPrefix for blank lines.
+func prefixLines(text string, prefix string, blankPrefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = blankPrefix
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// inlineWriter accumulates inline Markdown, collapsing whitespace as a browser would.
+type inlineWriter struct {
+	b strings.Builder
+	// plain writes plain text rather than Markdown
+	plain bool
+	// pending is set when whitespace is to precede whatever is written next
+	pending bool
+}
+
+func (w *inlineWriter) text(s string) {
+	s = sanitizeText(s)
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		w.pending = w.pending || s != ""
+		return
+	}
+	w.pending = w.pending || strings.TrimLeftFunc(s, unicode.IsSpace) != s
+	for _, word := range words {
+		if !w.plain {
+			word = escapeMarkdown(word)
+		}
+		w.raw(word)
+		w.pending = true
+	}
+	w.pending = strings.TrimRightFunc(s, unicode.IsSpace) != s
+}
+
+// raw writes s, which is already Markdown.
+func (w *inlineWriter) raw(s string) {
+	if w.pending && w.b.Len() > 0 && !strings.HasSuffix(w.b.String(), "\n") {
+		w.b.WriteByte(' ')
+	}
+	w.pending = false
+	w.b.WriteString(s)
+}
+
+func (w *inlineWriter) hardBreak() {
+	if w.b.Len() > 0 && w.plain {
+		w.b.WriteString("\n")
+	} else if w.b.Len() > 0 {
+		w.b.WriteString("\\\n")
+	}
+	w.pending = false
+}
+
+func (w *inlineWriter) String() string {
+	text := strings.TrimSpace(w.b.String())
+	if w.plain {
+		return text
+	}
+	return strings.TrimSuffix(text, "\\")
+}
+
+// sanitizeText replaces invalid UTF-8 and removes control characters other than line breaks
+// and tabs.
+func sanitizeText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(s, "�"))
+}
+
+var (
+	markdownEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`,
+		"]", `\]`, "<", `\<`, "~", `\~`)
+	entityLike     = regexp.MustCompile(`&(#?[A-Za-z0-9]+;)`)
+	blockLineStart = regexp.MustCompile(`(?m)^(#{1,6}(?:\s|$)|[-+=>]|\d+[.)](?:\s|$))`)
+)
+
+// escapeMarkdown escapes the characters of text that Markdown would interpret.
+func escapeMarkdown(text string) string {
+	return entityLike.ReplaceAllString(markdownEscaper.Replace(text), `\&$1`)
+}
+
+// escapeLineStarts escapes the start of lines of a paragraph that Markdown would take to begin
+// a heading, list, or block quote.
+func escapeLineStarts(paragraph string) string {
+	return blockLineStart.ReplaceAllStringFunc(paragraph, func(start string) string {
+		if start[0] >= '0' && start[0] <= '9' {
+			i := strings.IndexAny(start, ".)")
+			return start[:i] + `\` + start[i:]
+		}
+		return `\` + start
+	})
+}
@@ -0,0 +1,84 @@
+package restify
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+)
+
+// MergeRegions stitches together the same region of several documents, such as an article's
+// body split across pages, into a single tree. The region is the elements matching the CSS
+// selector within each root, which contribute copies of their children, in order, to the
+// returned element. The returned element is a copy of the first region found, without its
+// children, and has no parent. Roots lacking the region are skipped, though it is an error for
+// all of them to lack it. The roots are not modified.
+func MergeRegions(roots []*html.Node, selector string) (*html.Node, error) {
+	s, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var merged *html.Node
+	for _, root := range roots {
+		for _, region := range scrape.FindAll(root, s.Matcher()) {
+			if merged == nil {
+				merged = copyElement(region)
+			}
+			for child := region.FirstChild; child != nil; child = child.NextSibling {
+				merged.AppendChild(cloneTree(child))
+			}
+		}
+	}
+	if merged == nil {
+		return nil, fmt.Errorf("Unable to find %q in any of the %d parts", selector, len(roots))
+	}
+	return merged, nil
+}
+
+// LoadMerged retrieves each of the parts of a document split across urls, in order, and
+// stitches together their regions matching the CSS selector with MergeRegions.
+func (l *Loader) LoadMerged(urls []*url.URL, selector string) (*html.Node, error) {
+	return l.LoadMergedContext(context.Background(), urls, selector)
+}
+
+// LoadMergedContext is like LoadMerged but the requests are bound to the given context, in
+// addition to the Loader's timeout, which applies to each part.
+func (l *Loader) LoadMergedContext(ctx context.Context, urls []*url.URL, selector string) (*html.Node, error) {
+	// fail before loading anything if the selector is invalid
+	if _, err := ParseSelector(selector); err != nil {
+		return nil, err
+	}
+
+	roots := make([]*html.Node, 0, len(urls))
+	for i, partUrl := range urls {
+		root, err := l.LoadContext(ctx, partUrl)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load part %d of %d: %w", i+1, len(urls), err)
+		}
+		roots = append(roots, root)
+	}
+	return MergeRegions(roots, selector)
+}
+
+// copyElement copies node without its children or relatives.
+func copyElement(node *html.Node) *html.Node {
+	return &html.Node{
+		Type:      node.Type,
+		DataAtom:  node.DataAtom,
+		Data:      node.Data,
+		Namespace: node.Namespace,
+		Attr:      append([]html.Attribute(nil), node.Attr...),
+	}
+}
+
+// cloneTree copies node along with all of its descendants.
+func cloneTree(node *html.Node) *html.Node {
+	clone := copyElement(node)
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		clone.AppendChild(cloneTree(child))
+	}
+	return clone
+}
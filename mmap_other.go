@@ -0,0 +1,12 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package restify
+
+import "golang.org/x/net/html"
+
+// LoadFileMmap parses the HTML file at path. Memory-mapping is not supported on this platform,
+// so it is equivalent to LoadPath.
+func LoadFileMmap(path string) (*html.Node, error) {
+	return LoadPath(path)
+}
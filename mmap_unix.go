@@ -0,0 +1,66 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package restify
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+
+	"golang.org/x/net/html"
+)
+
+// LoadFileMmap parses the HTML file at path by memory-mapping it, so that its content is read
+// directly from the page cache rather than copied into the heap, which suits multi-gigabyte
+// local dumps. The mapping is released before returning, since the parsed nodes hold their own
+// copies of the content. Compressed files are decompressed as LoadPath does, from the mapped
+// content. The file must not be truncated while it is loaded. On platforms without
+// memory-mapping, it is equivalent to LoadPath.
+func LoadFileMmap(path string) (*html.Node, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open file: %w", err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to stat file: %w", err)
+	}
+	size := info.Size()
+	if size == 0 {
+		// empty files cannot be mapped
+		return LoadBuffer(nil)
+	}
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("File %s is too large to map", path)
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to map file: %w", err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer syscall.Munmap(data)
+
+	var content io.Reader = bytes.NewReader(data)
+	if _, _, compressed := findCompression(data, path); compressed {
+		decompressed, closeContent, err := decompress(content, path)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read file %s: %w", path, err)
+		}
+		//goland:noinspection GoUnhandledErrorResult
+		defer closeContent()
+		content = decompressed
+	}
+
+	root, err := html.Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse file %s: %w", path, err)
+	}
+	return root, nil
+}
@@ -0,0 +1,93 @@
+package restify
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// NodePath describes the position of node within its document as an XPath-like path, such as
+// /html[1]/body[1]/div[2]/p[1], where each step names an element and its 1-based position among
+// its siblings of the same name. Text and comment nodes are named text() and comment(). The
+// path is relative to the topmost ancestor of node, usually the document, whose own path is /.
+//
+// A path can be resolved with NodeAt, such as in another process that has loaded the same
+// content. Since paths are positional, they stop resolving to the same element once the
+// content changes before or around it.
+func NodePath(node *html.Node) string {
+	var steps []string
+	for n := node; n.Parent != nil; n = n.Parent {
+		name := nodePathName(n)
+		position := 1
+		for s := n.PrevSibling; s != nil; s = s.PrevSibling {
+			if s.Type == n.Type && nodePathName(s) == name {
+				position++
+			}
+		}
+		steps = append(steps, name+"["+strconv.Itoa(position)+"]")
+	}
+
+	if len(steps) == 0 {
+		return "/"
+	}
+	var b strings.Builder
+	for i := len(steps) - 1; i >= 0; i-- {
+		b.WriteString("/")
+		b.WriteString(steps[i])
+	}
+	return b.String()
+}
+
+// NodeAt locates the node at the given path within root, as produced by NodePath. A step
+// without a position, such as div, refers to the first. If no node is at the path, then ok
+// will be false, and if the path is malformed, an error is returned.
+func NodeAt(root *html.Node, path string) (n *html.Node, ok bool, err error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false, fmt.Errorf("Invalid node path %q: must start with /", path)
+	}
+
+	n = root
+	for _, step := range strings.Split(strings.Trim(path, "/"), "/") {
+		if step == "" {
+			continue
+		}
+		name, position := step, 1
+		if open := strings.IndexByte(step, '['); open >= 0 && strings.HasSuffix(step, "]") {
+			name = step[:open]
+			if position, err = strconv.Atoi(step[open+1 : len(step)-1]); err != nil || position < 1 {
+				return nil, false, fmt.Errorf("Invalid position in node path %q at %q", path, step)
+			}
+		}
+
+		var next *html.Node
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if nodePathName(c) == name {
+				position--
+				if position == 0 {
+					next = c
+					break
+				}
+			}
+		}
+		if next == nil {
+			return nil, false, nil
+		}
+		n = next
+	}
+	return n, true, nil
+}
+
+func nodePathName(node *html.Node) string {
+	switch node.Type {
+	case html.TextNode:
+		return "text()"
+	case html.CommentNode:
+		return "comment()"
+	case html.DoctypeNode:
+		return "doctype()"
+	default:
+		return node.Data
+	}
+}
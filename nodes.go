@@ -0,0 +1,81 @@
+package restify
+
+import (
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+)
+
+// Attrs retrieves the attributes of the given node as a map of name to value. Attributes in a
+// namespace, such as xlink:href, are keyed by namespace and name separated by a colon. When an
+// attribute is repeated, the first occurrence wins, matching browser behavior.
+func Attrs(node *html.Node) map[string]string {
+	attrs := make(map[string]string, len(node.Attr))
+	for _, a := range node.Attr {
+		key := a.Key
+		if a.Namespace != "" {
+			key = a.Namespace + ":" + a.Key
+		}
+		if _, exists := attrs[key]; !exists {
+			attrs[key] = a.Val
+		}
+	}
+	return attrs
+}
+
+// Dataset retrieves the data-* attributes of the given node, keyed as the DOM dataset property
+// would be, such as data-product-id becoming productId.
+func Dataset(node *html.Node) map[string]string {
+	dataset := make(map[string]string)
+	for _, a := range node.Attr {
+		if a.Namespace != "" || !strings.HasPrefix(a.Key, "data-") {
+			continue
+		}
+		key := datasetKey(a.Key[len("data-"):])
+		if _, exists := dataset[key]; !exists {
+			dataset[key] = a.Val
+		}
+	}
+	return dataset
+}
+
+// datasetKey converts a dashed attribute suffix into camel case, leaving a dash that is not
+// followed by a lowercase letter as is.
+func datasetKey(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c == '-' && i+1 < len(name) && 'a' <= name[i+1] && name[i+1] <= 'z' {
+			b.WriteByte(name[i+1] - 'a' + 'A')
+			i++
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// Classes retrieves the distinct classes of the given node, in the order they are declared.
+func Classes(node *html.Node) []string {
+	fields := strings.Fields(scrape.Attr(node, "class"))
+	classes := fields[:0]
+	seen := make(map[string]bool, len(fields))
+	for _, c := range fields {
+		if !seen[c] {
+			seen[c] = true
+			classes = append(classes, c)
+		}
+	}
+	return classes
+}
+
+// HasClass reports whether the given node has className among its classes.
+func HasClass(node *html.Node, className string) bool {
+	for _, c := range strings.Fields(scrape.Attr(node, "class")) {
+		if c == className {
+			return true
+		}
+	}
+	return false
+}
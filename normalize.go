@@ -0,0 +1,289 @@
+package restify
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DateHints adjusts how ParseDate interprets text.
+type DateHints struct {
+	// Layouts are tried, as with time.Parse, before the built-in layouts
+	Layouts []string
+	// Location is used for dates without a time zone, time.UTC if nil
+	Location *time.Location
+	// DayFirst interprets ambiguous numeric dates such as 02/03/2024 as day/month/year
+	// rather than month/day/year
+	DayFirst bool
+	// Now is the reference time for relative dates such as "yesterday", time.Now if zero
+	Now time.Time
+}
+
+// dateLayouts are the layouts tried by ParseDate, most specific first.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"20060102",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.ANSIC,
+	"Monday, January 2, 2006 3:04 PM",
+	"Monday, January 2, 2006",
+	"Mon, January 2, 2006",
+	"Mon, Jan 2, 2006",
+	"Monday, 2 January 2006",
+	"Mon, 2 Jan 2006",
+	"January 2, 2006 3:04 PM",
+	"January 2, 2006 15:04",
+	"January 2, 2006",
+	"January 2 2006",
+	"Jan 2, 2006 3:04 PM",
+	"Jan 2, 2006",
+	"Jan 2 2006",
+	"2 January 2006 15:04",
+	"2 January 2006",
+	"2 Jan 2006 15:04",
+	"2 Jan 2006",
+	"02-Jan-2006",
+	"January 2006",
+	"Jan 2006",
+}
+
+var (
+	monthFirstLayouts = []string{"01/02/2006 15:04:05", "01/02/2006 15:04", "01/02/2006", "1/2/2006", "01-02-2006", "01.02.2006", "1/2/06"}
+	dayFirstLayouts   = []string{"02/01/2006 15:04:05", "02/01/2006 15:04", "02/01/2006", "2/1/2006", "02-01-2006", "02.01.2006", "2.1.2006", "2/1/06"}
+
+	ordinalSuffix = regexp.MustCompile(`\b(\d{1,2})(?:st|nd|rd|th)\b`)
+	relativeAgo   = regexp.MustCompile(`^(\d+|an?|one)\s+(second|minute|hour|day|week|month|year)s?\s+ago$`)
+	unixTimestamp = regexp.MustCompile(`^\d{10}(?:\d{3})?$`)
+	spaceRun      = regexp.MustCompile(`\s+`)
+)
+
+// ParseDate interprets scraped date text, such as "March 3rd, 2024", "2024-03-03T10:00:00Z",
+// "03/04/2024", "yesterday", "5 hours ago", or a Unix timestamp in seconds or milliseconds.
+func ParseDate(text string, hints DateHints) (time.Time, error) {
+	location := hints.Location
+	if location == nil {
+		location = time.UTC
+	}
+	now := hints.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	now = now.In(location)
+
+	cleaned := strings.TrimSpace(spaceRun.ReplaceAllString(text, " "))
+	cleaned = ordinalSuffix.ReplaceAllString(cleaned, "$1")
+	lower := strings.ToLower(cleaned)
+
+	switch lower {
+	case "now", "just now":
+		return now, nil
+	case "today":
+		return startOfDay(now), nil
+	case "yesterday":
+		return startOfDay(now).AddDate(0, 0, -1), nil
+	case "tomorrow":
+		return startOfDay(now).AddDate(0, 0, 1), nil
+	}
+	if match := relativeAgo.FindStringSubmatch(lower); match != nil {
+		amount, err := strconv.Atoi(match[1])
+		if err != nil {
+			// "a", "an", or "one"
+			amount = 1
+		}
+		switch match[2] {
+		case "second":
+			return now.Add(-time.Duration(amount) * time.Second), nil
+		case "minute":
+			return now.Add(-time.Duration(amount) * time.Minute), nil
+		case "hour":
+			return now.Add(-time.Duration(amount) * time.Hour), nil
+		case "day":
+			return now.AddDate(0, 0, -amount), nil
+		case "week":
+			return now.AddDate(0, 0, -7*amount), nil
+		case "month":
+			return now.AddDate(0, -amount, 0), nil
+		default:
+			return now.AddDate(-amount, 0, 0), nil
+		}
+	}
+	if unixTimestamp.MatchString(cleaned) {
+		value, _ := strconv.ParseInt(cleaned, 10, 64)
+		if len(cleaned) == 13 {
+			return time.Unix(0, value*int64(time.Millisecond)).In(location), nil
+		}
+		return time.Unix(value, 0).In(location), nil
+	}
+
+	layouts := append([]string(nil), hints.Layouts...)
+	layouts = append(layouts, dateLayouts...)
+	if hints.DayFirst {
+		layouts = append(layouts, dayFirstLayouts...)
+	} else {
+		layouts = append(layouts, monthFirstLayouts...)
+	}
+	for _, layout := range layouts {
+		if parsed, err := time.ParseInLocation(layout, cleaned, location); err == nil {
+			return parsed, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Unable to parse date %q", text)
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// decimalCommaLanguages are the languages that conventionally write 1.234,5 rather than 1,234.5
+var decimalCommaLanguages = map[string]bool{
+	"de": true, "fr": true, "es": true, "it": true, "pt": true, "nl": true, "ru": true, "uk": true,
+	"pl": true, "tr": true, "sv": true, "da": true, "fi": true, "nb": true, "no": true, "cs": true,
+	"sk": true, "hu": true, "ro": true, "bg": true, "el": true, "id": true, "vi": true, "hr": true,
+	"sl": true, "sr": true, "lt": true, "lv": true, "et": true, "ca": true,
+}
+
+// numberPattern locates the first number within text, including grouping and decimal separators.
+var numberPattern = regexp.MustCompile(`[-−(]?\s*\d(?:[\d.,'’]|[ \x{00a0}\x{202f}]\d{3}\b)*`)
+
+// ParseNumber interprets the first number within scraped text, such as "1,234.5", "1.234,5",
+// "1 234,5", "(12.00)", or "-3'000". The locale is a BCP-47 language tag whose conventions
+// decide which of comma and period is the decimal separator. When locale is empty, the last
+// separator is taken as the decimal separator if both are present, and otherwise a lone
+// separator followed by exactly three digits is taken as grouping thousands.
+func ParseNumber(text string, locale string) (float64, error) {
+	match := numberPattern.FindString(text)
+	if match == "" {
+		return 0, fmt.Errorf("Unable to find a number in %q", text)
+	}
+
+	negative := strings.HasPrefix(match, "-") || strings.HasPrefix(match, "−") ||
+		(strings.HasPrefix(match, "(") && strings.Contains(text[strings.Index(text, match):], ")"))
+
+	var digits strings.Builder
+	type separator struct {
+		char     rune
+		position int
+	}
+	var separators []separator
+	for _, r := range match {
+		switch {
+		case unicode.IsDigit(r):
+			digits.WriteRune(r)
+		case r == '.' || r == ',':
+			separators = append(separators, separator{r, digits.Len()})
+		}
+	}
+	// trailing separators are punctuation rather than part of the number
+	for len(separators) > 0 && separators[len(separators)-1].position == digits.Len() {
+		separators = separators[:len(separators)-1]
+	}
+
+	decimal := rune(0)
+	lang := primaryLanguage(NormalizeLanguageTag(locale))
+	switch {
+	case lang != "" && decimalCommaLanguages[lang]:
+		decimal = ','
+	case lang != "":
+		decimal = '.'
+	case len(separators) == 0:
+	default:
+		last := separators[len(separators)-1]
+		mixed := false
+		count := 0
+		for _, s := range separators {
+			if s.char != last.char {
+				mixed = true
+			} else {
+				count++
+			}
+		}
+		switch {
+		case mixed:
+			decimal = last.char
+		case count == 1 && digits.Len()-last.position != 3:
+			decimal = last.char
+		}
+	}
+
+	number := digits.String()
+	for i := len(separators) - 1; i >= 0; i-- {
+		if separators[i].char == decimal {
+			number = number[:separators[i].position] + "." + number[separators[i].position:]
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to parse number %q: %w", text, err)
+	}
+	if negative {
+		value = -value
+	}
+	return value, nil
+}
+
+// Price is a monetary amount parsed by ParsePrice.
+type Price struct {
+	// Amount is the numeric value of the price
+	Amount float64 `json:"amount"`
+	// Currency is the ISO 4217 code of the currency, or empty if none was indicated
+	Currency string `json:"currency,omitempty"`
+}
+
+// currencySymbols maps currency symbols, longest first where they overlap, to ISO 4217 codes.
+// Ambiguous symbols map to their most common use, such as $ to USD.
+var currencySymbols = []struct {
+	symbol string
+	code   string
+}{
+	{"US$", "USD"}, {"CA$", "CAD"}, {"C$", "CAD"}, {"A$", "AUD"}, {"AU$", "AUD"}, {"NZ$", "NZD"},
+	{"HK$", "HKD"}, {"S$", "SGD"}, {"R$", "BRL"}, {"MX$", "MXN"}, {"$", "USD"},
+	{"€", "EUR"}, {"£", "GBP"}, {"¥", "JPY"}, {"₹", "INR"}, {"₩", "KRW"}, {"₽", "RUB"},
+	{"₺", "TRY"}, {"₪", "ILS"}, {"₫", "VND"}, {"₱", "PHP"}, {"฿", "THB"}, {"zł", "PLN"},
+	{"Kč", "CZK"}, {"kr", "SEK"}, {"Fr.", "CHF"},
+}
+
+var currencyCode = regexp.MustCompile(`\b(USD|EUR|GBP|JPY|CNY|RMB|INR|KRW|RUB|CAD|AUD|NZD|CHF|SEK|NOK|DKK|PLN|CZK|HUF|BRL|MXN|TRY|ILS|HKD|SGD|ZAR|THB|PHP|VND|IDR|MYR|AED|SAR)\b`)
+
+// ParsePrice interprets scraped price text, such as "$1,299.99", "1.299,99 €", "EUR 12", or
+// "£5", into an amount and currency. ISO 4217 codes take precedence over symbols. The amount
+// is parsed as with ParseNumber without a locale.
+func ParsePrice(text string) (Price, error) {
+	amount, err := ParseNumber(text, "")
+	if err != nil {
+		return Price{}, fmt.Errorf("Unable to parse price %q: %w", text, err)
+	}
+
+	price := Price{Amount: amount}
+	if match := currencyCode.FindString(strings.ToUpper(text)); match != "" {
+		price.Currency = match
+		if match == "RMB" {
+			price.Currency = "CNY"
+		}
+	} else {
+		for _, c := range currencySymbols {
+			if strings.Contains(text, c.symbol) {
+				price.Currency = c.code
+				break
+			}
+		}
+	}
+	return price, nil
+}
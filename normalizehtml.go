@@ -0,0 +1,159 @@
+package restify
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DefaultVolatileSelectors locate the elements removed by Normalize unless NoDefaults is set,
+// which hold tokens that differ on every response, such as CSRF tokens and ASP.NET view state.
+var DefaultVolatileSelectors = []string{
+	"meta[name=csrf-token], meta[name=csrf-param], meta[name=_csrf], meta[name=_csrf_header]",
+	"input[type=hidden][name*=csrf], input[type=hidden][name*=CSRF], input[type=hidden][name*=xsrf]",
+	"input[name=authenticity_token], input[name=_token], input[name=__RequestVerificationToken]",
+	"input[name=__VIEWSTATE], input[name=__VIEWSTATEGENERATOR], input[name=__EVENTVALIDATION]",
+}
+
+// DefaultVolatileAttrs are the attributes removed by Normalize unless NoDefaults is set, which
+// differ on every response, such as the nonces of Content-Security-Policy.
+var DefaultVolatileAttrs = []string{"nonce", "data-nonce", "data-csrf", "data-csrf-token", "data-request-id"}
+
+// NormalizeOptions configures Normalize.
+type NormalizeOptions struct {
+	// RemoveSelectors are CSS selectors, as accepted by ParseSelector, locating elements to
+	// remove along with their content, in addition to DefaultVolatileSelectors, such as
+	// ".timestamp", "time", or "script" for pages whose inline scripts vary
+	RemoveSelectors []string
+	// RemoveAttrs are attributes to remove from every element, in addition to
+	// DefaultVolatileAttrs
+	RemoveAttrs []string
+	// NoDefaults skips DefaultVolatileSelectors and DefaultVolatileAttrs
+	NoDefaults bool
+	// KeepComments keeps comments, which are otherwise removed
+	KeepComments bool
+}
+
+// Normalize returns a copy of the tree at root in a canonical form, so that two fetches of the
+// same logical page render, and so hash, identically, such as for change detection and
+// deduplication. Volatile elements and attributes are removed as configured by options, as are
+// comments. The attributes of each element are sorted, as are the names within class
+// attributes. Runs of whitespace are collapsed to a single space, outside of pre and textarea
+// elements, and text that is only whitespace is removed. The tree at root is not modified.
+func Normalize(root *html.Node, options NormalizeOptions) (*html.Node, error) {
+	selectors := options.RemoveSelectors
+	removedAttrs := make(map[string]bool)
+	for _, attr := range options.RemoveAttrs {
+		removedAttrs[strings.ToLower(attr)] = true
+	}
+	if !options.NoDefaults {
+		selectors = append(append([]string(nil), DefaultVolatileSelectors...), selectors...)
+		for _, attr := range DefaultVolatileAttrs {
+			removedAttrs[attr] = true
+		}
+	}
+
+	normalized := cloneTree(root)
+	for _, selector := range selectors {
+		s, err := ParseSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range scrape.FindAll(normalized, s.Matcher()) {
+			if n.Parent != nil {
+				n.Parent.RemoveChild(n)
+			}
+		}
+	}
+	normalizeNode(normalized, removedAttrs, options.KeepComments, false)
+	return normalized, nil
+}
+
+// NormalizedHash computes the hex encoded SHA-256 of the rendering of the tree at root once
+// normalized by Normalize with the given options.
+func NormalizedHash(root *html.Node, options NormalizeOptions) (string, error) {
+	normalized, err := Normalize(root, options)
+	if err != nil {
+		return "", err
+	}
+	return documentHash(normalized), nil
+}
+
+// WithNormalizedHashing makes LoadIfChanged compare pages by NormalizedHash with the given
+// options, rather than by hash of their exact content, so that pages differing only in
+// volatile content such as tokens and timestamps are not considered changed.
+func WithNormalizedHashing(options NormalizeOptions) LoaderOption {
+	return func(l *Loader) {
+		l.hashOptions = &options
+	}
+}
+
+// normalizeNode normalizes the descendants and attributes of n in place.
+func normalizeNode(n *html.Node, removedAttrs map[string]bool, keepComments bool, preformatted bool) {
+	if n.Type == html.ElementNode {
+		n.Attr = normalizeAttrs(n.Attr, removedAttrs)
+		if n.DataAtom == atom.Pre || n.DataAtom == atom.Textarea {
+			preformatted = true
+		}
+	}
+
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		switch child.Type {
+		case html.CommentNode:
+			if !keepComments {
+				n.RemoveChild(child)
+			}
+		case html.TextNode:
+			// text left adjacent by removed elements is merged so that it renders the same
+			for next != nil && next.Type == html.TextNode {
+				child.Data += next.Data
+				following := next.NextSibling
+				n.RemoveChild(next)
+				next = following
+			}
+			if !preformatted {
+				child.Data = spaceRun.ReplaceAllString(child.Data, " ")
+				if strings.TrimSpace(child.Data) == "" {
+					n.RemoveChild(child)
+				}
+			}
+		default:
+			normalizeNode(child, removedAttrs, keepComments, preformatted)
+		}
+		child = next
+	}
+}
+
+// normalizeAttrs returns attrs without removed or duplicate attributes, sorted by namespace and
+// name, with the names of class attributes sorted.
+func normalizeAttrs(attrs []html.Attribute, removedAttrs map[string]bool) []html.Attribute {
+	normalized := make([]html.Attribute, 0, len(attrs))
+	seen := make(map[html.Attribute]bool)
+	for _, a := range attrs {
+		key := html.Attribute{Namespace: a.Namespace, Key: strings.ToLower(a.Key)}
+		if removedAttrs[key.Key] || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		a.Key = key.Key
+		a.Val = strings.TrimSpace(spaceRun.ReplaceAllString(a.Val, " "))
+		if a.Namespace == "" && a.Key == "class" {
+			names := strings.Fields(a.Val)
+			sort.Strings(names)
+			a.Val = strings.Join(names, " ")
+		}
+		normalized = append(normalized, a)
+	}
+	sort.Slice(normalized, func(i, j int) bool {
+		if normalized[i].Namespace != normalized[j].Namespace {
+			return normalized[i].Namespace < normalized[j].Namespace
+		}
+		return normalized[i].Key < normalized[j].Key
+	})
+	return normalized
+}
@@ -0,0 +1,43 @@
+package restify
+
+import (
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// WithNoscriptExpansion parses the content of the <noscript> elements of each page as
+// ExpandNoscript does, before the page is returned.
+func WithNoscriptExpansion() LoaderOption {
+	return func(l *Loader) {
+		l.transforms = append(l.transforms, ExpandNoscript)
+	}
+}
+
+// ExpandNoscript parses the content of each <noscript> element within root into nodes. The HTML
+// parser, like a browser with scripting enabled, leaves that content as a single text node, which
+// is replaced by the parsed nodes. Lazy loading fallbacks such as <noscript><img src="..."></noscript>
+// can then be found like any other element. The <noscript> elements themselves are kept.
+func ExpandNoscript(root *html.Node) {
+	// the content is parsed as a browser with scripting disabled would within <body>
+	context := &html.Node{Type: html.ElementNode, DataAtom: atom.Body, Data: "body"}
+	for _, noscript := range scrape.FindAllNested(root, scrape.ByTag(atom.Noscript)) {
+		text := noscript.FirstChild
+		if text == nil || text.Type != html.TextNode || text.NextSibling != nil {
+			// already parsed, such as by a parser with scripting disabled
+			continue
+		}
+
+		nodes, err := html.ParseFragmentWithOptions(strings.NewReader(text.Data), context,
+			html.ParseOptionEnableScripting(false))
+		if err != nil {
+			continue
+		}
+		noscript.RemoveChild(text)
+		for _, n := range nodes {
+			noscript.AppendChild(n)
+		}
+	}
+}
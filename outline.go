@@ -0,0 +1,120 @@
+package restify
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// OutlineNode summarizes an element and its structure, as produced by Outline.
+type OutlineNode struct {
+	// Tag is the element name, or #document for a document
+	Tag string `json:"tag"`
+	// Id is the id attribute of the element, if any
+	Id string `json:"id,omitempty"`
+	// Classes are the classes of the element, if any
+	Classes []string `json:"classes,omitempty"`
+	// Count is the number of consecutive sibling elements with the same tag, id, and classes
+	// condensed into this node. The children shown are those of the first.
+	Count int `json:"count"`
+	// Children is the number of child elements, including any beyond the outline's depth
+	Children int `json:"children"`
+	// Elements are the outlines of the child elements, omitted beyond the outline's depth
+	Elements []*OutlineNode `json:"elements,omitempty"`
+}
+
+// Outline produces a condensed view of the element structure under root, to help discover
+// selectors for a new site. Consecutive siblings with the same tag, id, and classes, such as
+// the items of a list, are condensed into a single OutlineNode. At most maxDepth levels of
+// elements below root are included, or all of them if maxDepth is zero.
+func Outline(root *html.Node, maxDepth int) *OutlineNode {
+	if maxDepth <= 0 {
+		maxDepth = -1
+	}
+	return outlineNode(root, maxDepth)
+}
+
+// outlineNode outlines node and the given number of levels below it, or all if negative.
+func outlineNode(node *html.Node, depth int) *OutlineNode {
+	outline := &OutlineNode{Tag: node.Data, Count: 1}
+	if node.Type == html.DocumentNode {
+		outline.Tag = "#document"
+	}
+	if id, ok := attrValue(node, "id"); ok {
+		outline.Id = id
+	}
+	outline.Classes = Classes(node)
+
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		outline.Children++
+		if depth == 0 {
+			continue
+		}
+
+		child := outlineNode(c, depth-1)
+		if last := len(outline.Elements) - 1; last >= 0 && outline.Elements[last].sameKind(child) {
+			outline.Elements[last].Count++
+			continue
+		}
+		outline.Elements = append(outline.Elements, child)
+	}
+	return outline
+}
+
+func (n *OutlineNode) sameKind(other *OutlineNode) bool {
+	if n.Tag != other.Tag || n.Id != other.Id || len(n.Classes) != len(other.Classes) {
+		return false
+	}
+	for i := range n.Classes {
+		if n.Classes[i] != other.Classes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Label renders the node as a selector, such as div#main.card, followed by the number of
+// condensed siblings and of children, such as "li.item ×20 (3 children)".
+func (n *OutlineNode) Label() string {
+	var b strings.Builder
+	b.WriteString(n.Tag)
+	if n.Id != "" {
+		b.WriteString("#")
+		b.WriteString(n.Id)
+	}
+	for _, c := range n.Classes {
+		b.WriteString(".")
+		b.WriteString(c)
+	}
+	if n.Count > 1 {
+		fmt.Fprintf(&b, " ×%d", n.Count)
+	}
+	switch n.Children {
+	case 0:
+	case 1:
+		b.WriteString(" (1 child)")
+	default:
+		fmt.Fprintf(&b, " (%d children)", n.Children)
+	}
+	return b.String()
+}
+
+// String renders the outline as indented text, one node per line.
+func (n *OutlineNode) String() string {
+	var b strings.Builder
+	n.write(&b, 0)
+	return b.String()
+}
+
+func (n *OutlineNode) write(b *strings.Builder, indent int) {
+	b.WriteString(strings.Repeat("  ", indent))
+	b.WriteString(n.Label())
+	b.WriteString("\n")
+	for _, e := range n.Elements {
+		e.write(b, indent+1)
+	}
+}
@@ -0,0 +1,584 @@
+// Package pdftext extracts the text of PDF documents, for loading PDF responses with
+// restify.WithPdfExtractor(pdftext.Extract).
+//
+// It understands just enough of PDF to recover the text drawn by content streams, so it adds no
+// dependencies. Text is recovered in the order the content streams are stored, which is usually
+// page order. Text drawn with fonts whose encodings are custom, as is common for embedded subset
+// fonts, cannot be recovered faithfully; for such documents supply a complete PDF library to
+// restify.WithPdfExtractor instead.
+package pdftext
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ErrEncrypted is returned for encrypted documents, whose streams cannot be read.
+var ErrEncrypted = errors.New("encrypted PDF documents are not supported")
+
+// headerSearchLength is how far into the content the %PDF- header may appear, since some
+// generators precede it with junk.
+const headerSearchLength = 1024
+
+// MaxStreamSize is the most content decompressed from each FlateDecode stream, so that a small
+// compressed stream cannot expand to exhaust memory. Content beyond it is ignored, as the
+// content streams of text rarely approach it.
+const MaxStreamSize = 16 << 20
+
+// wordSpacing is the TJ adjustment, in thousandths of a text space unit, beyond which a gap
+// between strings is taken as a space between words.
+const wordSpacing = 250
+
+// Extract reads the PDF document from reader and returns its text, with a line for each line
+// of text drawn. Each compressed stream is read up to MaxStreamSize.
+func Extract(reader io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read PDF document: %w", err)
+	}
+
+	header := data
+	if len(header) > headerSearchLength {
+		header = header[:headerSearchLength]
+	}
+	if !bytes.Contains(header, []byte("%PDF-")) {
+		return "", errors.New("not a PDF document")
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return "", ErrEncrypted
+	}
+
+	var text textWriter
+	for _, s := range findStreams(data) {
+		if !s.isContent() {
+			continue
+		}
+		content, ok := s.decode()
+		if !ok || bytes.Contains(content, []byte("begincmap")) {
+			continue
+		}
+		interpret(content, &text)
+		text.newline()
+	}
+	return text.String(), nil
+}
+
+// stream is a stream object of the document.
+type stream struct {
+	// dict is the source of the stream's dictionary
+	dict string
+	// data is the encoded content of the stream
+	data []byte
+}
+
+var (
+	streamKeyword = regexp.MustCompile(`stream\r?\n`)
+	directLength  = regexp.MustCompile(`/Length\s+(\d+)(\s+\d+\s+R)?`)
+	filterEntry   = regexp.MustCompile(`/Filter\s*(\[[^\]]*\]|/[^\s/\[<>]+)`)
+	filterName    = regexp.MustCompile(`/([^\s/\[\]<>]+)`)
+
+	// nonContent matches the dictionaries of streams known not to be content streams
+	nonContent = regexp.MustCompile(`/Subtype\s*/(Image|Type1C|CIDFontType0C|OpenType|XML)\b|` +
+		`/Type\s*/(XRef|ObjStm|Metadata|EmbeddedFile)\b|/Length[123]\b|/(FunctionType|PatternType|ShadingType)\b`)
+)
+
+// findStreams locates the stream objects of data, in the order they are stored.
+func findStreams(data []byte) []stream {
+	var streams []stream
+	offset := 0
+	for {
+		loc := streamKeyword.FindIndex(data[offset:])
+		if loc == nil {
+			return streams
+		}
+		start, bodyStart := offset+loc[0], offset+loc[1]
+		offset = bodyStart
+		if start >= 3 && string(data[start-3:start]) == "end" {
+			continue
+		}
+
+		dict, ok := precedingDict(data[:start])
+		if !ok {
+			continue
+		}
+
+		end := -1
+		if match := directLength.FindStringSubmatch(dict); match != nil && match[2] == "" {
+			if length, err := strconv.Atoi(match[1]); err == nil && bodyStart+length <= len(data) {
+				rest := bytes.TrimLeft(data[bodyStart+length:], "\r\n \t")
+				if bytes.HasPrefix(rest, []byte("endstream")) {
+					end = bodyStart + length
+				}
+			}
+		}
+		if end < 0 {
+			// the length is indirect or wrong, so rely on the endstream keyword
+			i := bytes.Index(data[bodyStart:], []byte("endstream"))
+			if i < 0 {
+				return streams
+			}
+			end = bodyStart + i
+			for end > bodyStart && (data[end-1] == '\n' || data[end-1] == '\r') {
+				end--
+			}
+		}
+
+		streams = append(streams, stream{dict: dict, data: data[bodyStart:end]})
+		offset = end
+	}
+}
+
+// precedingDict finds the dictionary that ends data, allowing for trailing whitespace.
+func precedingDict(data []byte) (string, bool) {
+	end := len(bytes.TrimRight(data, "\r\n \t\f\x00"))
+	if end < 2 || string(data[end-2:end]) != ">>" {
+		return "", false
+	}
+	depth := 0
+	for i := end - 1; i > 0; i-- {
+		switch {
+		case data[i] == '>' && data[i-1] == '>':
+			depth++
+			i--
+		case data[i] == '<' && data[i-1] == '<':
+			depth--
+			i--
+			if depth == 0 {
+				return string(data[i:end]), true
+			}
+		}
+	}
+	return "", false
+}
+
+// isContent reports whether the stream may be a page or form content stream.
+func (s *stream) isContent() bool {
+	return !nonContent.MatchString(s.dict)
+}
+
+// decode applies the stream's filters, reporting false if any of them is unsupported.
+func (s *stream) decode() ([]byte, bool) {
+	content := s.data
+	match := filterEntry.FindStringSubmatch(s.dict)
+	if match == nil {
+		return content, true
+	}
+
+	for _, name := range filterName.FindAllStringSubmatch(match[1], -1) {
+		var err error
+		switch name[1] {
+		case "FlateDecode", "Fl":
+			var r io.ReadCloser
+			r, err = zlib.NewReader(bytes.NewReader(content))
+			if err == nil {
+				// keep what can be read of truncated or corrupt streams
+				content, err = ioutil.ReadAll(io.LimitReader(r, MaxStreamSize))
+				if len(content) > 0 {
+					err = nil
+				}
+			}
+		case "ASCIIHexDecode", "AHx":
+			content, err = decodeHex(content)
+		case "ASCII85Decode", "A85":
+			content, err = decodeAscii85(content)
+		default:
+			return nil, false
+		}
+		if err != nil {
+			return nil, false
+		}
+	}
+	return content, true
+}
+
+func decodeHex(content []byte) ([]byte, error) {
+	if i := bytes.IndexByte(content, '>'); i >= 0 {
+		content = content[:i]
+	}
+	digits := bytes.Map(func(r rune) rune {
+		if strings.ContainsRune(" \t\r\n\f\x00", r) {
+			return -1
+		}
+		return r
+	}, content)
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	decoded := make([]byte, hex.DecodedLen(len(digits)))
+	_, err := hex.Decode(decoded, digits)
+	return decoded, err
+}
+
+func decodeAscii85(content []byte) ([]byte, error) {
+	content = bytes.TrimPrefix(bytes.TrimSpace(content), []byte("<~"))
+	if i := bytes.Index(content, []byte("~>")); i >= 0 {
+		content = content[:i]
+	}
+	decoded := make([]byte, 4*len(content)/5+4)
+	n, _, err := ascii85.Decode(decoded, content, true)
+	return decoded[:n], err
+}
+
+// textWriter accumulates extracted text, avoiding redundant spaces and blank lines.
+type textWriter struct {
+	b strings.Builder
+	// last is the last byte written
+	last byte
+}
+
+func (w *textWriter) write(s string) {
+	if s == "" {
+		return
+	}
+	w.b.WriteString(s)
+	w.last = s[len(s)-1]
+}
+
+func (w *textWriter) space() {
+	if w.last != 0 && w.last != ' ' && w.last != '\n' {
+		w.write(" ")
+	}
+}
+
+func (w *textWriter) newline() {
+	if w.last != 0 && w.last != '\n' {
+		w.write("\n")
+	}
+}
+
+func (w *textWriter) String() string {
+	lines := strings.Split(w.b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// operand is a value preceding an operator within a content stream.
+type operand struct {
+	number   float64
+	isNumber bool
+	str      []byte
+	isString bool
+	array    []operand
+}
+
+// interpret runs the text operators of a content stream, writing the text they show.
+func interpret(content []byte, w *textWriter) {
+	l := lexer{data: content}
+	var operands []operand
+	// y is the vertical position of the text line, and shownY that of the text last shown
+	var y, shownY float64
+	shown := false
+
+	number := func(i int) float64 {
+		if i < len(operands) {
+			return operands[i].number
+		}
+		return 0
+	}
+	show := func(o operand) {
+		if !o.isString {
+			return
+		}
+		if shown && y != shownY {
+			w.newline()
+		}
+		w.write(decodeString(o.str))
+		shownY, shown = y, true
+	}
+
+	for {
+		token, ok := l.next()
+		if !ok {
+			return
+		}
+		if !token.isOperator {
+			operands = append(operands, token.operand)
+			continue
+		}
+
+		switch token.operator {
+		case "BT":
+			y = 0
+			w.space()
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				y += number(len(operands) - 1)
+			}
+			w.space()
+		case "Tm":
+			if len(operands) >= 6 {
+				y = number(len(operands) - 1)
+			}
+			w.space()
+		case "T*":
+			w.newline()
+		case "Tj":
+			if len(operands) > 0 {
+				show(operands[len(operands)-1])
+			}
+		case "'", "\"":
+			w.newline()
+			if len(operands) > 0 {
+				show(operands[len(operands)-1])
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				for _, element := range operands[len(operands)-1].array {
+					if element.isNumber && element.number < -wordSpacing {
+						w.space()
+					}
+					show(element)
+				}
+			}
+		case "BI":
+			l.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+}
+
+// decodeString converts the bytes of a string shown in a content stream into text. Strings
+// beginning with a UTF-16 byte order mark are decoded as such, and all others are taken to use
+// the common Windows-1252 superset of the standard encodings.
+func decodeString(s []byte) string {
+	if len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff {
+		units := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+
+	decoded, err := charmap.Windows1252.NewDecoder().Bytes(s)
+	if err != nil {
+		return ""
+	}
+	return strings.Map(func(r rune) rune {
+		if r < ' ' {
+			return -1
+		}
+		return r
+	}, string(decoded))
+}
+
+// token is an operand or operator of a content stream.
+type token struct {
+	operand    operand
+	operator   string
+	isOperator bool
+}
+
+// lexer splits a content stream into tokens.
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// next returns the next token, or false at the end of the stream.
+func (l *lexer) next() (token, bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isWhitespace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			return token{operand: operand{str: l.literalString(), isString: true}}, true
+		case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+			l.skipDict()
+			return token{}, true
+		case c == '<':
+			return token{operand: operand{str: l.hexString(), isString: true}}, true
+		case c == '[':
+			l.pos++
+			return token{operand: operand{array: l.array()}}, true
+		case c == ']' || c == '>' || c == '{' || c == '}' || c == ')':
+			l.pos++
+		case c == '/':
+			l.pos++
+			l.word()
+			return token{}, true
+		default:
+			word := l.word()
+			if word == "" {
+				l.pos++
+				continue
+			}
+			if n, err := strconv.ParseFloat(word, 64); err == nil {
+				return token{operand: operand{number: n, isNumber: true}}, true
+			}
+			return token{operator: word, isOperator: true}, true
+		}
+	}
+	return token{}, false
+}
+
+// word reads a run of regular characters.
+func (l *lexer) word() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isWhitespace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// array reads the elements of an array whose opening bracket has been read.
+func (l *lexer) array() []operand {
+	var elements []operand
+	for l.pos < len(l.data) {
+		for l.pos < len(l.data) && isWhitespace(l.data[l.pos]) {
+			l.pos++
+		}
+		if l.pos < len(l.data) && l.data[l.pos] == ']' {
+			l.pos++
+			return elements
+		}
+		t, ok := l.next()
+		if !ok {
+			break
+		}
+		if !t.isOperator {
+			elements = append(elements, t.operand)
+		}
+	}
+	return elements
+}
+
+// literalString reads a string such as (Hello \(world\)), from its opening parenthesis.
+func (l *lexer) literalString() []byte {
+	var s []byte
+	depth := 0
+	for l.pos++; l.pos < len(l.data); l.pos++ {
+		c := l.data[l.pos]
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				l.pos++
+				return s
+			}
+			depth--
+		case '\\':
+			l.pos++
+			if l.pos >= len(l.data) {
+				return s
+			}
+			c = l.data[l.pos]
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// line continuation
+				if l.pos+1 < len(l.data) && l.data[l.pos+1] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					value := 0
+					for i := 0; i < 3 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						value = value*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					l.pos--
+					c = byte(value)
+				}
+			}
+		}
+		s = append(s, c)
+	}
+	return s
+}
+
+// hexString reads a string such as <48656c6c6f>, from its opening angle bracket.
+func (l *lexer) hexString() []byte {
+	l.pos++
+	end := bytes.IndexByte(l.data[l.pos:], '>')
+	if end < 0 {
+		end = len(l.data) - l.pos
+	}
+	decoded, _ := decodeHex(l.data[l.pos : l.pos+end])
+	l.pos += end + 1
+	return decoded
+}
+
+// skipDict skips over a dictionary, such as the properties of marked content.
+func (l *lexer) skipDict() {
+	depth := 0
+	for l.pos+1 < len(l.data) {
+		switch {
+		case l.data[l.pos] == '<' && l.data[l.pos+1] == '<':
+			depth++
+			l.pos += 2
+		case l.data[l.pos] == '>' && l.data[l.pos+1] == '>':
+			depth--
+			l.pos += 2
+			if depth == 0 {
+				return
+			}
+		case l.data[l.pos] == '(':
+			l.literalString()
+		default:
+			l.pos++
+		}
+	}
+	l.pos = len(l.data)
+}
+
+// skipInlineImage skips the parameters and data of an inline image, following its BI operator.
+func (l *lexer) skipInlineImage() {
+	i := bytes.Index(l.data[l.pos:], []byte("ID"))
+	if i < 0 {
+		l.pos = len(l.data)
+		return
+	}
+	l.pos += i + 2
+	for {
+		i = bytes.Index(l.data[l.pos:], []byte("EI"))
+		if i < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		at := l.pos + i
+		l.pos = at + 2
+		if isWhitespace(l.data[at-1]) && (l.pos == len(l.data) || isWhitespace(l.data[l.pos])) {
+			return
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package restify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostPolicy limits the load a Loader places on a single host.
+type HostPolicy struct {
+	// MaxConns is the number of requests to the host that may be in progress at once, including
+	// reading their response bodies. Zero means no limit.
+	MaxConns int
+	// Delay is the minimum time between starting requests to the host. Zero means no delay.
+	Delay time.Duration
+}
+
+type hostPolicy struct {
+	pattern string
+	policy  HostPolicy
+}
+
+// WithHostPolicy applies policy to each host matching pattern, which is either a host name such
+// as "example.com", a wildcard such as "*.example.com" matching its subdomains, or "*" matching
+// every host. Each matching host is limited separately. When several patterns match a host, the
+// first configured is used, and hosts matching no pattern are not limited.
+func WithHostPolicy(pattern string, policy HostPolicy) LoaderOption {
+	return func(l *Loader) {
+		l.hostPolicies = append(l.hostPolicies, hostPolicy{strings.ToLower(pattern), policy})
+	}
+}
+
+// WithTransportTuning adjusts the *http.Transport used by the Loader, such as to change its
+// keep-alive or idle connection settings. The tuning is applied to a copy of the transport, so
+// http.DefaultTransport, or one supplied WithTransport, is left unchanged. It has no effect if a
+// RoundTripper other than an *http.Transport was supplied WithTransport.
+func WithTransportTuning(tune func(transport *http.Transport)) LoaderOption {
+	return func(l *Loader) {
+		l.transportTunings = append(l.transportTunings, tune)
+	}
+}
+
+// tunedTransport applies the Loader's transport tunings, if any.
+func (l *Loader) tunedTransport() http.RoundTripper {
+	transport, ok := l.transport.(*http.Transport)
+	if !ok || len(l.transportTunings) == 0 {
+		return l.transport
+	}
+	transport = transport.Clone()
+	for _, tune := range l.transportTunings {
+		tune(transport)
+	}
+	return transport
+}
+
+// matchHostPattern reports whether host matches a pattern as described by WithHostPolicy.
+func matchHostPattern(pattern, host string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, "*."):
+		return strings.HasSuffix(host, pattern[1:])
+	default:
+		return host == pattern
+	}
+}
+
+// politeTransport enforces host policies around the requests of another RoundTripper.
+type politeTransport struct {
+	next     http.RoundTripper
+	policies []hostPolicy
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+// hostState tracks the requests in progress to, and the next permitted start time for, a host.
+type hostState struct {
+	conns chan struct{}
+	mu    sync.Mutex
+	next  time.Time
+}
+
+func newPoliteTransport(next http.RoundTripper, policies []hostPolicy) *politeTransport {
+	return &politeTransport{next: next, policies: policies, hosts: make(map[string]*hostState)}
+}
+
+func (t *politeTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	host := strings.ToLower(request.URL.Hostname())
+	policy, ok := t.policy(host)
+	if !ok {
+		return t.next.RoundTrip(request)
+	}
+	state := t.state(host, policy)
+	ctx := request.Context()
+
+	if state.conns != nil {
+		select {
+		case state.conns <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := func() {
+		if state.conns != nil {
+			<-state.conns
+		}
+	}
+
+	if err := state.wait(ctx, policy.Delay); err != nil {
+		release()
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(request)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+func (t *politeTransport) policy(host string) (HostPolicy, bool) {
+	for _, p := range t.policies {
+		if matchHostPattern(p.pattern, host) {
+			return p.policy, true
+		}
+	}
+	return HostPolicy{}, false
+}
+
+func (t *politeTransport) state(host string, policy HostPolicy) *hostState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state, ok := t.hosts[host]
+	if !ok {
+		state = &hostState{}
+		if policy.MaxConns > 0 {
+			state.conns = make(chan struct{}, policy.MaxConns)
+		}
+		t.hosts[host] = state
+	}
+	return state
+}
+
+// wait blocks until a request may start, reserving the following slot delay later.
+func (s *hostState) wait(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	now := time.Now()
+	start := s.next
+	if start.Before(now) {
+		start = now
+	}
+	s.next = start.Add(delay)
+	s.mu.Unlock()
+
+	if wait := start.Sub(now); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// releasingBody frees a host's connection slot once the response body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
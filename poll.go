@@ -0,0 +1,115 @@
+package restify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+)
+
+// ErrPollTimeout is returned when polling a page does not succeed within its timeout.
+var ErrPollTimeout = errors.New("timed out polling page")
+
+// PollUntil repeatedly loads the page at url, every interval, until an element matching matcher
+// appears, returning the first such element. Failed loads are retried at the next interval.
+// If timeout elapses first, ErrPollTimeout is returned, wrapped with the error of the last load
+// if it failed. A timeout of zero polls without a limit. The interval must be positive.
+func (l *Loader) PollUntil(url *url.URL, matcher scrape.Matcher, interval, timeout time.Duration) (*html.Node, error) {
+	return l.PollUntilContext(context.Background(), url, matcher, interval, timeout)
+}
+
+// PollUntilContext is like PollUntil but stops polling when ctx is done. A deadline of ctx is
+// a timeout, while canceling it returns ctx.Err(), wrapped with the error of the last load if it
+// failed.
+func (l *Loader) PollUntilContext(ctx context.Context, url *url.URL, matcher scrape.Matcher, interval, timeout time.Duration) (*html.Node, error) {
+	var found *html.Node
+	err := l.poll(ctx, url, interval, timeout, func(root *html.Node) (bool, error) {
+		var ok bool
+		found, ok = scrape.Find(root, matcher)
+		return ok, nil
+	})
+	return found, err
+}
+
+// PollUntilChanged repeatedly loads the page at url, every interval, until its content differs
+// from that of the first load, returning the changed document. Content is compared by the hash
+// of its rendering, or by NormalizedHash when the Loader was configured WithNormalizedHashing,
+// so that volatile content such as tokens and timestamps can be ignored, as LoadIfChanged
+// compares it. Timeouts, intervals, and failed loads are handled as with PollUntil.
+func (l *Loader) PollUntilChanged(url *url.URL, interval, timeout time.Duration) (*html.Node, error) {
+	return l.PollUntilChangedContext(context.Background(), url, interval, timeout)
+}
+
+// PollUntilChangedContext is like PollUntilChanged but stops polling when ctx is done, as
+// PollUntilContext does.
+func (l *Loader) PollUntilChangedContext(ctx context.Context, url *url.URL, interval, timeout time.Duration) (*html.Node, error) {
+	var original string
+	var loaded bool
+	var changed *html.Node
+	err := l.poll(ctx, url, interval, timeout, func(root *html.Node) (bool, error) {
+		hash, err := l.pageHash(root)
+		if err != nil {
+			return false, err
+		}
+		if !loaded {
+			original, loaded = hash, true
+			return false, nil
+		}
+		if hash == original {
+			return false, nil
+		}
+		changed = root
+		return true, nil
+	})
+	return changed, err
+}
+
+// poll loads the page at url every interval until done reports true for the document, or fails.
+func (l *Loader) poll(ctx context.Context, url *url.URL, interval, timeout time.Duration, done func(root *html.Node) (bool, error)) error {
+	if interval <= 0 {
+		return fmt.Errorf("Unable to poll at an interval of %s", interval)
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		root, err := l.LoadContext(ctx, url)
+		if err == nil {
+			ok, err := done(root)
+			if err != nil {
+				return err
+			}
+			if ok {
+				return nil
+			}
+		}
+		// the load may have failed because the context ended
+		if ctx.Err() == nil {
+			lastErr = err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			err := ctx.Err()
+			if err == context.DeadlineExceeded {
+				err = ErrPollTimeout
+			}
+			if lastErr != nil {
+				return fmt.Errorf("%w: %v", err, lastErr)
+			}
+			return err
+		}
+	}
+}
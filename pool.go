@@ -0,0 +1,67 @@
+package restify
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+// maxPooledBufferSize is the capacity above which buffers are left to the garbage collector
+// rather than pooled, so that one unusually large document does not pin its memory.
+const maxPooledBufferSize = 64 * 1024
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buffer *bytes.Buffer) {
+	if buffer.Cap() > maxPooledBufferSize {
+		return
+	}
+	buffer.Reset()
+	bufferPool.Put(buffer)
+}
+
+var sniffReaderPool = sync.Pool{
+	New: func() interface{} {
+		return bufio.NewReaderSize(nil, charsetSniffLength)
+	},
+}
+
+// getSniffReader provides a buffered reader of body able to peek at charsetSniffLength bytes.
+func getSniffReader(body io.Reader) *bufio.Reader {
+	reader := sniffReaderPool.Get().(*bufio.Reader)
+	reader.Reset(body)
+	return reader
+}
+
+func putSniffReader(reader *bufio.Reader) {
+	reader.Reset(nil)
+	sniffReaderPool.Put(reader)
+}
+
+var gzipReaderPool sync.Pool
+
+// getGzipReader provides a gzip reader of body, reusing the decompression state of earlier readers.
+func getGzipReader(body io.Reader) (*gzip.Reader, error) {
+	if reader, ok := gzipReaderPool.Get().(*gzip.Reader); ok {
+		if err := reader.Reset(body); err != nil {
+			gzipReaderPool.Put(reader)
+			return nil, err
+		}
+		return reader, nil
+	}
+	return gzip.NewReader(body)
+}
+
+func putGzipReader(reader *gzip.Reader) {
+	gzipReaderPool.Put(reader)
+}
@@ -0,0 +1,125 @@
+package restify
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// HeaderField is a single request header.
+type HeaderField struct {
+	Name  string
+	Value string
+}
+
+// BrowserProfile is a coherent set of request headers resembling those sent by a browser when
+// navigating to a page.
+type BrowserProfile struct {
+	// UserAgent is sent unless the Loader is configured WithUserAgent or WithUserAgentRotation
+	UserAgent string
+	// Headers are sent with each request, listed in the order the browser sends them
+	Headers []HeaderField
+}
+
+// ProfileChrome resembles Chrome on Windows.
+var ProfileChrome = BrowserProfile{
+	UserAgent: UserAgentChromeWindows,
+	Headers: []HeaderField{
+		{"sec-ch-ua", `"Not_A Brand";v="8", "Chromium";v="120", "Google Chrome";v="120"`},
+		{"sec-ch-ua-mobile", "?0"},
+		{"sec-ch-ua-platform", `"Windows"`},
+		{"upgrade-insecure-requests", "1"},
+		{"accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"},
+		{"sec-fetch-site", "none"},
+		{"sec-fetch-mode", "navigate"},
+		{"sec-fetch-user", "?1"},
+		{"sec-fetch-dest", "document"},
+		{"accept-encoding", "gzip, deflate"},
+		{"accept-language", "en-US,en;q=0.9"},
+	},
+}
+
+// ProfileFirefox resembles Firefox on Windows.
+var ProfileFirefox = BrowserProfile{
+	UserAgent: UserAgentFirefoxWindows,
+	Headers: []HeaderField{
+		{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8"},
+		{"Accept-Language", "en-US,en;q=0.5"},
+		{"Accept-Encoding", "gzip, deflate"},
+		{"Upgrade-Insecure-Requests", "1"},
+		{"Sec-Fetch-Dest", "document"},
+		{"Sec-Fetch-Mode", "navigate"},
+		{"Sec-Fetch-Site", "none"},
+		{"Sec-Fetch-User", "?1"},
+	},
+}
+
+// WithBrowserProfile sends the headers of profile, such as ProfileChrome or ProfileFirefox, with
+// each request in place of the default "accept: */*" header, since some CDNs block requests
+// that do not look like they come from a browser. Headers set by request configs take precedence.
+//
+// Browsers also advertise the br and zstd encodings, but only gzip and deflate are advertised
+// here since only those can be decoded. The Go HTTP client writes headers in its own order, so
+// the listed order is not reproduced on the wire.
+func WithBrowserProfile(profile BrowserProfile) LoaderOption {
+	return func(l *Loader) {
+		l.profile = &profile
+	}
+}
+
+// applyProfile sets the headers of the Loader's browser profile, if any, on request.
+func (l *Loader) applyProfile(request *http.Request) {
+	if l.profile == nil {
+		return
+	}
+	request.Header.Del("accept")
+	for _, field := range l.profile.Headers {
+		request.Header.Set(field.Name, field.Value)
+	}
+	if l.userAgent == "" && len(l.userAgents) == 0 && l.profile.UserAgent != "" {
+		request.Header.Set("user-agent", l.profile.UserAgent)
+	}
+}
+
+// decodeContentEncoding wraps the body of resp so that it is decompressed, for responses to
+// requests that set their own Accept-Encoding and so were not decompressed by the transport.
+// Bodies in other encodings, such as br, are read as they were received rather than failing
+// the load, as when the Loader decoded no encodings itself. The returned release function must
+// be called once the body has been read.
+func decodeContentEncoding(resp *http.Response) (io.Reader, func(), error) {
+	noRelease := func() {}
+	if resp.Uncompressed {
+		return resp.Body, noRelease, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return resp.Body, noRelease, nil
+
+	case "gzip", "x-gzip":
+		reader, err := getGzipReader(resp.Body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to decompress response body: %w", err)
+		}
+		return reader, func() { putGzipReader(reader) }, nil
+
+	case "deflate":
+		// deflate is meant to be zlib wrapped, but some servers send raw deflate data
+		buffered := bufio.NewReader(resp.Body)
+		if header, err := buffered.Peek(2); err == nil && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 &&
+			header[0]&0x0f == 8 {
+			reader, err := zlib.NewReader(buffered)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Failed to decompress response body: %w", err)
+			}
+			return reader, noRelease, nil
+		}
+		return flate.NewReader(buffered), noRelease, nil
+
+	default:
+		return resp.Body, noRelease, nil
+	}
+}
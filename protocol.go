@@ -0,0 +1,130 @@
+package restify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/http2"
+)
+
+// Protocol selects the version of HTTP used by a Loader.
+type Protocol string
+
+const (
+	// ProtocolAuto negotiates HTTP/2 with servers supporting it over TLS, and otherwise uses HTTP/1.1
+	ProtocolAuto Protocol = ""
+	// ProtocolHttp1 always uses HTTP/1.1
+	ProtocolHttp1 Protocol = "HTTP/1.1"
+	// ProtocolHttp2 always uses HTTP/2 over TLS, failing for servers that do not support it
+	ProtocolHttp2 Protocol = "HTTP/2"
+	// ProtocolH2c uses HTTP/2 without TLS, known as h2c, for http URLs, and HTTP/2 over TLS for
+	// https URLs
+	ProtocolH2c Protocol = "h2c"
+)
+
+// WithProtocol selects the version of HTTP used for requests, in place of ProtocolAuto. Some
+// servers and the proxies in front of them behave differently depending on the protocol. The
+// protocol actually used is reported by Response.Proto.
+//
+// The protocol applies when the Loader's transport is an *http.Transport, whose settings, such
+// as those of WithProxy and WithTransportTuning, are kept. HTTP/2 over TLS works through HTTPS
+// proxies, but h2c cannot be proxied, so its requests fail when the transport would send them
+// through a proxy. HTTP/3 is not built in, since it needs a QUIC implementation; supply an
+// HTTP/3 RoundTripper, such as that of github.com/quic-go/quic-go, WithTransport instead.
+func WithProtocol(protocol Protocol) LoaderOption {
+	return func(l *Loader) {
+		l.protocol = protocol
+	}
+}
+
+// protocolTransport adapts transport to use the Loader's protocol.
+func (l *Loader) protocolTransport(transport http.RoundTripper) http.RoundTripper {
+	base, ok := transport.(*http.Transport)
+	if !ok || l.protocol == ProtocolAuto {
+		return transport
+	}
+
+	switch l.protocol {
+	case ProtocolHttp1:
+		base = base.Clone()
+		base.ForceAttemptHTTP2 = false
+		// a non-nil empty map disables the transport's own HTTP/2 support
+		base.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		if base.TLSClientConfig != nil {
+			base.TLSClientConfig.NextProtos = withoutProto(base.TLSClientConfig.NextProtos, http2.NextProtoTLS)
+		}
+		return base
+
+	case ProtocolHttp2:
+		return requireHttp2(forceHttp2(base))
+
+	case ProtocolH2c:
+		overTls := requireHttp2(forceHttp2(base))
+		dial := base.DialContext
+		if dial == nil {
+			dial = (&net.Dialer{}).DialContext
+		}
+		cleartext := &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(ctx, network, addr)
+			},
+		}
+		return roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			if request.URL.Scheme != "http" {
+				return overTls.RoundTrip(request)
+			}
+			if base.Proxy != nil {
+				proxyUrl, err := base.Proxy(request)
+				if err != nil {
+					return nil, err
+				}
+				if proxyUrl != nil {
+					return nil, fmt.Errorf("Unable to send h2c request to %s through proxy %s", request.URL.Host, proxyUrl.Redacted())
+				}
+			}
+			return cleartext.RoundTrip(request)
+		})
+
+	default:
+		return transport
+	}
+}
+
+// forceHttp2 returns a copy of base that negotiates HTTP/2 over TLS, even when base has a custom
+// dialer or TLS configuration, which otherwise disable it.
+func forceHttp2(base *http.Transport) *http.Transport {
+	base = base.Clone()
+	base.ForceAttemptHTTP2 = true
+	if base.TLSNextProto != nil && len(base.TLSNextProto) == 0 {
+		// a non-nil empty map disables the transport's own HTTP/2 support
+		base.TLSNextProto = nil
+	}
+	return base
+}
+
+// requireHttp2 fails the requests of transport that do not use HTTP/2.
+func requireHttp2(transport http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		resp, err := transport.RoundTrip(request)
+		if err != nil || resp.ProtoMajor == 2 {
+			return resp, err
+		}
+		//goland:noinspection GoUnhandledErrorResult
+		resp.Body.Close()
+		return nil, fmt.Errorf("Server %s responded with %s rather than HTTP/2", request.URL.Host, resp.Proto)
+	})
+}
+
+func withoutProto(protos []string, remove string) []string {
+	var kept []string
+	for _, proto := range protos {
+		if proto != remove {
+			kept = append(kept, proto)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,74 @@
+package restify
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// WithRawBody retains the content of each response in Response.Body, as it was received, so
+// that it can be stored for audits or parsed again later, such as with different options,
+// without fetching it again.
+func WithRawBody() LoaderOption {
+	return func(l *Loader) {
+		l.retainBody = true
+	}
+}
+
+// WithBodyTee streams the content of each response, as it was received, to the writer returned
+// by open, such as a file in an archive of the crawl. The Response given to open has its URL,
+// StatusCode, Proto, and Header set, but not yet its content. When open returns nil the content of
+// that response is not streamed, and when the writer is also an io.Closer it is closed once the
+// content has been read. Failing to write the content fails the fetch.
+func WithBodyTee(open func(resp *Response) io.Writer) LoaderOption {
+	return func(l *Loader) {
+		l.bodyTee = open
+	}
+}
+
+// rawBody captures the content of a response for WithRawBody and WithBodyTee.
+type rawBody struct {
+	reader   io.Reader
+	retained *bytes.Buffer
+	tee      io.Writer
+}
+
+// captureBody wraps body so that, as it is read, the content is retained or streamed as the
+// Loader is configured to. It returns nil if the Loader does neither.
+func (l *Loader) captureBody(resp *Response, body io.Reader) *rawBody {
+	capture := &rawBody{reader: body}
+	var writers []io.Writer
+	if l.retainBody {
+		capture.retained = new(bytes.Buffer)
+		writers = append(writers, capture.retained)
+	}
+	if l.bodyTee != nil {
+		if capture.tee = l.bodyTee(resp); capture.tee != nil {
+			writers = append(writers, capture.tee)
+		}
+	}
+	if len(writers) == 0 {
+		return nil
+	}
+	capture.reader = io.TeeReader(body, io.MultiWriter(writers...))
+	return capture
+}
+
+// finish reads whatever of the content was left unread by parsing, so that all of it is
+// captured, and then sets Response.Body and closes the tee writer.
+func (c *rawBody) finish(resp *Response) error {
+	_, err := io.Copy(ioutil.Discard, c.reader)
+	if closer, ok := c.tee.(io.Closer); ok {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to capture response body: %w", err)
+	}
+	if c.retained != nil {
+		resp.Body = c.retained.Bytes()
+	}
+	return nil
+}
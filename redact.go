@@ -0,0 +1,239 @@
+package restify
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+)
+
+// PiiDetector finds a kind of personal information within text, for RedactPii.
+type PiiDetector struct {
+	// Name describes the kind of information, such as "email"
+	Name string
+	// Pattern matches candidate occurrences of the information
+	Pattern *regexp.Regexp
+	// Valid, when set, confirms that a match of Pattern is an occurrence, such as by checksum
+	Valid func(match string) bool
+}
+
+// Built-in detectors, which err on the side of redacting too much.
+var (
+	// DetectEmails finds email addresses, excluding file names such as "logo@2x.png"
+	DetectEmails = PiiDetector{
+		Name:    "email",
+		Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}\b`),
+		Valid:   emailValid,
+	}
+	// DetectCardNumbers finds payment card numbers, which are confirmed by their Luhn checksum
+	DetectCardNumbers = PiiDetector{
+		Name:    "card",
+		Pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		Valid:   luhnValid,
+	}
+	// DetectPhoneNumbers finds telephone numbers of 7 to 15 digits, which are written in groups
+	// or with a leading +, excluding ones that are written as dates, ranges of years, or numbers
+	// with thousands separators
+	DetectPhoneNumbers = PiiDetector{
+		Name: "phone",
+		Pattern: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?(?:\(\d{1,4}\)[ .-]?)?|\(\d{1,4}\)[ .-]?|\b)\d{1,4}(?:[ .-]\d{2,4}){1,5}\b` +
+			`|\+\d{7,15}\b`),
+		Valid: phoneValid,
+	}
+	// DetectIpAddresses finds IPv4 addresses
+	DetectIpAddresses = PiiDetector{
+		Name:    "ip",
+		Pattern: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`),
+	}
+)
+
+// DefaultPiiDetectors are the detectors applied by Redact, in the order applied.
+var DefaultPiiDetectors = []PiiDetector{DetectEmails, DetectCardNumbers, DetectIpAddresses, DetectPhoneNumbers}
+
+// PiiAttributes are the attributes whose values RedactPii scrubs, those holding text shown to
+// visitors or describing the page, along with every data-* attribute. Other attributes, such as
+// src, hold URLs and identifiers that redacting would break, except for the addresses and
+// numbers of mailto: and tel: hrefs, which are scrubbed too.
+var PiiAttributes = []string{"alt", "title", "content", "value"}
+
+// piiSchemes are the schemes of hrefs whose remainder RedactPii scrubs.
+var piiSchemes = []string{"mailto:", "tel:"}
+
+// Patterns of numbers that resemble phone numbers: numeric dates, optionally followed by the
+// hour of a time, ranges of years, and numbers grouped by thousands separators.
+var (
+	datePattern      = regexp.MustCompile(`^\d{1,4}[-./]\d{1,2}[-./]\d{1,4}(?:[ T]\d{1,2})?$`)
+	yearRangePattern = regexp.MustCompile(`^(?:1[89]|20)\d\d ?- ?(?:1[89]|20)\d\d$`)
+	thousandsPattern = regexp.MustCompile(`^\d{1,3}(?:[. ]\d{3})+$`)
+)
+
+// assetEmailPattern matches the domains of email-like file names, such as the "2x.png" of
+// "logo@2x.png" naming an image for high density displays, or a domain ending in a file extension.
+var assetEmailPattern = regexp.MustCompile(`(?i)^(?:\d+(?:\.\d+)?x\.|.*\.(?:png|jpe?g|gif|svg|webp|avif|ico|bmp|css|js)$)`)
+
+// Redact scrubs personal information from the tree at root before it is stored. Each element
+// matching one of the CSS selectors, such as ".author" or "[data-user]", has its content and
+// attributes other than id and class replaced by placeholder, or is removed if placeholder is
+// empty. Then the text matched by DefaultPiiDetectors is replaced as RedactPii does.
+func Redact(root *html.Node, selectors []string, placeholder string) error {
+	for _, selector := range selectors {
+		s, err := ParseSelector(selector)
+		if err != nil {
+			return err
+		}
+		for _, n := range scrape.FindAll(root, s.Matcher()) {
+			if n.Parent == nil {
+				continue
+			}
+			if placeholder == "" {
+				n.Parent.RemoveChild(n)
+				continue
+			}
+			maskElement(n, placeholder)
+		}
+	}
+
+	RedactPii(root, placeholder, DefaultPiiDetectors...)
+	return nil
+}
+
+// maskElement replaces the content of n with placeholder, keeping only its id and class.
+func maskElement(n *html.Node, placeholder string) {
+	for child := n.FirstChild; child != nil; child = n.FirstChild {
+		n.RemoveChild(child)
+	}
+	n.AppendChild(&html.Node{Type: html.TextNode, Data: placeholder})
+
+	var kept []html.Attribute
+	for _, a := range n.Attr {
+		if a.Namespace == "" && (a.Key == "id" || a.Key == "class") {
+			kept = append(kept, a)
+		}
+	}
+	n.Attr = kept
+}
+
+// RedactPii replaces each occurrence of personal information found by detectors within the
+// text, comments, PiiAttributes, and mailto: and tel: hrefs of the tree at root with
+// placeholder, which may be empty to delete them. The number of a tel: href is a phone number as
+// a whole, so is replaced when a detector named "phone" accepts it, even if written without the
+// groups the detector's Pattern looks for.
+func RedactPii(root *html.Node, placeholder string, detectors ...PiiDetector) {
+	redact := func(text string) string {
+		for _, d := range detectors {
+			text = d.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+				if d.Valid != nil && !d.Valid(match) {
+					return match
+				}
+				return placeholder
+			})
+		}
+		return text
+	}
+
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode, html.CommentNode:
+			n.Data = redact(n.Data)
+		case html.ElementNode:
+			for i, a := range n.Attr {
+				switch {
+				case a.Namespace != "":
+				case piiAttribute(a.Key):
+					n.Attr[i].Val = redact(a.Val)
+				case strings.EqualFold(a.Key, "href"):
+					n.Attr[i].Val = redactHref(a.Val, placeholder, detectors, redact)
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+	visit(root)
+}
+
+// redactHref scrubs the address or number of a mailto: or tel: href with redact, leaving other
+// hrefs as they are.
+func redactHref(href string, placeholder string, detectors []PiiDetector, redact func(string) string) string {
+	trimmed := strings.TrimSpace(href)
+	for _, scheme := range piiSchemes {
+		if len(trimmed) < len(scheme) || !strings.EqualFold(trimmed[:len(scheme)], scheme) {
+			continue
+		}
+		rest := trimmed[len(scheme):]
+		if unescaped, err := url.PathUnescape(rest); err == nil {
+			// addresses such as jane%40example.com are only found once unescaped
+			rest = unescaped
+		}
+		if scheme == "tel:" {
+			for _, d := range detectors {
+				if d.Name == "phone" && (d.Valid == nil || d.Valid(rest)) {
+					return trimmed[:len(scheme)] + placeholder
+				}
+			}
+		}
+		if redacted := redact(rest); redacted != rest {
+			return trimmed[:len(scheme)] + redacted
+		}
+		return href
+	}
+	return href
+}
+
+// piiAttribute reports whether the attribute named key is one of PiiAttributes or a data-*
+// attribute.
+func piiAttribute(key string) bool {
+	if len(key) > len("data-") && strings.EqualFold(key[:len("data-")], "data-") {
+		return true
+	}
+	for _, attribute := range PiiAttributes {
+		if strings.EqualFold(key, attribute) {
+			return true
+		}
+	}
+	return false
+}
+
+// emailValid reports whether a match of DetectEmails is an address rather than a file name.
+func emailValid(match string) bool {
+	return !assetEmailPattern.MatchString(match[strings.LastIndexByte(match, '@')+1:])
+}
+
+// luhnValid reports whether the digits of number satisfy the Luhn checksum.
+func luhnValid(number string) bool {
+	sum, count := 0, 0
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if count%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		count++
+	}
+	return count >= 13 && sum%10 == 0
+}
+
+// phoneValid reports whether a match of DetectPhoneNumbers has the digits of a telephone number
+// and is not another kind of number.
+func phoneValid(match string) bool {
+	digits := 0
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	match = strings.TrimSpace(match)
+	return digits >= 7 && digits <= 15 && !datePattern.MatchString(match) &&
+		!yearRangePattern.MatchString(match) && !thousandsPattern.MatchString(match)
+}
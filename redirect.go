@@ -0,0 +1,123 @@
+package restify
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// WithHtmlRedirects follows up to maxHops redirects expressed within pages, rather than by
+// HTTP status, as detected by FindHtmlRedirect. Each hop is reported in Response.Redirects.
+// By default, such pages are returned as-is.
+func WithHtmlRedirects(maxHops int) LoaderOption {
+	return func(l *Loader) {
+		l.maxHtmlRedirects = maxHops
+	}
+}
+
+var (
+	// metaRefreshUrl extracts the target of a refresh content value such as "0; url='/next'"
+	metaRefreshUrl = regexp.MustCompile(`(?i)^\s*\d*(?:\.\d*)?\s*[;,]?\s*(?:url\s*=\s*)?['"]?([^'"]*)['"]?\s*$`)
+	// scriptRedirect matches the common forms of navigating with JavaScript, such as
+	// location.replace("/next") and window.location.href = '/next', as statements of their own
+	scriptRedirect = regexp.MustCompile(`(?:^|[;\n])\s*((?:window\.|document\.|top\.|self\.)?\blocation(?:\.href)?\s*(?:=\s*|\.replace\(\s*|\.assign\(\s*)(['"])([^'"]+)['"])`)
+)
+
+// FindHtmlRedirect detects a page that redirects by way of a <meta http-equiv="refresh">
+// element or a script that sets the window location, returning the target resolved against
+// the page's url. The kind is RedirectMetaRefresh or RedirectJavaScript accordingly. Only
+// scripts navigating when they run are recognized, not those navigating within functions or
+// event handlers. Targets must be http or https URLs, or for pages that are themselves file
+// URLs, file URLs.
+func FindHtmlRedirect(root *html.Node, pageUrl *url.URL) (target *url.URL, kind RedirectKind, ok bool) {
+	refresh, found := scrape.Find(root, func(n *html.Node) bool {
+		return n.DataAtom == atom.Meta && strings.EqualFold(scrape.Attr(n, "http-equiv"), "refresh")
+	})
+	if found {
+		if match := metaRefreshUrl.FindStringSubmatch(scrape.Attr(refresh, "content")); match != nil && match[1] != "" {
+			if target, ok := resolveReference(pageUrl, match[1]); ok && followable(pageUrl, target) {
+				return target, RedirectMetaRefresh, true
+			}
+		}
+	}
+
+	for _, script := range scrape.FindAll(root, scrape.ByTag(atom.Script)) {
+		if script.FirstChild == nil {
+			continue
+		}
+		code := script.FirstChild.Data
+		for _, match := range scriptRedirect.FindAllStringSubmatchIndex(code, -1) {
+			if !topLevel(code, match[2]) {
+				continue
+			}
+			if target, ok := resolveReference(pageUrl, code[match[6]:match[7]]); ok && followable(pageUrl, target) {
+				return target, RedirectJavaScript, true
+			}
+		}
+	}
+
+	return nil, "", false
+}
+
+// topLevel reports whether the position at offset within a script is outside of any block, such
+// as the body of a function, and outside of strings and comments.
+func topLevel(code string, offset int) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < offset; i++ {
+		c := code[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '/' && i+1 < len(code) && code[i+1] == '/':
+			end := strings.IndexByte(code[i:], '\n')
+			if end < 0 || i+end >= offset {
+				return false
+			}
+			i += end
+		case c == '/' && i+1 < len(code) && code[i+1] == '*':
+			end := strings.Index(code[i+2:], "*/")
+			if end < 0 || i+2+end+2 > offset {
+				return false
+			}
+			i += 2 + end + 1
+		case c == '{' || c == '(' || c == '[':
+			depth++
+		case c == '}' || c == ')' || c == ']':
+			depth--
+		}
+	}
+	return depth == 0 && quote == 0
+}
+
+// followable reports whether the page at from may lead a Loader to load target. Targets must be
+// http or https URLs, unless from is itself a file URL, so that remote pages cannot read local
+// files.
+func followable(from *url.URL, target *url.URL) bool {
+	switch target.Scheme {
+	case "http", "https":
+		return true
+	case "file":
+		return from.Scheme == "file"
+	}
+	return false
+}
+
+// resolveReference parses ref and resolves it against base.
+func resolveReference(base *url.URL, ref string) (*url.URL, bool) {
+	parsed, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return nil, false
+	}
+	return base.ResolveReference(parsed), true
+}
@@ -0,0 +1,425 @@
+// Package redisstore provides a restify.SharedFrontier and restify.Deduper kept in Redis, so that
+// several crawler processes can share a crawl's queue and visited set, and stop together once
+// the crawl is exhausted.
+//
+// It speaks just enough of the Redis protocol for its own needs, so it adds no dependencies.
+package redisstore
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/comnoco/restify"
+)
+
+// DefaultPrefix is the prefix of the keys used by a Store when none is configured.
+const DefaultPrefix = "restify"
+
+// DefaultDialTimeout is the time allowed to connect when none is configured.
+const DefaultDialTimeout = 5 * time.Second
+
+// DefaultTimeout is the time allowed for each command when none is configured.
+const DefaultTimeout = 5 * time.Second
+
+// DefaultPendingTimeout is the time a popped request is pending when none is configured.
+const DefaultPendingTimeout = 10 * time.Minute
+
+// requeueScript moves the pending requests, KEYS[2], whose score is at most ARGV[1] back onto the
+// frontier, KEYS[1], such as those popped by a worker that died, setting requeued to their count.
+const requeueScript = `local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1])
+for _, request in ipairs(expired) do
+	redis.call('ZREM', KEYS[2], request)
+	redis.call('RPUSH', KEYS[1], request)
+end
+local requeued = #expired
+`
+
+// popScript requeues the expired pending requests as of ARGV[1], then pops the next request of
+// the frontier, KEYS[1], and adds it to the pending requests, KEYS[2], with the score ARGV[2],
+// atomically so that no request is lost between them.
+const popScript = requeueScript + `local request = redis.call('LPOP', KEYS[1])
+if request then
+	redis.call('ZADD', KEYS[2], ARGV[2], request)
+end
+return request`
+
+// pendingScript requeues the expired pending requests as of ARGV[1], then counts the requests
+// still pending along with those requeued, which remain to be crawled.
+const pendingScript = requeueScript + `return redis.call('ZCARD', KEYS[2]) + requeued`
+
+// Options configures the connection and keys of a Store.
+type Options struct {
+	// Password authenticates the connection, if set
+	Password string
+	// DB selects the numbered Redis database, 0 by default
+	DB int
+	// Prefix namespaces the keys of the crawl, DefaultPrefix if empty. Workers sharing a crawl
+	// must use the same prefix; separate crawls should use different ones.
+	Prefix string
+	// DialTimeout limits the time to connect, DefaultDialTimeout if zero
+	DialTimeout time.Duration
+	// Timeout limits the time to send each command and read its reply, DefaultTimeout if zero
+	Timeout time.Duration
+	// PendingTimeout is how long a popped request counts as pending until it is done,
+	// DefaultPendingTimeout if zero, after which it is pushed back onto the frontier, such as
+	// when the worker crawling it has died. It should exceed the time taken to crawl a page.
+	PendingTimeout time.Duration
+}
+
+// Store is a restify.SharedFrontier and restify.Deduper kept in Redis. The frontier is a list
+// at "<prefix>:frontier", the pending requests are a sorted set at "<prefix>:pending" scored by
+// when they stop being pending, and the seen URLs are a set at "<prefix>:seen". A Store holds a
+// single connection, which is re-established if it fails, and is safe for concurrent use. The
+// methods of the restify interfaces are bound to Options.Timeout, while their Context variants
+// are also bound to a context.
+type Store struct {
+	addr    string
+	options Options
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+var (
+	_ restify.SharedFrontier = (*Store)(nil)
+	_ restify.Deduper        = (*Store)(nil)
+)
+
+// New creates a Store using the Redis server at addr, such as "localhost:6379". The connection
+// is established when the Store is first used.
+func New(addr string, options Options) *Store {
+	if options.Prefix == "" {
+		options.Prefix = DefaultPrefix
+	}
+	if options.DialTimeout == 0 {
+		options.DialTimeout = DefaultDialTimeout
+	}
+	if options.Timeout == 0 {
+		options.Timeout = DefaultTimeout
+	}
+	if options.PendingTimeout == 0 {
+		options.PendingTimeout = DefaultPendingTimeout
+	}
+	return &Store{addr: addr, options: options}
+}
+
+func (s *Store) frontierKey() string {
+	return s.options.Prefix + ":frontier"
+}
+
+func (s *Store) seenKey() string {
+	return s.options.Prefix + ":seen"
+}
+
+func (s *Store) pendingKey() string {
+	return s.options.Prefix + ":pending"
+}
+
+// Push implements restify.FrontierStore.
+func (s *Store) Push(request restify.CrawlRequest) error {
+	return s.PushContext(context.Background(), request)
+}
+
+// PushContext is like Push but stops when ctx is done.
+func (s *Store) PushContext(ctx context.Context, request restify.CrawlRequest) error {
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("Failed to encode crawl request: %w", err)
+	}
+	_, err = s.do(ctx, "RPUSH", s.frontierKey(), string(encoded))
+	return err
+}
+
+// Pop implements restify.FrontierStore. The request is pending until it is Done or its
+// PendingTimeout passes, when it is pushed back onto the frontier for another worker to crawl.
+func (s *Store) Pop() (restify.CrawlRequest, bool, error) {
+	return s.PopContext(context.Background())
+}
+
+// PopContext is like Pop but stops when ctx is done.
+func (s *Store) PopContext(ctx context.Context) (restify.CrawlRequest, bool, error) {
+	var request restify.CrawlRequest
+	now := time.Now()
+	expiry := now.Add(s.options.PendingTimeout)
+	reply, err := s.do(ctx, "EVAL", popScript, "2", s.frontierKey(), s.pendingKey(), unixMilli(now), unixMilli(expiry))
+	if err != nil || reply == nil {
+		return request, false, err
+	}
+	encoded, ok := reply.(string)
+	if !ok {
+		return request, false, fmt.Errorf("Unexpected reply to EVAL: %v", reply)
+	}
+	if err := json.Unmarshal([]byte(encoded), &request); err != nil {
+		return request, false, fmt.Errorf("Failed to decode crawl request: %w", err)
+	}
+	return request, true, nil
+}
+
+// Len implements restify.FrontierStore.
+func (s *Store) Len() (int, error) {
+	return s.LenContext(context.Background())
+}
+
+// LenContext is like Len but stops when ctx is done.
+func (s *Store) LenContext(ctx context.Context) (int, error) {
+	reply, err := s.do(ctx, "LLEN", s.frontierKey())
+	if err != nil {
+		return 0, err
+	}
+	length, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("Unexpected reply to LLEN: %v", reply)
+	}
+	return int(length), nil
+}
+
+// Done implements restify.SharedFrontier.
+func (s *Store) Done(request restify.CrawlRequest) error {
+	return s.DoneContext(context.Background(), request)
+}
+
+// DoneContext is like Done but stops when ctx is done.
+func (s *Store) DoneContext(ctx context.Context, request restify.CrawlRequest) error {
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("Failed to encode crawl request: %w", err)
+	}
+	_, err = s.do(ctx, "ZREM", s.pendingKey(), string(encoded))
+	return err
+}
+
+// Pending implements restify.SharedFrontier, counting the requests popped within their
+// PendingTimeout that are not yet done. Requests whose PendingTimeout has passed are pushed back
+// onto the frontier, by Pending as by Pop, and counted until they are popped again.
+func (s *Store) Pending() (int, error) {
+	return s.PendingContext(context.Background())
+}
+
+// PendingContext is like Pending but stops when ctx is done.
+func (s *Store) PendingContext(ctx context.Context) (int, error) {
+	reply, err := s.do(ctx, "EVAL", pendingScript, "2", s.frontierKey(), s.pendingKey(), unixMilli(time.Now()))
+	if err != nil {
+		return 0, err
+	}
+	count, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("Unexpected reply to EVAL: %v", reply)
+	}
+	return int(count), nil
+}
+
+// unixMilli formats t as milliseconds since the epoch, the scores of pending requests.
+func unixMilli(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
+
+// MarkSeen implements restify.Deduper. Redis adds to the set atomically, so exactly one worker
+// sees each key as newly added.
+func (s *Store) MarkSeen(key string) (bool, error) {
+	return s.MarkSeenContext(context.Background(), key)
+}
+
+// MarkSeenContext is like MarkSeen but stops when ctx is done.
+func (s *Store) MarkSeenContext(ctx context.Context, key string) (bool, error) {
+	reply, err := s.do(ctx, "SADD", s.seenKey(), key)
+	if err != nil {
+		return false, err
+	}
+	added, ok := reply.(int64)
+	if !ok {
+		return false, fmt.Errorf("Unexpected reply to SADD: %v", reply)
+	}
+	return added == 1, nil
+}
+
+// Reset deletes the frontier, pending, and seen keys, such as to start a crawl afresh.
+func (s *Store) Reset() error {
+	return s.ResetContext(context.Background())
+}
+
+// ResetContext is like Reset but stops when ctx is done.
+func (s *Store) ResetContext(ctx context.Context) error {
+	_, err := s.do(ctx, "DEL", s.frontierKey(), s.pendingKey(), s.seenKey())
+	return err
+}
+
+// Close closes the connection, if one is open.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// ErrorReply is an error reported by the Redis server.
+type ErrorReply string
+
+func (e ErrorReply) Error() string {
+	return "redis: " + string(e)
+}
+
+// do sends a command and reads its reply, which is a string, int64, []interface{}, or nil,
+// within the Timeout and before ctx is done. A broken connection is discarded so that the next
+// command reconnects, as is one whose command was interrupted, since its reply may still arrive.
+func (s *Store) do(ctx context.Context, args ...string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	deadline := time.Now().Add(s.options.Timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn := s.conn
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, s.abandon(fmt.Errorf("Failed to set redis deadline: %w", err))
+	}
+	finished, stopped := make(chan struct{}), make(chan struct{})
+	defer func() {
+		close(finished)
+		// the deadline must not be changed once the next command has set its own
+		<-stopped
+	}()
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			// interrupts the round trip, which then fails
+			//goland:noinspection GoUnhandledErrorResult
+			conn.SetDeadline(time.Now())
+		case <-finished:
+		}
+	}()
+
+	reply, err := s.roundTrip(args...)
+	var errorReply ErrorReply
+	if err != nil && !errors.As(err, &errorReply) {
+		//goland:noinspection GoUnhandledErrorResult
+		s.abandon(err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+	}
+	return reply, err
+}
+
+func (s *Store) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: s.options.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("Failed to connect to redis: %w", err)
+	}
+	s.conn = conn
+	s.reader = bufio.NewReader(conn)
+	if err := conn.SetDeadline(time.Now().Add(s.options.Timeout)); err != nil {
+		return s.abandon(fmt.Errorf("Failed to set redis deadline: %w", err))
+	}
+
+	if s.options.Password != "" {
+		if _, err := s.roundTrip("AUTH", s.options.Password); err != nil {
+			return s.abandon(fmt.Errorf("Failed to authenticate with redis: %w", err))
+		}
+	}
+	if s.options.DB != 0 {
+		if _, err := s.roundTrip("SELECT", strconv.Itoa(s.options.DB)); err != nil {
+			return s.abandon(fmt.Errorf("Failed to select redis database: %w", err))
+		}
+	}
+	return nil
+}
+
+// abandon closes a connection that could not be set up, returning err.
+func (s *Store) abandon(err error) error {
+	//goland:noinspection GoUnhandledErrorResult
+	s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *Store) roundTrip(args ...string) (interface{}, error) {
+	writer := bufio.NewWriter(s.conn)
+	fmt.Fprintf(writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, fmt.Errorf("Failed to send redis command: %w", err)
+	}
+	return readReply(s.reader)
+}
+
+func readReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read redis reply: %w", err)
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("Malformed redis reply: %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+
+	case '-':
+		return nil, ErrorReply(payload)
+
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+
+	case '$':
+		length, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed redis bulk length: %q", payload)
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		buffer := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, buffer); err != nil {
+			return nil, fmt.Errorf("Failed to read redis reply: %w", err)
+		}
+		return string(buffer[:length]), nil
+
+	case '*':
+		count, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed redis array length: %q", payload)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		elements := make([]interface{}, count)
+		for i := range elements {
+			if elements[i], err = readReply(reader); err != nil {
+				return nil, err
+			}
+		}
+		return elements, nil
+
+	default:
+		return nil, fmt.Errorf("Unknown redis reply type: %q", kind)
+	}
+}
@@ -0,0 +1,94 @@
+package restify
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// ErrNoRuleset is returned by RulesetRegistry.Extract when no ruleset is registered for the
+// host of a page.
+var ErrNoRuleset = errors.New("no ruleset registered for host")
+
+// RulesetRegistry routes pages to the Ruleset for their host, such as to scrape many sites
+// that each need their own rules. It is safe for concurrent use.
+type RulesetRegistry struct {
+	mu      sync.RWMutex
+	entries []registryEntry
+}
+
+type registryEntry struct {
+	pattern string
+	rules   *Ruleset
+}
+
+// NewRulesetRegistry creates an empty RulesetRegistry.
+func NewRulesetRegistry() *RulesetRegistry {
+	return &RulesetRegistry{}
+}
+
+// Register routes hosts matching pattern to rules. The pattern is a host name such as
+// "example.com", a wildcard such as "*.example.com" matching its subdomains, or "*" as the
+// fallback for every host, as with WithHostPolicy. When several patterns match a host, a host
+// name is preferred to a wildcard, the wildcard of the longest domain is preferred to others,
+// and the fallback is used last. Registering a pattern again replaces its rules.
+func (r *RulesetRegistry) Register(pattern string, rules *Ruleset) {
+	pattern = strings.ToLower(pattern)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.entries {
+		if r.entries[i].pattern == pattern {
+			r.entries[i].rules = rules
+			return
+		}
+	}
+	r.entries = append(r.entries, registryEntry{pattern, rules})
+}
+
+// Lookup returns the rules for the host of pageUrl, and whether any were registered.
+func (r *RulesetRegistry) Lookup(pageUrl *url.URL) (*Ruleset, bool) {
+	host := strings.ToLower(pageUrl.Hostname())
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var best *registryEntry
+	for i := range r.entries {
+		entry := &r.entries[i]
+		if !matchHostPattern(entry.pattern, host) {
+			continue
+		}
+		if best == nil || patternSpecificity(entry.pattern) > patternSpecificity(best.pattern) {
+			best = entry
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+	return best.rules, true
+}
+
+// Extract applies the rules for the host of pageUrl to the document at root, as with
+// Ruleset.Extract, or returns ErrNoRuleset if there are none.
+func (r *RulesetRegistry) Extract(pageUrl *url.URL, root *html.Node) ([]Record, error) {
+	rules, ok := r.Lookup(pageUrl)
+	if !ok {
+		return nil, ErrNoRuleset
+	}
+	return rules.Extract(root)
+}
+
+// patternSpecificity ranks host patterns so that more specific ones are preferred.
+func patternSpecificity(pattern string) int {
+	switch {
+	case pattern == "*":
+		return 0
+	case strings.HasPrefix(pattern, "*."):
+		return len(pattern)
+	default:
+		// host names match a single host, so are preferred to any wildcard
+		return len(pattern) + 1<<16
+	}
+}
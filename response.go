@@ -0,0 +1,283 @@
+package restify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/transform"
+)
+
+// maxHttpRedirects matches the limit of the default http.Client redirect policy.
+const maxHttpRedirects = 10
+
+// Response is the result of a Loader fetch: the parsed document along with details of how it
+// was retrieved.
+type Response struct {
+	// Root is the parsed document. XML documents are parsed with ParseXml.
+	Root *html.Node
+	// URL is the location of the document after following any redirects
+	URL *url.URL
+	// StatusCode is the HTTP status of the final response, zero for file URLs
+	StatusCode int
+	// Proto is the protocol of the final response, such as "HTTP/1.1" or "HTTP/2.0", empty
+	// for file URLs
+	Proto string
+	// Header holds the headers of the final response, empty for file URLs
+	Header http.Header
+	// Redirects lists, in order, each location that redirected on the way to URL
+	Redirects []Redirect
+	// Version identifies which version of the page was loaded, VersionCanonical unless the
+	// Loader was configured WithPreferredVersions
+	Version PageVersion
+	// Charset is the name of the character encoding the content was decoded from, as
+	// determined by DetectCharset for HTML
+	Charset string
+	// ContentType is the media type of the content, such as "text/html", without parameters.
+	// It is empty for file URLs and responses without a valid Content-Type.
+	ContentType string
+	// Kind is how the content was interpreted, based on its ContentType
+	Kind ContentKind
+	// Json is the decoded content when Kind is ContentJson, in which case Root is a synthetic
+	// document presenting the JSON text
+	Json interface{}
+	// Text is the content when Kind is ContentText, or the extracted text when Kind is
+	// ContentPdf, in which cases Root is a synthetic document presenting the text
+	Text string
+	// Language is the hreflang tag of the language variant that was loaded instead of the page
+	// requested, when the Loader was configured WithPreferredLanguages, or empty if the page
+	// itself was loaded
+	Language string
+	// Body is the content as it was received, after removing any content encoding but before
+	// decoding its charset, when the Loader was configured WithRawBody. It is empty for file URLs.
+	Body []byte
+	// Frames are the documents embedded by <iframe> elements, when the Loader was configured
+	// WithFrames(FramesDocuments)
+	Frames []*Frame
+}
+
+// RedirectKind identifies the mechanism used to redirect.
+type RedirectKind string
+
+const (
+	// RedirectHttp is a 3xx HTTP response with a Location header
+	RedirectHttp RedirectKind = "http"
+	// RedirectMetaRefresh is a <meta http-equiv="refresh"> element
+	RedirectMetaRefresh RedirectKind = "meta-refresh"
+	// RedirectJavaScript is a script assigning to or replacing the window location
+	RedirectJavaScript RedirectKind = "javascript"
+	// RedirectAlternate is a page declaring the preferred version or language variant that was
+	// loaded in its place
+	RedirectAlternate RedirectKind = "alternate"
+)
+
+// Redirect is a location that redirected elsewhere.
+type Redirect struct {
+	// URL is the location that redirected
+	URL *url.URL
+	// Kind is how the location redirected
+	Kind RedirectKind
+}
+
+type redirectsKey struct{}
+
+// recordRedirect is the http.Client redirect policy of a Loader, which tracks the redirect chain
+// in the list carried by the request context.
+func recordRedirect(request *http.Request, via []*http.Request) error {
+	if len(via) >= maxHttpRedirects {
+		return errors.New("stopped after 10 redirects")
+	}
+	if redirects, ok := request.Context().Value(redirectsKey{}).(*[]Redirect); ok {
+		*redirects = append(*redirects, Redirect{URL: via[len(via)-1].URL, Kind: RedirectHttp})
+	}
+	return nil
+}
+
+// Fetch retrieves and parses the content at the given url, reporting details of the retrieval
+// in the returned Response. The Loader's timeout applies to the whole fetch, including any
+// HTML redirects that are followed. File URLs are loaded with LoadFile. When the Loader was
+// configured WithDocumentStore, the Response may be one kept by the store.
+func (l *Loader) Fetch(ctx context.Context, url *url.URL) (*Response, error) {
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+	if l.documents != nil && url.Scheme != "file" {
+		// conditions set by LoadIfChanged are the caller's, so are not replaced by the store's
+		if _, conditional := ctx.Value(conditionalKey{}).(PageState); !conditional {
+			return l.fetchStored(ctx, url)
+		}
+	}
+	return l.fetch(ctx, url)
+}
+
+// fetch is Fetch without the Loader's timeout or DocumentStore.
+func (l *Loader) fetch(ctx context.Context, url *url.URL) (*Response, error) {
+	var redirects []Redirect
+	visited := map[string]bool{url.String(): true}
+	from := origin(ctx)
+	for hop := 0; ; hop++ {
+		resp, err := l.fetchOnce(ctx, from, url, &redirects)
+		if err != nil {
+			return nil, err
+		}
+		if l.detectChallenges {
+			if vendor, ok := DetectChallenge(resp); ok {
+				return nil, &ChallengeError{Vendor: vendor, URL: resp.URL, StatusCode: resp.StatusCode}
+			}
+		}
+		if resp.StatusCode == http.StatusNotModified {
+			resp.Redirects = redirects
+			return resp, nil
+		}
+		// conditions set by LoadIfChanged apply only to the page requested
+		ctx = context.WithValue(ctx, conditionalKey{}, PageState{})
+
+		if hop < l.maxHtmlRedirects {
+			if target, kind, ok := FindHtmlRedirect(resp.Root, resp.URL); ok && !visited[target.String()] {
+				visited[target.String()] = true
+				redirects = append(redirects, Redirect{URL: resp.URL, Kind: kind})
+				from, url = resp.URL, target
+				continue
+			}
+		}
+
+		resp.Redirects = redirects
+		resp.Version = VersionCanonical
+		if len(l.preferredLanguages) > 0 {
+			resp = l.preferLanguage(ctx, resp, l.preferredLanguages)
+		}
+		if len(l.preferredVersions) > 0 {
+			resp = l.preferVersion(ctx, resp)
+		}
+		if l.frameMode != FramesIgnore {
+			l.loadFrames(ctx, resp)
+		}
+		return resp, nil
+	}
+}
+
+// fetchOnce retrieves a single document, following only HTTP redirects, which are appended to
+// redirects. The URL is normalized by the Loader's URLNormalizer before it is requested. The
+// page that led to the URL, such as by redirecting or embedding it, is from, or nil if it was
+// requested directly. File URLs are refused when led to by other pages, unless they are file
+// URLs too.
+func (l *Loader) fetchOnce(ctx context.Context, from *url.URL, url *url.URL, redirects *[]Redirect) (*Response, error) {
+	if url.Scheme == "file" {
+		if from != nil && !followable(from, url) {
+			return nil, fmt.Errorf("Refusing to load file URL %s from %s: %w", url, from, ErrHostBlocked)
+		}
+		if l.hostFilter != nil {
+			return nil, fmt.Errorf("Refusing to load file URL %s: %w", url, ErrHostBlocked)
+		}
+		root, err := LoadFile(url, l.userAgent, l.configs...)
+		if err != nil {
+			return nil, err
+		}
+		response := &Response{Root: root, URL: url, Header: make(http.Header), Kind: ContentHtml}
+		l.transform(response)
+		return response, nil
+	}
+
+	url, err := l.normalizer().Normalize(url)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to request: %w", err)
+	}
+
+	ctx = context.WithValue(ctx, redirectsKey{}, redirects)
+	request, err := http.NewRequestWithContext(ctx, "GET", url.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to request: %w", err)
+	}
+
+	request.Header.Set("accept", "*/*")
+	l.applyProfile(request)
+	if userAgent := l.nextUserAgent(); userAgent != "" {
+		request.Header.Set("user-agent", userAgent)
+	}
+	if state, ok := ctx.Value(conditionalKey{}).(PageState); ok {
+		if state.ETag != "" {
+			request.Header.Set("if-none-match", state.ETag)
+		}
+		if state.LastModified != "" {
+			request.Header.Set("if-modified-since", state.LastModified)
+		}
+	}
+	if referer, ok := ctx.Value(refererKey{}).(string); ok {
+		// frames are requested as a browser would, which embedded widgets often check
+		request.Header.Set("referer", referer)
+	}
+	for _, config := range l.configs {
+		config(request)
+	}
+
+	resp, err := l.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve response: %w", err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+
+	decoded, releaseDecoded, err := decodeContentEncoding(resp)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseDecoded()
+
+	response := &Response{
+		URL:        resp.Request.URL,
+		StatusCode: resp.StatusCode,
+		Proto:      resp.Proto,
+		Header:     resp.Header,
+	}
+	var body io.Reader = decoded
+	capture := l.captureBody(response, decoded)
+	if capture != nil {
+		body = capture.reader
+	}
+	err = l.parseContent(response, body, resp.Header.Get("Content-Type"))
+	if capture != nil {
+		if finishErr := capture.finish(response); err == nil {
+			err = finishErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.transform(response)
+	return response, nil
+}
+
+// charsetSniffLength is the amount of content examined for a byte order mark or <meta charset>,
+// as recommended by the HTML specification.
+const charsetSniffLength = 1024
+
+// DetectCharset determines the character encoding of HTML content from the given start of the
+// content and its Content-Type header, which may be empty. It considers byte order marks, the
+// Content-Type charset parameter, <meta> charset declarations, and finally the content itself,
+// as described by https://html.spec.whatwg.org/multipage/parsing.html#determining-the-character-encoding
+func DetectCharset(content []byte, contentType string) string {
+	_, name, _ := charset.DetermineEncoding(content, contentType)
+	return name
+}
+
+// decodeCharset wraps body so that it is decoded to UTF-8, returning the name of the detected
+// charset. The returned release function must be called once the body has been read.
+func decodeCharset(body io.Reader, contentType string) (io.Reader, string, func()) {
+	buffered := getSniffReader(body)
+	release := func() { putSniffReader(buffered) }
+	// a short read just means less to sniff
+	start, _ := buffered.Peek(charsetSniffLength)
+
+	encoding, name, _ := charset.DetermineEncoding(start, contentType)
+	if name == "utf-8" {
+		return buffered, name, release
+	}
+	return transform.NewReader(buffered, encoding.NewDecoder()), name, release
+}
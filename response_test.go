@@ -0,0 +1,76 @@
+package restify
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// benchmarkPage renders a listing page of the given number of product cards.
+func benchmarkPage(cards int) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<!DOCTYPE html><html><head><meta charset="utf-8"><title>Products</title></head><body><ul class="products">`)
+	for i := 0; i < cards; i++ {
+		fmt.Fprintf(&b, `<li class="product" id="p%d"><a href="/products/%d"><img src="/images/%d.jpg" alt="Product %d"></a>`+
+			`<h2>Product %d</h2><p class="price">$%d.99</p><p class="description">A product worth buying, number %d.</p></li>`,
+			i, i, i, i, i, i, i)
+	}
+	b.WriteString(`</ul></body></html>`)
+	return b.Bytes()
+}
+
+// benchmarkServer serves page as HTML, compressed with gzip when requested.
+func benchmarkServer(page []byte) *httptest.Server {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	//goland:noinspection GoUnhandledErrorResult
+	writer.Write(page)
+	//goland:noinspection GoUnhandledErrorResult
+	writer.Close()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "text/html; charset=utf-8")
+		if r.Header.Get("accept-encoding") == "gzip, deflate" {
+			w.Header().Set("content-encoding", "gzip")
+			//goland:noinspection GoUnhandledErrorResult
+			w.Write(compressed.Bytes())
+			return
+		}
+		//goland:noinspection GoUnhandledErrorResult
+		w.Write(page)
+	}))
+}
+
+func benchmarkFetch(b *testing.B, options ...LoaderOption) {
+	server := benchmarkServer(benchmarkPage(300))
+	defer server.Close()
+	pageUrl, err := url.Parse(server.URL)
+	if err != nil {
+		b.Fatal(err)
+	}
+	loader := NewLoader(options...)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := loader.Fetch(context.Background(), pageUrl); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkFetch measures the load and parse path, including the pooled charset sniffing reader.
+func BenchmarkFetch(b *testing.B) {
+	benchmarkFetch(b)
+}
+
+// BenchmarkFetchGzip measures the load and parse path of responses decompressed by the Loader,
+// which reuse pooled gzip readers.
+func BenchmarkFetchGzip(b *testing.B) {
+	benchmarkFetch(b, WithBrowserProfile(ProfileChrome))
+}
@@ -0,0 +1,134 @@
+// Package restifytest provides helpers for testing code built on restify without depending on
+// the network.
+package restifytest
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/comnoco/restify"
+)
+
+// RecordEnv is the environment variable that, when set to a non-empty value, forces cassettes
+// to be re-recorded from the network even when their file already exists.
+const RecordEnv = "RESTIFYTEST_RECORD"
+
+// Redacted replaces the values of redacted headers in saved cassettes.
+const Redacted = "REDACTED"
+
+// DefaultRedactedHeaders are the headers whose values are never written to a cassette.
+var DefaultRedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+// Cassette records the HTTP exchanges of a test into an HTTP Archive file the first time the
+// test runs and replays them on subsequent runs, VCR style. Exchanges are stored in the same
+// format read by restify.LoadHar, so cassettes can also be inspected with any HAR viewer.
+type Cassette struct {
+	path      string
+	recording bool
+	recorder  *restify.HarRecorder
+	transport http.RoundTripper
+	redacted  []string
+}
+
+// NewCassette creates a Cassette backed by the file at path. If the file exists, and the
+// RecordEnv environment variable is not set, its responses are replayed and requests that were
+// not recorded fail. Otherwise requests are sent through http.DefaultTransport and the
+// exchanges are saved to path when the test completes successfully.
+func NewCassette(t testing.TB, path string) *Cassette {
+	t.Helper()
+
+	c := &Cassette{
+		path:     path,
+		redacted: DefaultRedactedHeaders,
+	}
+
+	_, err := os.Stat(path)
+	switch {
+	case err == nil && os.Getenv(RecordEnv) == "":
+		har, err := restify.LoadHar(path)
+		if err != nil {
+			t.Fatalf("Failed to load cassette %s: %v", path, err)
+		}
+		c.transport = restify.NewHarTransport(har)
+
+	case err == nil || os.IsNotExist(err):
+		c.recording = true
+		c.recorder = restify.NewHarRecorder()
+		c.transport = c.recorder.Wrap(http.DefaultTransport)
+		t.Cleanup(func() {
+			if t.Failed() {
+				t.Logf("Not saving cassette %s since the test failed", path)
+				return
+			}
+			if err := c.save(); err != nil {
+				t.Errorf("Failed to save cassette %s: %v", path, err)
+			}
+		})
+
+	default:
+		t.Fatalf("Failed to access cassette %s: %v", path, err)
+	}
+
+	return c
+}
+
+// RedactHeaders adds to the headers whose values are replaced with Redacted when the cassette
+// is saved. Matching is case-insensitive.
+func (c *Cassette) RedactHeaders(names ...string) {
+	c.redacted = append(append([]string(nil), c.redacted...), names...)
+}
+
+// Recording reports whether the cassette is recording from the network rather than replaying.
+func (c *Cassette) Recording() bool {
+	return c.recording
+}
+
+// Transport returns the http.RoundTripper that records or replays exchanges.
+func (c *Cassette) Transport() http.RoundTripper {
+	return c.transport
+}
+
+// Loader creates a restify.Loader that uses the cassette's transport. The given options are
+// applied first, so any transport they configure is superseded by the cassette.
+func (c *Cassette) Loader(options ...restify.LoaderOption) *restify.Loader {
+	options = append(options, restify.WithTransport(c.transport))
+	return restify.NewLoader(options...)
+}
+
+func (c *Cassette) save() error {
+	har := c.recorder.Har()
+	for i, entry := range har.Log.Entries {
+		redactedEntry := *entry
+		redactedEntry.Request.Headers = c.redact(entry.Request.Headers)
+		redactedEntry.Response.Headers = c.redact(entry.Response.Headers)
+		har.Log.Entries[i] = &redactedEntry
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return har.Save(c.path)
+}
+
+func (c *Cassette) redact(headers []restify.HarHeader) []restify.HarHeader {
+	result := make([]restify.HarHeader, len(headers))
+	for i, header := range headers {
+		result[i] = header
+		for _, name := range c.redacted {
+			if strings.EqualFold(header.Name, name) {
+				result[i].Value = Redacted
+				break
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,119 @@
+package restifytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/comnoco/restify"
+)
+
+// UpdateEnv is the environment variable that, when set to a non-empty value, rewrites golden
+// files with the current output rather than comparing against them.
+const UpdateEnv = "RESTIFYTEST_UPDATE"
+
+// updating reports whether golden files are to be rewritten, either by UpdateEnv or by an
+// -update flag that the test package declares itself. The flag is not declared here, since
+// doing so would clash with packages that declare their own.
+func updating() bool {
+	if os.Getenv(UpdateEnv) != "" {
+		return true
+	}
+	f := flag.Lookup("update")
+	if f == nil {
+		return false
+	}
+	update, err := strconv.ParseBool(f.Value.String())
+	return err == nil && update
+}
+
+// Golden applies rules to the HTML file at fixtureHTML and compares the extracted records,
+// encoded as indented JSON, with the contents of the file at goldenJSON. When they differ the
+// test fails with a line diff, and when the records fail the validation declared by rules each
+// failure is reported. Setting UpdateEnv, as in "RESTIFYTEST_UPDATE=1 go test ./...", writes
+// the golden file from the current output instead, creating it if necessary, so the change can
+// be reviewed and checked in along with the rules. So does -update, for test packages that
+// declare an -update flag of their own.
+func Golden(t testing.TB, rules *restify.Ruleset, fixtureHTML string, goldenJSON string) {
+	t.Helper()
+
+	root, err := restify.LoadPath(fixtureHTML)
+	if err != nil {
+		t.Fatalf("Failed to load fixture %s: %v", fixtureHTML, err)
+	}
+	records, err := rules.Extract(root)
+	var failures restify.ValidationErrors
+	switch {
+	case errors.As(err, &failures):
+		for _, failure := range failures {
+			t.Errorf("%s: %v", fixtureHTML, failure)
+		}
+	case err != nil:
+		t.Fatalf("Failed to extract from fixture %s: %v", fixtureHTML, err)
+	}
+
+	actual, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to encode records: %v", err)
+	}
+	actual = append(actual, '\n')
+
+	if updating() {
+		if err := os.MkdirAll(filepath.Dir(goldenJSON), 0755); err != nil {
+			t.Fatalf("Failed to create directory of golden file %s: %v", goldenJSON, err)
+		}
+		if err := ioutil.WriteFile(goldenJSON, actual, 0644); err != nil {
+			t.Fatalf("Failed to write golden file %s: %v", goldenJSON, err)
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(goldenJSON)
+	if err != nil {
+		t.Fatalf("Failed to read golden file %s, set %s to create it: %v", goldenJSON, UpdateEnv, err)
+	}
+	if bytes.Equal(bytes.TrimSpace(expected), bytes.TrimSpace(actual)) {
+		return
+	}
+	t.Errorf("Records extracted from %s differ from %s, set %s to accept them:\n%s",
+		fixtureHTML, goldenJSON, UpdateEnv, lineDiff(string(expected), string(actual)))
+}
+
+// lineDiff describes how the lines of actual differ from those of expected, as the differing
+// lines between their common beginning and end, prefixed with - when expected and + when actual.
+func lineDiff(expected string, actual string) string {
+	before := strings.Split(strings.TrimSpace(expected), "\n")
+	after := strings.Split(strings.TrimSpace(actual), "\n")
+
+	start := 0
+	for start < len(before) && start < len(after) && before[start] == after[start] {
+		start++
+	}
+	end := 0
+	for end < len(before)-start && end < len(after)-start &&
+		before[len(before)-1-end] == after[len(after)-1-end] {
+		end++
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("  " + before[start-1] + "\n")
+	}
+	for _, line := range before[start : len(before)-end] {
+		b.WriteString("- " + line + "\n")
+	}
+	for _, line := range after[start : len(after)-end] {
+		b.WriteString("+ " + line + "\n")
+	}
+	if end > 0 {
+		b.WriteString("  " + before[len(before)-end] + "\n")
+	}
+	return b.String()
+}
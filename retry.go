@@ -0,0 +1,158 @@
+package restify
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// DefaultRetryBackoff is the delay before the first retry when a RetryPolicy does not set one.
+const DefaultRetryBackoff = time.Second
+
+// DefaultMaxRetryBackoff is the longest delay between retries when a RetryPolicy does not set
+// one, including delays requested by Retry-After headers.
+const DefaultMaxRetryBackoff = 30 * time.Second
+
+// DefaultRetryStatusCodes are the statuses retried when a RetryPolicy does not list any, which
+// are those of servers that are overloaded or briefly unavailable.
+var DefaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy configures how a Loader retries requests that fail, given WithRetries.
+type RetryPolicy struct {
+	// MaxRetries is the number of times a request is retried after its first attempt
+	MaxRetries int
+	// Backoff is the delay before the first retry, doubling for each that follows,
+	// DefaultRetryBackoff if zero
+	Backoff time.Duration
+	// MaxBackoff is the longest delay between retries, DefaultMaxRetryBackoff if zero
+	MaxBackoff time.Duration
+	// StatusCodes are the response statuses that are retried, DefaultRetryStatusCodes if empty
+	StatusCodes []int
+}
+
+// WithRetries retries requests that time out, fail temporarily, such as when a connection is
+// reset, or respond with one of the statuses of policy, waiting longer between each attempt, or
+// as long as the server asks by its Retry-After header. Only requests without side effects,
+// those with the GET, HEAD, or OPTIONS methods, are retried, and failures that would fail again,
+// such as refused hosts, failed TLS handshakes, and hosts that do not exist, are not. The
+// Loader's timeout covers every attempt.
+func WithRetries(policy RetryPolicy) LoaderOption {
+	return func(l *Loader) {
+		l.retryPolicy = &policy
+	}
+}
+
+// wrap retries requests sent through next according to the policy.
+func (p *RetryPolicy) wrap(next http.RoundTripper) http.RoundTripper {
+	backoff := p.Backoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxRetryBackoff
+	}
+	statusCodes := p.StatusCodes
+	if len(statusCodes) == 0 {
+		statusCodes = DefaultRetryStatusCodes
+	}
+	retried := make(map[int]bool)
+	for _, code := range statusCodes {
+		retried[code] = true
+	}
+
+	return roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		idempotent := request.Method == "" || request.Method == http.MethodGet ||
+			request.Method == http.MethodHead || request.Method == http.MethodOptions
+		if !idempotent || p.MaxRetries <= 0 || (request.Body != nil && request.GetBody == nil) {
+			return next.RoundTrip(request)
+		}
+
+		delay := backoff
+		current := request
+		for attempt := 0; ; attempt++ {
+			resp, err := next.RoundTrip(current)
+			if attempt >= p.MaxRetries || request.Context().Err() != nil ||
+				(err == nil && !retried[resp.StatusCode]) || (err != nil && !retryableError(err)) {
+				return resp, err
+			}
+
+			wait := delay
+			if err == nil {
+				if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+					wait = after
+				}
+				//goland:noinspection GoUnhandledErrorResult
+				io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+				//goland:noinspection GoUnhandledErrorResult
+				resp.Body.Close()
+			}
+			if wait > maxBackoff {
+				wait = maxBackoff
+			}
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-request.Context().Done():
+				timer.Stop()
+				return nil, request.Context().Err()
+			}
+			// requests must not be modified by a RoundTripper, so retries send a copy
+			current = request.Clone(request.Context())
+			if request.GetBody != nil {
+				if current.Body, err = request.GetBody(); err != nil {
+					return nil, err
+				}
+			}
+			if delay *= 2; delay > maxBackoff {
+				delay = maxBackoff
+			}
+		}
+	})
+}
+
+// retryableError reports whether a request failing with err may succeed if retried, as when it
+// timed out, failed temporarily, or its connection was reset or closed.
+func retryableError(err error) bool {
+	if errors.Is(err, ErrHostBlocked) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var temporary interface{ Temporary() bool }
+	return errors.As(err, &temporary) && temporary.Temporary()
+}
+
+// retryAfter interprets a Retry-After header, which is either a number of seconds or a date.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
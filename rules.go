@@ -0,0 +1,242 @@
+package restify
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+)
+
+// Rule extracts a single named field from an item of a page.
+type Rule struct {
+	// Name is the key of the field within each Record
+	Name string `json:"name"`
+	// Selector is a CSS selector, as accepted by ParseSelector, locating the field within the
+	// item. When empty, the item itself is used.
+	Selector string `json:"selector,omitempty"`
+	// Attr, when set, extracts the value of the named attribute rather than the text
+	Attr string `json:"attr,omitempty"`
+	// Multiple collects every match into a list, rather than only the first
+	Multiple bool `json:"multiple,omitempty"`
+
+	// Type, when set, is the kind of value expected, one of the RuleType constants. Values are
+	// validated against it but remain strings.
+	Type RuleType `json:"type,omitempty"`
+	// Required fields must match within every item
+	Required bool `json:"required,omitempty"`
+	// MinCount is the fewest values expected of a Multiple rule within each item
+	MinCount int `json:"minCount,omitempty"`
+	// MaxCount, when positive, is the most values expected of a Multiple rule within each item
+	MaxCount int `json:"maxCount,omitempty"`
+	// Pattern, when set, is a regular expression that every value must match
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// Ruleset describes how to extract structured records from a page.
+type Ruleset struct {
+	// Item is a CSS selector locating each item to extract a Record from. When empty, the whole
+	// document is a single item.
+	Item string `json:"item,omitempty"`
+	// Rules extract the fields of each item
+	Rules []Rule `json:"rules"`
+	// MinItems is the fewest items expected within a page
+	MinItems int `json:"minItems,omitempty"`
+}
+
+// ReadRuleset reads a Ruleset from the JSON or YAML file at path, in the formats read by
+// ReadLoaderConfig and with the JSON names of its fields, such as:
+//
+//	item: li.product
+//	rules:
+//	  - name: title
+//	    selector: h2
+//	    required: true
+//	  - name: price
+//	    selector: .price
+//	    type: price
+//
+// The ruleset is checked to be valid, as Extract would.
+func ReadRuleset(path string) (*Ruleset, error) {
+	var rules Ruleset
+	if err := readConfigFile(path, &rules); err != nil {
+		return nil, err
+	}
+	if _, err := rules.compile(); err != nil {
+		return nil, fmt.Errorf("Invalid ruleset %s: %w", path, err)
+	}
+	return &rules, nil
+}
+
+// Record holds the fields extracted from an item, keyed by rule name. Each value is a string,
+// or a []string for rules extracting Multiple values. Fields that matched nothing are omitted,
+// unless Multiple, in which case they are an empty list.
+type Record map[string]interface{}
+
+// Fields returns the names of the fields of the records extracted by the ruleset, in the order of
+// its rules, such as to give the columns of a CsvSink or SqlSink.
+func (rs *Ruleset) Fields() []string {
+	fields := make([]string, len(rs.Rules))
+	for i, rule := range rs.Rules {
+		fields[i] = rule.Name
+	}
+	return fields
+}
+
+// Extract applies the ruleset to the document at root, returning a Record for each item found.
+// When the records fail the expectations declared by the ruleset, such as after a site is
+// redesigned, they are returned along with ValidationErrors describing each failure.
+func (rs *Ruleset) Extract(root *html.Node) ([]Record, error) {
+	compiled, err := rs.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*html.Node{root}
+	if compiled.item != nil {
+		items = scrape.FindAll(root, compiled.item.Matcher())
+	}
+	records := make([]Record, 0, len(items))
+	var failures ValidationErrors
+	for i, n := range items {
+		record := rs.extractItem(n, compiled.fields)
+		failures = append(failures, rs.validate(i, record, compiled.patterns)...)
+		records = append(records, record)
+	}
+	failures = append(failures, rs.validateCount(len(items))...)
+
+	if len(failures) > 0 {
+		return records, failures
+	}
+	return records, nil
+}
+
+// ExtractStream applies rules to the HTML content of reader as it is read, calling emit with the
+// Record of each item as soon as the item's element has been parsed, as with StreamMatches.
+// This bounds memory use and latency by the size of an item, rather than of the document,
+// for arbitrarily long content. Since each item is detached from the document, the selectors
+// of the rules can only refer to elements within it. When rules has no Item, the whole
+// document is a single item, which is emitted once the content has been read. Records failing
+// the expectations of rules are emitted regardless, and ValidationErrors describing the
+// failures are returned once the content has been read.
+func ExtractStream(reader io.Reader, rules *Ruleset, emit func(record Record)) error {
+	compiled, err := rules.compile()
+	if err != nil {
+		return err
+	}
+
+	var failures ValidationErrors
+	items := 0
+	extract := func(n *html.Node) {
+		record := rules.extractItem(n, compiled.fields)
+		failures = append(failures, rules.validate(items, record, compiled.patterns)...)
+		items++
+		emit(record)
+	}
+
+	if compiled.item == nil {
+		root, err := LoadReader(reader)
+		if err != nil {
+			return err
+		}
+		extract(root)
+	} else {
+		err = StreamMatches(reader, compiled.item.Matcher(), func(n *html.Node) bool {
+			extract(n)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	failures = append(failures, rules.validateCount(items)...)
+	if len(failures) > 0 {
+		return failures
+	}
+	return nil
+}
+
+// compiledRuleset holds the parsed selectors and patterns of a Ruleset.
+type compiledRuleset struct {
+	// item is the item selector, nil if there is none
+	item *Selector
+	// fields are the selectors of each rule, nil for rules without one
+	fields []*Selector
+	// patterns are the patterns of each rule, nil for rules without one
+	patterns []*regexp.Regexp
+}
+
+// compile parses the selectors and patterns of the ruleset, checking that its rules are valid.
+func (rs *Ruleset) compile() (*compiledRuleset, error) {
+	compiled := &compiledRuleset{
+		fields:   make([]*Selector, len(rs.Rules)),
+		patterns: make([]*regexp.Regexp, len(rs.Rules)),
+	}
+	if rs.Item != "" {
+		var err error
+		if compiled.item, err = ParseSelector(rs.Item); err != nil {
+			return nil, fmt.Errorf("Failed to parse item selector: %w", err)
+		}
+	}
+
+	for i, rule := range rs.Rules {
+		if !rule.Type.valid() {
+			return nil, fmt.Errorf("Unknown type %q of rule %q", rule.Type, rule.Name)
+		}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse pattern of rule %q: %w", rule.Name, err)
+			}
+			compiled.patterns[i] = pattern
+		}
+		if rule.Selector == "" {
+			continue
+		}
+		selector, err := ParseSelector(rule.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse selector of rule %q: %w", rule.Name, err)
+		}
+		compiled.fields[i] = selector
+	}
+	return compiled, nil
+}
+
+// extractItem applies the rules, whose compiled selectors are given by fields, to a single item.
+func (rs *Ruleset) extractItem(item *html.Node, fields []*Selector) Record {
+	record := make(Record)
+	for i, rule := range rs.Rules {
+		matches := Select(item)
+		if fields[i] != nil {
+			matches = matches.Find(fields[i].Matcher())
+		}
+
+		values := make([]string, 0, matches.Len())
+		for _, match := range matches.Nodes {
+			values = append(values, rule.value(match))
+			if !rule.Multiple {
+				break
+			}
+		}
+
+		switch {
+		case rule.Multiple:
+			record[rule.Name] = values
+		case len(values) > 0:
+			record[rule.Name] = values[0]
+		}
+	}
+	return record
+}
+
+// value extracts the rule's value from a matched node.
+func (r *Rule) value(node *html.Node) string {
+	if r.Attr != "" {
+		value, _ := attrValue(node, r.Attr)
+		return strings.TrimSpace(value)
+	}
+	return scrape.Text(node)
+}
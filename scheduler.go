@@ -0,0 +1,341 @@
+package restify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrJobRunning is returned by Scheduler.RunNow when the job is already running.
+var ErrJobRunning = errors.New("job is already running")
+
+// ErrNoJob is returned by Scheduler.RunNow when no job has the name given.
+var ErrNoJob = errors.New("no such job")
+
+// ScrapeJob loads a page on a schedule and writes the records a Ruleset extracts from it to a
+// Sink.
+type ScrapeJob struct {
+	// Name identifies the job within its Scheduler
+	Name string
+	// URL is the page loaded
+	URL *url.URL
+	// Ruleset extracts the records from the page
+	Ruleset *Ruleset
+	// Sink stores the records
+	Sink Sink
+	// Schedule is when the job runs, as a cron expression accepted by ParseSchedule
+	Schedule string
+	// Jitter delays each run by a random time up to this long, so that jobs scheduled at the
+	// same time do not all load their pages at once
+	Jitter time.Duration
+	// Timeout limits the time each run may take, unlimited if zero
+	Timeout time.Duration
+}
+
+// JobStatus describes a ScrapeJob and the outcome of its last run.
+type JobStatus struct {
+	// Name identifies the job
+	Name string `json:"name"`
+	// Schedule is the cron expression of the job
+	Schedule string `json:"schedule"`
+	// Running is set while the job runs
+	Running bool `json:"running"`
+	// NextRun is when the job next runs, including its jitter, or zero if the Scheduler is not
+	// running or the schedule never matches again
+	NextRun time.Time `json:"nextRun,omitempty"`
+	// LastStart is when the last run started
+	LastStart time.Time `json:"lastStart,omitempty"`
+	// LastEnd is when the last run finished
+	LastEnd time.Time `json:"lastEnd,omitempty"`
+	// LastRecords is the number of records the last run wrote to the job's Sink
+	LastRecords int `json:"lastRecords"`
+	// LastErr is why the last run failed, nil if it succeeded
+	LastErr error `json:"-"`
+	// LastError is the message of LastErr, for encoding
+	LastError string `json:"lastError,omitempty"`
+	// Runs is the number of runs started
+	Runs int `json:"runs"`
+	// Failures is the number of runs that failed
+	Failures int `json:"failures"`
+	// Skipped is the number of runs skipped since the previous run had not finished
+	Skipped int `json:"skipped"`
+}
+
+// Scheduler runs ScrapeJobs on their schedules. A job is never run again while it is still
+// running, and runs that come due meanwhile are skipped. Jobs can be added and removed while the
+// Scheduler runs. It is safe for concurrent use.
+type Scheduler struct {
+	// Loader loads the pages of the jobs, a default Loader if nil
+	Loader *Loader
+	// Location is the time zone that schedules are interpreted in, the local time zone if nil
+	Location *time.Location
+	// OnRun, when set, is called with the status of each job after it runs
+	OnRun func(status JobStatus)
+	// OnError, when set, is called when a job fails
+	OnError func(job string, err error)
+
+	mu   sync.Mutex
+	jobs map[string]*scheduledJob
+	// running is the context of Run, or nil when jobs are not to be started
+	running context.Context
+	// stopping is set while Run waits for runs in progress to finish
+	stopping bool
+	wg       sync.WaitGroup
+}
+
+// scheduledJob is a job added to a Scheduler.
+type scheduledJob struct {
+	job      ScrapeJob
+	schedule *Schedule
+	// status is guarded by the Scheduler's mu
+	status JobStatus
+	// stop, when set, ends the loop scheduling the job
+	stop context.CancelFunc
+}
+
+// Add adds a job to the scheduler, which starts scheduling it immediately if it is running.
+func (s *Scheduler) Add(job ScrapeJob) error {
+	if job.Name == "" {
+		return errors.New("Unable to schedule a job without a name")
+	}
+	if job.URL == nil || job.Ruleset == nil || job.Sink == nil {
+		return fmt.Errorf("Unable to schedule job %s without a URL, Ruleset, and Sink", job.Name)
+	}
+	schedule, err := ParseSchedule(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("Failed to schedule job %s: %w", job.Name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.jobs == nil {
+		s.jobs = make(map[string]*scheduledJob)
+	}
+	if _, exists := s.jobs[job.Name]; exists {
+		return fmt.Errorf("Unable to schedule job %s: a job with that name already exists", job.Name)
+	}
+	scheduled := &scheduledJob{
+		job:      job,
+		schedule: schedule,
+		status:   JobStatus{Name: job.Name, Schedule: job.Schedule},
+	}
+	s.jobs[job.Name] = scheduled
+	if s.running != nil {
+		s.start(scheduled)
+	}
+	return nil
+}
+
+// Remove removes the named job from the scheduler, returning false if there is no such job. A
+// run of the job in progress is not interrupted.
+func (s *Scheduler) Remove(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scheduled, ok := s.jobs[name]
+	if !ok {
+		return false
+	}
+	if scheduled.stop != nil {
+		scheduled.stop()
+	}
+	delete(s.jobs, name)
+	return true
+}
+
+// Run runs the jobs on their schedules until ctx is done, then waits for runs in progress to
+// finish, which are given the same context, and returns the context's error.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running != nil || s.stopping {
+		s.mu.Unlock()
+		return errors.New("Unable to run a scheduler that is already running")
+	}
+	s.running = ctx
+	for _, scheduled := range s.jobs {
+		s.start(scheduled)
+	}
+	s.mu.Unlock()
+
+	<-ctx.Done()
+	// jobs added from now on are not started, so that none join the runs being waited for
+	s.mu.Lock()
+	s.running = nil
+	s.stopping = true
+	s.mu.Unlock()
+	s.wg.Wait()
+
+	s.mu.Lock()
+	s.stopping = false
+	for _, scheduled := range s.jobs {
+		scheduled.stop = nil
+		scheduled.status.NextRun = time.Time{}
+	}
+	s.mu.Unlock()
+	return ctx.Err()
+}
+
+// RunNow runs the named job immediately, regardless of its schedule, returning once it has
+// finished. It returns ErrJobRunning if the job is already running.
+func (s *Scheduler) RunNow(ctx context.Context, name string) error {
+	s.mu.Lock()
+	scheduled, ok := s.jobs[name]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("Unable to run job %s: %w", name, ErrNoJob)
+	}
+	if scheduled.status.Running {
+		s.mu.Unlock()
+		return fmt.Errorf("Unable to run job %s: %w", name, ErrJobRunning)
+	}
+	s.begin(scheduled)
+	s.mu.Unlock()
+
+	return s.execute(ctx, scheduled)
+}
+
+// Status returns the status of every job, ordered by name.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, scheduled := range s.jobs {
+		statuses = append(statuses, scheduled.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// StatusOf returns the status of the named job. If there is no such job, then ok will be false.
+func (s *Scheduler) StatusOf(name string) (status JobStatus, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scheduled, ok := s.jobs[name]
+	if !ok {
+		return JobStatus{}, false
+	}
+	return scheduled.status, true
+}
+
+// start begins the loop scheduling a job while the scheduler runs. s.mu must be held.
+func (s *Scheduler) start(scheduled *scheduledJob) {
+	running := s.running
+	ctx, stop := context.WithCancel(running)
+	scheduled.stop = stop
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.loop(ctx, running, scheduled)
+	}()
+}
+
+// loop waits for each time the job comes due and runs it, unless it is still running, until ctx
+// is done. The runs are given the context of the scheduler, so that removing the job does not
+// interrupt them.
+func (s *Scheduler) loop(ctx context.Context, running context.Context, scheduled *scheduledJob) {
+	location := s.Location
+	if location == nil {
+		location = time.Local
+	}
+	for {
+		next := scheduled.schedule.Next(time.Now().In(location))
+		if next.IsZero() {
+			return
+		}
+		if scheduled.job.Jitter > 0 {
+			next = next.Add(time.Duration(rand.Int63n(int64(scheduled.job.Jitter))))
+		}
+		s.mu.Lock()
+		scheduled.status.NextRun = next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		s.mu.Lock()
+		if scheduled.status.Running {
+			scheduled.status.Skipped++
+			s.mu.Unlock()
+			continue
+		}
+		s.begin(scheduled)
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			//goland:noinspection GoUnhandledErrorResult
+			s.execute(running, scheduled)
+		}()
+	}
+}
+
+// begin marks a job as running. s.mu must be held.
+func (s *Scheduler) begin(scheduled *scheduledJob) {
+	scheduled.status.Running = true
+	scheduled.status.Runs++
+	scheduled.status.LastStart = time.Now()
+	if s.Loader == nil {
+		s.Loader = NewLoader()
+	}
+}
+
+// execute runs a job that has been marked as running, recording its outcome.
+func (s *Scheduler) execute(ctx context.Context, scheduled *scheduledJob) error {
+	job := scheduled.job
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+
+	records := 0
+	err := func() error {
+		root, err := s.Loader.LoadContext(ctx, job.URL)
+		if err != nil {
+			return err
+		}
+		return job.Ruleset.ExtractTo(ctx, root, SinkFunc(func(ctx context.Context, record Record) error {
+			if err := job.Sink.Write(ctx, record); err != nil {
+				return err
+			}
+			records++
+			return nil
+		}))
+	}()
+
+	s.mu.Lock()
+	scheduled.status.Running = false
+	scheduled.status.LastEnd = time.Now()
+	scheduled.status.LastRecords = records
+	scheduled.status.LastErr = err
+	scheduled.status.LastError = ""
+	if err != nil {
+		scheduled.status.Failures++
+		scheduled.status.LastError = err.Error()
+	}
+	status := scheduled.status
+	s.mu.Unlock()
+
+	if err != nil && s.OnError != nil {
+		s.OnError(job.Name, err)
+	}
+	if s.OnRun != nil {
+		s.OnRun(status)
+	}
+	if err != nil {
+		return fmt.Errorf("Job %s failed: %w", job.Name, err)
+	}
+	return nil
+}
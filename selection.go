@@ -0,0 +1,149 @@
+package restify
+
+import (
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+)
+
+// Selection is an ordered set of HTML nodes with chainable operations for extraction, such as
+//
+//	prices := restify.Select(root).
+//		Find(restify.MustParseSelector(".product").Matcher()).
+//		Find(scrape.ByClass("price")).
+//		Map(func(i int, n *html.Node) string { return scrape.Text(n) })
+//
+// Operations never modify the receiver; they return a new Selection.
+type Selection struct {
+	// Nodes are the selected nodes in document order
+	Nodes []*html.Node
+}
+
+// Select creates a Selection of the given nodes, typically the root returned by a loader.
+func Select(nodes ...*html.Node) Selection {
+	return Selection{Nodes: nodes}
+}
+
+// Len is the number of nodes in the selection.
+func (s Selection) Len() int {
+	return len(s.Nodes)
+}
+
+// Find selects the descendants of each node in the selection that satisfy matcher. As with the
+// FindSubset functions, the descendants of a matched node are not themselves considered.
+func (s Selection) Find(matcher scrape.Matcher) Selection {
+	var found []*html.Node
+	seen := make(map[*html.Node]bool)
+	for _, n := range s.Nodes {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			for _, match := range scrape.FindAll(c, matcher) {
+				if !seen[match] {
+					seen[match] = true
+					found = append(found, match)
+				}
+			}
+		}
+	}
+	return Selection{Nodes: found}
+}
+
+// FindWith selects the nodes found by find within each node of the selection, such as with
+//
+//	buttons := restify.Select(cards...).FindWith(func(card *html.Node) []*html.Node {
+//		return restify.FindByText(card, "Add to cart", restify.TextMatchOptions{})
+//	})
+//
+// so that any of the Find functions, such as FindByText, FindComments, or FindSubsetByTagName,
+// can search within several nodes. Each node is given to find as its root, so it may be found
+// itself, as the Find functions would. Nodes found more than once are selected once.
+func (s Selection) FindWith(find func(root *html.Node) []*html.Node) Selection {
+	var found []*html.Node
+	seen := make(map[*html.Node]bool)
+	for _, n := range s.Nodes {
+		for _, match := range find(n) {
+			if !seen[match] {
+				seen[match] = true
+				found = append(found, match)
+			}
+		}
+	}
+	return Selection{Nodes: found}
+}
+
+// Filter selects the nodes of the selection that satisfy matcher.
+func (s Selection) Filter(matcher scrape.Matcher) Selection {
+	var filtered []*html.Node
+	for _, n := range s.Nodes {
+		if matcher(n) {
+			filtered = append(filtered, n)
+		}
+	}
+	return Selection{Nodes: filtered}
+}
+
+// First selects the first node of the selection, if any.
+func (s Selection) First() Selection {
+	return s.Eq(0)
+}
+
+// Last selects the last node of the selection, if any.
+func (s Selection) Last() Selection {
+	return s.Eq(-1)
+}
+
+// Eq selects the node at index i of the selection. A negative index counts back from the end.
+// The result is empty if the index is out of range.
+func (s Selection) Eq(i int) Selection {
+	if i < 0 {
+		i += len(s.Nodes)
+	}
+	if i < 0 || i >= len(s.Nodes) {
+		return Selection{}
+	}
+	return Selection{Nodes: s.Nodes[i : i+1]}
+}
+
+// Text retrieves the combined text of the selected nodes, with whitespace trimmed and each
+// piece of text separated by a single space.
+func (s Selection) Text() string {
+	texts := make([]string, 0, len(s.Nodes))
+	for _, n := range s.Nodes {
+		if text := scrape.Text(n); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, " ")
+}
+
+// Attr retrieves the value of the named attribute of the first selected node. If the selection
+// is empty or the node lacks the attribute, then ok will be false.
+func (s Selection) Attr(name string) (value string, ok bool) {
+	if len(s.Nodes) == 0 {
+		return "", false
+	}
+	return attrValue(s.Nodes[0], name)
+}
+
+// Each calls f with the index and node of each selected node, returning the selection for
+// further chaining.
+func (s Selection) Each(f func(i int, n *html.Node)) Selection {
+	for i, n := range s.Nodes {
+		f(i, n)
+	}
+	return s
+}
+
+// Map calls f with the index and node of each selected node and collects the results.
+func (s Selection) Map(f func(i int, n *html.Node) string) []string {
+	results := make([]string, len(s.Nodes))
+	for i, n := range s.Nodes {
+		results[i] = f(i, n)
+	}
+	return results
+}
+
+// Json converts the selected nodes into JSON content as with ConvertHtmlToJson.
+func (s Selection) Json() ([]byte, error) {
+	return ConvertHtmlToJson(s.Nodes)
+}
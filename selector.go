@@ -0,0 +1,389 @@
+package restify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+)
+
+// Selector is a parsed CSS selector. The supported subset is type selectors (div and *), #id,
+// .class, attribute selectors ([attr] and [attr=value], as well as the ^=, $=, *=, ~=, and |=
+// operators described by AttributeOperator), the descendant and child (>) combinators, and
+// comma separated groups.
+type Selector struct {
+	text   string
+	groups []complexSelector
+}
+
+// complexSelector is a chain of compound selectors joined by combinators, where
+// combinators[i] relates steps[i-1] to steps[i]. combinators[0] is unused.
+type complexSelector struct {
+	steps       []compoundSelector
+	combinators []byte
+}
+
+// compoundSelector is a sequence of simple selectors that all apply to a single element.
+type compoundSelector struct {
+	simples []simpleSelector
+}
+
+type simpleKind int
+
+const (
+	simpleTag simpleKind = iota
+	simpleId
+	simpleClass
+	simpleAttr
+)
+
+// simpleSelector is a single condition on an element.
+type simpleSelector struct {
+	kind  simpleKind
+	name  string
+	value string
+	// op is the attribute operator, empty if only presence is required
+	op AttributeOperator
+}
+
+// ParseSelector parses the given CSS selector.
+func ParseSelector(selector string) (*Selector, error) {
+	p := &selectorParser{input: selector}
+	groups, err := p.parseGroups()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse selector %q: %w", selector, err)
+	}
+	return &Selector{text: selector, groups: groups}, nil
+}
+
+// MustParseSelector is like ParseSelector but panics if the selector is invalid.
+func MustParseSelector(selector string) *Selector {
+	s, err := ParseSelector(selector)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// String returns the selector as it was given to ParseSelector.
+func (s *Selector) String() string {
+	return s.text
+}
+
+// Matcher returns a scrape.Matcher that matches the element nodes selected by s.
+func (s *Selector) Matcher() scrape.Matcher {
+	return s.Match
+}
+
+// Match reports whether the given node is selected by s.
+func (s *Selector) Match(node *html.Node) bool {
+	for _, group := range s.groups {
+		if group.match(node, len(group.steps)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindSubsetBySelector retrieves the HTML nodes within root that match the given CSS selector.
+// As with the other FindSubset functions, the descendants of a matched node are not considered.
+func FindSubsetBySelector(root *html.Node, selector string) ([]*html.Node, error) {
+	s, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return scrape.FindAll(root, s.Matcher()), nil
+}
+
+// match reports whether node matches the steps of c up to and including step.
+func (c *complexSelector) match(node *html.Node, step int) bool {
+	if !c.steps[step].match(node) {
+		return false
+	}
+	if step == 0 {
+		return true
+	}
+
+	switch c.combinators[step] {
+	case '>':
+		parent := node.Parent
+		return parent != nil && c.match(parent, step-1)
+
+	default:
+		for ancestor := node.Parent; ancestor != nil; ancestor = ancestor.Parent {
+			if c.match(ancestor, step-1) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (c *compoundSelector) match(node *html.Node) bool {
+	if node.Type != html.ElementNode {
+		return false
+	}
+	for _, simple := range c.simples {
+		if !simple.match(node) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *simpleSelector) match(node *html.Node) bool {
+	switch s.kind {
+	case simpleTag:
+		return s.name == "*" || strings.EqualFold(node.Data, s.name)
+
+	case simpleId:
+		return scrape.Attr(node, "id") == s.name
+
+	case simpleClass:
+		return HasClass(node, s.name)
+
+	default:
+		value, ok := attrValue(node, s.name)
+		return ok && (s.op == "" || s.op.Match(value, s.value))
+	}
+}
+
+// attrValue looks up the attribute with the given key, ignoring case.
+func attrValue(node *html.Node, key string) (string, bool) {
+	for _, a := range node.Attr {
+		if a.Namespace == "" && strings.EqualFold(a.Key, key) {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// String renders the simple selector in CSS syntax.
+func (s *simpleSelector) String() string {
+	switch s.kind {
+	case simpleTag:
+		return s.name
+	case simpleId:
+		return "#" + s.name
+	case simpleClass:
+		return "." + s.name
+	default:
+		if s.op == "" {
+			return "[" + s.name + "]"
+		}
+		return fmt.Sprintf("[%s%s%q]", s.name, s.op, s.value)
+	}
+}
+
+func (c *compoundSelector) String() string {
+	var b strings.Builder
+	for _, simple := range c.simples {
+		b.WriteString(simple.String())
+	}
+	return b.String()
+}
+
+// prefix renders the steps of c up to and including step in CSS syntax.
+func (c *complexSelector) prefix(step int) string {
+	var b strings.Builder
+	for i := 0; i <= step; i++ {
+		if i > 0 {
+			if c.combinators[i] == '>' {
+				b.WriteString(" > ")
+			} else {
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(c.steps[i].String())
+	}
+	return b.String()
+}
+
+type selectorParser struct {
+	input string
+	pos   int
+}
+
+func (p *selectorParser) parseGroups() ([]complexSelector, error) {
+	var groups []complexSelector
+	for {
+		group, err := p.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+
+		p.skipSpace()
+		if p.pos >= len(p.input) {
+			return groups, nil
+		}
+		if p.input[p.pos] != ',' {
+			return nil, fmt.Errorf("unexpected %q at offset %d", p.input[p.pos], p.pos)
+		}
+		p.pos++
+	}
+}
+
+func (p *selectorParser) parseComplex() (complexSelector, error) {
+	var c complexSelector
+
+	p.skipSpace()
+	combinator := byte(' ')
+	for {
+		step, err := p.parseCompound()
+		if err != nil {
+			return c, err
+		}
+		c.steps = append(c.steps, step)
+		c.combinators = append(c.combinators, combinator)
+
+		sawSpace := p.skipSpace()
+		if p.pos >= len(p.input) || p.input[p.pos] == ',' {
+			return c, nil
+		}
+		switch p.input[p.pos] {
+		case '>':
+			combinator = '>'
+			p.pos++
+			p.skipSpace()
+		default:
+			if !sawSpace {
+				return c, fmt.Errorf("unexpected %q at offset %d", p.input[p.pos], p.pos)
+			}
+			combinator = ' '
+		}
+	}
+}
+
+func (p *selectorParser) parseCompound() (compoundSelector, error) {
+	var c compoundSelector
+
+	if p.pos < len(p.input) && p.input[p.pos] == '*' {
+		p.pos++
+		c.simples = append(c.simples, simpleSelector{kind: simpleTag, name: "*"})
+	} else if name := p.parseIdent(); name != "" {
+		c.simples = append(c.simples, simpleSelector{kind: simpleTag, name: strings.ToLower(name)})
+	}
+
+	for p.pos < len(p.input) {
+		switch p.input[p.pos] {
+		case '#', '.':
+			kind := simpleId
+			if p.input[p.pos] == '.' {
+				kind = simpleClass
+			}
+			p.pos++
+			name := p.parseIdent()
+			if name == "" {
+				return c, fmt.Errorf("expected a name at offset %d", p.pos)
+			}
+			c.simples = append(c.simples, simpleSelector{kind: kind, name: name})
+
+		case '[':
+			p.pos++
+			simple, err := p.parseAttr()
+			if err != nil {
+				return c, err
+			}
+			c.simples = append(c.simples, simple)
+
+		case ':':
+			return c, fmt.Errorf("pseudo-classes are not supported, at offset %d", p.pos)
+
+		default:
+			if len(c.simples) == 0 {
+				return c, fmt.Errorf("expected a selector at offset %d", p.pos)
+			}
+			return c, nil
+		}
+	}
+
+	if len(c.simples) == 0 {
+		return c, fmt.Errorf("expected a selector at offset %d", p.pos)
+	}
+	return c, nil
+}
+
+// parseAttr parses an attribute selector after its opening bracket.
+func (p *selectorParser) parseAttr() (simpleSelector, error) {
+	p.skipSpace()
+	name := p.parseIdent()
+	if name == "" {
+		return simpleSelector{}, fmt.Errorf("expected an attribute name at offset %d", p.pos)
+	}
+	simple := simpleSelector{kind: simpleAttr, name: strings.ToLower(name)}
+
+	p.skipSpace()
+	if op := p.parseAttrOperator(); op != "" {
+		simple.op = op
+		p.skipSpace()
+		value, err := p.parseValue()
+		if err != nil {
+			return simple, err
+		}
+		simple.value = value
+		p.skipSpace()
+	}
+
+	if p.pos >= len(p.input) || p.input[p.pos] != ']' {
+		return simple, fmt.Errorf("expected ] at offset %d", p.pos)
+	}
+	p.pos++
+	return simple, nil
+}
+
+// parseAttrOperator consumes an attribute operator, returning an empty operator if there is none.
+func (p *selectorParser) parseAttrOperator() AttributeOperator {
+	for _, op := range []AttributeOperator{AttributeEquals, AttributePrefix, AttributeSuffix,
+		AttributeContains, AttributeWord, AttributeLang} {
+		if strings.HasPrefix(p.input[p.pos:], string(op)) {
+			p.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+// parseValue parses a quoted string or an identifier.
+func (p *selectorParser) parseValue() (string, error) {
+	if p.pos < len(p.input) && (p.input[p.pos] == '"' || p.input[p.pos] == '\'') {
+		quote := p.input[p.pos]
+		end := strings.IndexByte(p.input[p.pos+1:], quote)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string at offset %d", p.pos)
+		}
+		value := p.input[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return value, nil
+	}
+
+	value := p.parseIdent()
+	if value == "" {
+		return "", fmt.Errorf("expected a value at offset %d", p.pos)
+	}
+	return value, nil
+}
+
+func (p *selectorParser) parseIdent() string {
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if c == '-' || c == '_' || c >= 0x80 ||
+			('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			p.pos++
+		} else {
+			break
+		}
+	}
+	return p.input[start:p.pos]
+}
+
+// skipSpace advances past whitespace, reporting whether there was any.
+func (p *selectorParser) skipSpace() bool {
+	start := p.pos
+	for p.pos < len(p.input) && strings.IndexByte(" \t\n\r\f", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+	return p.pos > start
+}
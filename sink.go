@@ -0,0 +1,259 @@
+package restify
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// Sink stores extracted records, such as in a file or database. Sinks can be given to a Crawler
+// or Ruleset.ExtractTo so that records stream directly to storage.
+type Sink interface {
+	// Write stores a single record.
+	Write(ctx context.Context, record Record) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(ctx context.Context, record Record) error
+
+// Write implements Sink.
+func (f SinkFunc) Write(ctx context.Context, record Record) error {
+	return f(ctx, record)
+}
+
+// ExtractTo applies the ruleset to the document at root as Extract does, writing each record to
+// sink. Records failing the expectations of the ruleset are written regardless, and the
+// ValidationErrors describing the failures are returned once every record has been written.
+func (rs *Ruleset) ExtractTo(ctx context.Context, root *html.Node, sink Sink) error {
+	records, err := rs.Extract(root)
+	if _, invalid := err.(ValidationErrors); err != nil && !invalid {
+		return err
+	}
+	if err := writeRecords(ctx, sink, records); err != nil {
+		return err
+	}
+	return err
+}
+
+// writeRecords writes each record to sink, stopping at the first failure.
+func writeRecords(ctx context.Context, sink Sink, records []Record) error {
+	for _, record := range records {
+		if err := sink.Write(ctx, record); err != nil {
+			return fmt.Errorf("Failed to write record: %w", err)
+		}
+	}
+	return nil
+}
+
+// JsonLinesSink writes each record as a line of JSON, the JSON Lines format. It is safe for
+// concurrent use.
+type JsonLinesSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewJsonLinesSink creates a JsonLinesSink writing to writer, such as an os.File.
+func NewJsonLinesSink(writer io.Writer) *JsonLinesSink {
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	return &JsonLinesSink{encoder: encoder}
+}
+
+// Write implements Sink.
+func (s *JsonLinesSink) Write(_ context.Context, record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.encoder.Encode(record)
+}
+
+// CsvSink writes each record as a row of comma-separated values, after a header row naming the
+// columns. Lists of values are joined with "; " into a single cell. It is safe for concurrent
+// use.
+type CsvSink struct {
+	mu          sync.Mutex
+	writer      *csv.Writer
+	columns     []string
+	wroteHeader bool
+}
+
+// NewCsvSink creates a CsvSink writing to writer. The columns are the names of the fields
+// written, in order, such as the Fields of the Ruleset extracting the records, and fields of
+// records not among them are ignored. Since records omit the fields that matched nothing, the
+// columns are not inferred from them, and writing fails if none are given.
+func NewCsvSink(writer io.Writer, columns ...string) *CsvSink {
+	return &CsvSink{writer: csv.NewWriter(writer), columns: columns}
+}
+
+// Write implements Sink.
+func (s *CsvSink) Write(_ context.Context, record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.columns) == 0 {
+		return errors.New("Unable to write CSV without columns")
+	}
+	if !s.wroteHeader {
+		if err := s.writer.Write(s.columns); err != nil {
+			return err
+		}
+		s.wroteHeader = true
+	}
+
+	row := make([]string, len(s.columns))
+	for i, column := range s.columns {
+		row[i] = strings.Join(recordValues(record[column]), "; ")
+	}
+	if err := s.writer.Write(row); err != nil {
+		return err
+	}
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+// SqlSink inserts each record as a row of a database table through a driver registered with
+// database/sql. Each column holds the text of a field, with lists of values encoded as JSON. It
+// is safe for concurrent use when the database is. No driver is included, so writing to SQLite,
+// for instance, requires importing one such as github.com/mattn/go-sqlite3.
+type SqlSink struct {
+	// DB is the database written to
+	DB *sql.DB
+	// Table is the name of the table inserted into
+	Table string
+	// Columns are the names of the fields inserted, which are also the names of the columns
+	Columns []string
+	// NumberedPlaceholders uses placeholders such as $1, as for PostgreSQL, rather than ?
+	NumberedPlaceholders bool
+}
+
+// NewSqlSink creates a SqlSink inserting the given fields into table of db, such as the Fields of
+// the Ruleset extracting the records.
+func NewSqlSink(db *sql.DB, table string, columns ...string) *SqlSink {
+	return &SqlSink{DB: db, Table: table, Columns: columns}
+}
+
+// CreateTable creates the table written to, with a text column for each field, unless it
+// already exists.
+func (s *SqlSink) CreateTable(ctx context.Context) error {
+	columns := make([]string, len(s.Columns))
+	for i, column := range s.Columns {
+		columns[i] = quoteSqlIdentifier(column) + " TEXT"
+	}
+	statement := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)",
+		quoteSqlIdentifier(s.Table), strings.Join(columns, ", "))
+	if _, err := s.DB.ExecContext(ctx, statement); err != nil {
+		return fmt.Errorf("Failed to create table %s: %w", s.Table, err)
+	}
+	return nil
+}
+
+// Write implements Sink. Fields missing from the record are inserted as NULL.
+func (s *SqlSink) Write(ctx context.Context, record Record) error {
+	columns := make([]string, len(s.Columns))
+	placeholders := make([]string, len(s.Columns))
+	values := make([]interface{}, len(s.Columns))
+	for i, column := range s.Columns {
+		columns[i] = quoteSqlIdentifier(column)
+		placeholders[i] = "?"
+		if s.NumberedPlaceholders {
+			placeholders[i] = "$" + strconv.Itoa(i+1)
+		}
+
+		switch value := record[column].(type) {
+		case string:
+			values[i] = value
+		case nil:
+			values[i] = nil
+		default:
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("Failed to encode field %s: %w", column, err)
+			}
+			values[i] = string(encoded)
+		}
+	}
+
+	statement := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quoteSqlIdentifier(s.Table), strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	if _, err := s.DB.ExecContext(ctx, statement, values...); err != nil {
+		return fmt.Errorf("Failed to insert into %s: %w", s.Table, err)
+	}
+	return nil
+}
+
+// quoteSqlIdentifier quotes the name of a table or column.
+func quoteSqlIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// HttpSink sends each record as the JSON body of a POST request, such as to an ingestion
+// endpoint. It is safe for concurrent use.
+type HttpSink struct {
+	// URL receives the requests
+	URL string
+	// Header holds additional headers sent with each request, such as Authorization
+	Header http.Header
+	// Client sends the requests, a client with a timeout of HttpRequestTimeout if nil
+	Client *http.Client
+}
+
+// NewHttpSink creates an HttpSink posting to url.
+func NewHttpSink(url string) *HttpSink {
+	return &HttpSink{URL: url}
+}
+
+// Write implements Sink. Responses with a status of 300 or more are failures.
+func (s *HttpSink) Write(ctx context.Context, record Record) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("Failed to encode record: %w", err)
+	}
+	request, err := http.NewRequestWithContext(ctx, "POST", s.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Failed to create request: %w", err)
+	}
+	for name, values := range s.Header {
+		request.Header[name] = values
+	}
+	request.Header.Set("content-type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: HttpRequestTimeout}
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("Failed to post record: %w", err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	io.Copy(ioutil.Discard, resp.Body)
+	//goland:noinspection GoUnhandledErrorResult
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Sink responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// recordValues returns the values of a field of a Record as a list.
+func recordValues(value interface{}) []string {
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []string:
+		return value
+	}
+	return nil
+}
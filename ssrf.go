@@ -0,0 +1,189 @@
+package restify
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrHostBlocked is returned, wrapped, when a Loader refuses to connect to a host or address
+// because of the restrictions configured by DenyPrivateNetworks, WithAllowedHosts,
+// WithDeniedHosts, or WithDeniedNetworks.
+var ErrHostBlocked = errors.New("host is not allowed")
+
+// privateNetworks are the ranges refused by DenyPrivateNetworks, in addition to loopback,
+// link-local, private, unspecified, and multicast addresses.
+var privateNetworks = mustParseNetworks(
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved, including broadcast
+	"64:ff9b::/96",  // IPv4/IPv6 translation, which can reach private IPv4 addresses
+	"fc00::/7",      // unique local
+)
+
+// hostFilter restricts the hosts and addresses a Loader connects to.
+type hostFilter struct {
+	allowed     []string
+	denied      []string
+	networks    []*net.IPNet
+	denyPrivate bool
+}
+
+// DenyPrivateNetworks refuses to connect to loopback, private, link-local, and other
+// non-public addresses, such as to defend against server-side request forgery when loading URLs
+// supplied by users. Addresses are checked once host names have been resolved, so host names
+// resolving to private addresses are refused too, as are redirects to them. File URLs are also
+// refused.
+//
+// Addresses are checked when connecting, so when requests are sent through a proxy only
+// hosts given as IP addresses are checked, and the proxy must be trusted to refuse the rest.
+// Connections are only checked for Loaders whose transport is an *http.Transport, as it is by
+// default.
+func DenyPrivateNetworks() LoaderOption {
+	return func(l *Loader) {
+		l.filter().denyPrivate = true
+	}
+}
+
+// WithAllowedHosts only allows requests to hosts matching one of the patterns, which are as
+// described by WithHostPolicy, including for redirects. File URLs are refused.
+func WithAllowedHosts(patterns ...string) LoaderOption {
+	return func(l *Loader) {
+		filter := l.filter()
+		for _, pattern := range patterns {
+			filter.allowed = append(filter.allowed, strings.ToLower(pattern))
+		}
+	}
+}
+
+// WithDeniedHosts refuses requests to hosts matching one of the patterns, which are as
+// described by WithHostPolicy, including for redirects. File URLs are refused.
+func WithDeniedHosts(patterns ...string) LoaderOption {
+	return func(l *Loader) {
+		filter := l.filter()
+		for _, pattern := range patterns {
+			filter.denied = append(filter.denied, strings.ToLower(pattern))
+		}
+	}
+}
+
+// WithDeniedNetworks refuses to connect to addresses within the given networks, which are
+// checked once host names have been resolved as with DenyPrivateNetworks. File URLs are refused.
+func WithDeniedNetworks(networks ...*net.IPNet) LoaderOption {
+	return func(l *Loader) {
+		filter := l.filter()
+		filter.networks = append(filter.networks, networks...)
+	}
+}
+
+// filter returns the Loader's host filter, creating it if needed.
+func (l *Loader) filter() *hostFilter {
+	if l.hostFilter == nil {
+		l.hostFilter = &hostFilter{}
+	}
+	return l.hostFilter
+}
+
+// checkHost returns an error if requests to host are not allowed.
+func (f *hostFilter) checkHost(host string) error {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if len(f.allowed) > 0 {
+		allowed := false
+		for _, pattern := range f.allowed {
+			if matchHostPattern(pattern, host) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("Refusing to request %s: %w", host, ErrHostBlocked)
+		}
+	}
+	for _, pattern := range f.denied {
+		if matchHostPattern(pattern, host) {
+			return fmt.Errorf("Refusing to request %s: %w", host, ErrHostBlocked)
+		}
+	}
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		return f.checkIp(ip)
+	}
+	return nil
+}
+
+// checkIp returns an error if connections to ip are not allowed.
+func (f *hostFilter) checkIp(ip net.IP) error {
+	blocked := false
+	if f.denyPrivate {
+		blocked = ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+			ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || inNetworks(ip, privateNetworks)
+	}
+	if blocked || inNetworks(ip, f.networks) {
+		return fmt.Errorf("Refusing to connect to %s: %w", ip, ErrHostBlocked)
+	}
+	return nil
+}
+
+// control checks the resolved address of each connection before it is made.
+func (f *hostFilter) control(_ string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("Refusing to connect to %s: %w", address, ErrHostBlocked)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("Refusing to connect to %s: %w", address, ErrHostBlocked)
+	}
+	return f.checkIp(ip)
+}
+
+// wrap checks the host of each request sent through next.
+func (f *hostFilter) wrap(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		if err := f.checkHost(request.URL.Hostname()); err != nil {
+			return nil, err
+		}
+		return next.RoundTrip(request)
+	})
+}
+
+// dialer creates the dialer of the Loader's connections, matching that of http.DefaultTransport
+// but checking addresses against the Loader's host filter.
+func (l *Loader) dialer() *net.Dialer {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if l.hostFilter != nil {
+		dialer.Control = l.hostFilter.control
+	}
+	return dialer
+}
+
+// customDialing reports whether connections must be made with the Loader's dialer.
+func (l *Loader) customDialing() bool {
+	return l.hostFilter != nil || l.resolver != nil
+}
+
+func inNetworks(ip net.IP, networks []*net.IPNet) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func mustParseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
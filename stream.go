@@ -0,0 +1,169 @@
+package restify
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// voidElements are the elements that never have content and so never appear on the open element stack.
+var voidElements = map[atom.Atom]bool{
+	atom.Area:   true,
+	atom.Base:   true,
+	atom.Br:     true,
+	atom.Col:    true,
+	atom.Embed:  true,
+	atom.Hr:     true,
+	atom.Img:    true,
+	atom.Input:  true,
+	atom.Keygen: true,
+	atom.Link:   true,
+	atom.Meta:   true,
+	atom.Param:  true,
+	atom.Source: true,
+	atom.Track:  true,
+	atom.Wbr:    true,
+}
+
+// impliedEnds lists, for a start tag, the open elements that it implicitly closes when
+// they are the current element, such as a new <li> ending the previous one.
+var impliedEnds = map[atom.Atom][]atom.Atom{
+	atom.Li:     {atom.Li},
+	atom.P:      {atom.P},
+	atom.Dt:     {atom.Dt, atom.Dd},
+	atom.Dd:     {atom.Dt, atom.Dd},
+	atom.Tr:     {atom.Tr, atom.Td, atom.Th},
+	atom.Td:     {atom.Td, atom.Th},
+	atom.Th:     {atom.Td, atom.Th},
+	atom.Option: {atom.Option},
+}
+
+// StreamMatches tokenizes the HTML content of reader without building the full document tree
+// and calls emit with each element that matches the given matcher. Only the matched element's
+// subtree is constructed, so memory use is bounded by the size of the matched elements rather
+// than the size of the page.
+//
+// The matcher is evaluated against each start tag as it is encountered. At that point the node
+// has its attributes and a Parent chain of the currently open elements, but no children or
+// siblings. As with FindSubsetByClass and friends, the descendants of a matched element are not
+// themselves considered for matching. The emitted node is detached from its parents.
+//
+// Tree construction is lenient: end tags close the nearest matching open element, common
+// implied end tags (such as consecutive <li> or <p>) are honored, and unmatched end tags are
+// ignored. Streaming stops early, without error, when emit returns false.
+func StreamMatches(reader io.Reader, matcher scrape.Matcher, emit func(*html.Node) bool) error {
+	tokenizer := html.NewTokenizer(reader)
+
+	var stack []*html.Node
+	// captured is the matched element currently being built, if any
+	var captured *html.Node
+	// capturedDepth is the stack depth at which captured was pushed
+	capturedDepth := 0
+
+	top := func() *html.Node {
+		if len(stack) == 0 {
+			return nil
+		}
+		return stack[len(stack)-1]
+	}
+
+	// popTo removes the open elements down to and including depth, returning false if emit asked to stop
+	popTo := func(depth int) bool {
+		stack = stack[:depth]
+		if captured != nil && depth <= capturedDepth {
+			done := captured
+			done.Parent = nil
+			captured = nil
+			return emit(done)
+		}
+		return true
+	}
+
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return fmt.Errorf("Failed to tokenize stream: %w", err)
+			}
+			return nil
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+
+			if current := top(); current != nil {
+				for _, closed := range impliedEnds[token.DataAtom] {
+					if current.DataAtom == closed {
+						if !popTo(len(stack) - 1) {
+							return nil
+						}
+						break
+					}
+				}
+			}
+
+			n := &html.Node{
+				Type:     html.ElementNode,
+				DataAtom: token.DataAtom,
+				Data:     token.Data,
+				Attr:     token.Attr,
+			}
+			parent := top()
+			if captured != nil {
+				parent.AppendChild(n)
+			} else {
+				// only link upwards so that ancestors don't retain the whole document
+				n.Parent = parent
+				if matcher(n) {
+					captured = n
+					capturedDepth = len(stack)
+				}
+			}
+
+			if tokenType == html.SelfClosingTagToken || voidElements[token.DataAtom] {
+				if captured == n && !popTo(len(stack)) {
+					return nil
+				}
+			} else {
+				stack = append(stack, n)
+			}
+
+		case html.EndTagToken:
+			token := tokenizer.Token()
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].DataAtom == token.DataAtom && stack[i].Data == token.Data {
+					if !popTo(i) {
+						return nil
+					}
+					break
+				}
+			}
+
+		case html.TextToken, html.CommentToken:
+			if captured != nil {
+				nodeType := html.TextNode
+				if tokenType == html.CommentToken {
+					nodeType = html.CommentNode
+				}
+				top().AppendChild(&html.Node{
+					Type: nodeType,
+					Data: string(tokenizer.Text()),
+				})
+			}
+		}
+	}
+}
+
+// StreamAll is a convenience around StreamMatches that collects up to limit matched elements.
+// A limit of zero or less collects all of them.
+func StreamAll(reader io.Reader, matcher scrape.Matcher, limit int) ([]*html.Node, error) {
+	var matched []*html.Node
+	err := StreamMatches(reader, matcher, func(n *html.Node) bool {
+		matched = append(matched, n)
+		return limit <= 0 || len(matched) < limit
+	})
+	return matched, err
+}
@@ -0,0 +1,123 @@
+package restify
+
+import (
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// WithTemplateExpansion expands the <template> elements of each page as ExpandTemplates does,
+// before the page is returned.
+func WithTemplateExpansion() LoaderOption {
+	return func(l *Loader) {
+		l.transforms = append(l.transforms, ExpandTemplates)
+	}
+}
+
+// ExpandTemplates rewrites the tree at root into the form a browser renders, so that content
+// shipped within <template> elements, as server-side rendering frameworks do, is searched and
+// extracted like the rest of the page.
+//
+// A declarative shadow root, being a <template shadowrootmode> element, is attached to its
+// parent: the template's content takes the place of the parent's children, which are each
+// assigned to the <slot> element of the shadow root with the matching name, replacing the
+// slot's fallback content. Children not assigned to any slot, which a browser would not render,
+// are kept after the shadow root's content, so that their content can still be extracted.
+// Other <template> elements are replaced by their content.
+func ExpandTemplates(root *html.Node) {
+	templates := scrape.FindAllNested(root, scrape.ByTag(atom.Template))
+	// innermost first, so that nested shadow roots are attached before their content moves
+	for i := len(templates) - 1; i >= 0; i-- {
+		template := templates[i]
+		if template.Parent == nil {
+			continue
+		}
+		if isShadowRoot(template) && template.Parent.Type == html.ElementNode {
+			attachShadowRoot(template)
+		} else {
+			unwrapNode(template)
+		}
+	}
+}
+
+// isShadowRoot reports whether template declares a shadow root, including with the
+// shadowroot attribute of earlier drafts.
+func isShadowRoot(template *html.Node) bool {
+	mode, ok := attrValue(template, "shadowrootmode")
+	if !ok {
+		mode, ok = attrValue(template, "shadowroot")
+	}
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	return ok && (mode == "open" || mode == "closed")
+}
+
+// attachShadowRoot replaces the children of the template's parent with the template's content,
+// assigning the parent's former children to its slots, and appending those left unassigned.
+func attachShadowRoot(template *html.Node) {
+	host := template.Parent
+	host.RemoveChild(template)
+	var light []*html.Node
+	for child := host.FirstChild; child != nil; child = host.FirstChild {
+		host.RemoveChild(child)
+		light = append(light, child)
+	}
+	moveChildren(template, host, nil)
+
+	assigned := make(map[string]bool)
+	for _, slot := range scrape.FindAllNested(host, scrape.ByTag(atom.Slot)) {
+		name := strings.TrimSpace(scrape.Attr(slot, "name"))
+		if assigned[name] {
+			continue
+		}
+		assigned[name] = true
+
+		var slotted []*html.Node
+		for _, n := range light {
+			if n.Parent == nil && slotName(n) == name {
+				slotted = append(slotted, n)
+			}
+		}
+		if len(slotted) == 0 {
+			// the fallback content is shown
+			continue
+		}
+		for child := slot.FirstChild; child != nil; child = slot.FirstChild {
+			slot.RemoveChild(child)
+		}
+		for _, n := range slotted {
+			slot.AppendChild(n)
+		}
+	}
+
+	for _, n := range light {
+		if n.Parent == nil {
+			host.AppendChild(n)
+		}
+	}
+}
+
+// slotName is the name of the slot that a child of a shadow host is assigned to. Text is only
+// ever assigned to the default slot, whose name is empty.
+func slotName(n *html.Node) string {
+	if n.Type != html.ElementNode {
+		return ""
+	}
+	return strings.TrimSpace(scrape.Attr(n, "slot"))
+}
+
+// unwrapNode replaces n with its children.
+func unwrapNode(n *html.Node) {
+	moveChildren(n, n.Parent, n)
+	n.Parent.RemoveChild(n)
+}
+
+// moveChildren moves the children of from into to, before the child before, or at the end
+// if before is nil.
+func moveChildren(from *html.Node, to *html.Node, before *html.Node) {
+	for child := from.FirstChild; child != nil; child = from.FirstChild {
+		from.RemoveChild(child)
+		to.InsertBefore(child, before)
+	}
+}
@@ -0,0 +1,111 @@
+package restify
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// TextOptions adjusts how ToText converts HTML.
+type TextOptions struct {
+	// LinksAsFootnotes numbers each link, as in "the report [1]", and lists the URLs of the
+	// links after the text. Otherwise the URL of each link follows it in parentheses.
+	LinksAsFootnotes bool
+	// Width, when positive, wraps paragraphs to lines of at most this many characters, except
+	// for words that are longer
+	Width int
+	// BaseURL, when set, resolves relative link URLs
+	BaseURL *url.URL
+}
+
+// ToText converts the HTML at node into plain text in the style of an email, such as for
+// notifications about scraped content. Paragraphs are separated by blank lines, the first two
+// levels of headings are underlined, list items are marked with * or their number, and block
+// quotes are prefixed with >. Scripts, styles, forms, and the document head are omitted.
+func ToText(node *html.Node, opts TextOptions) string {
+	c := htmlConverter{
+		markdown: MarkdownOptions{BaseURL: opts.BaseURL},
+		plain:    true,
+		text:     opts,
+	}
+	text := joinBlocks(c.blocks([]*html.Node{node}), "\n\n")
+	if len(c.footnotes) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	b.WriteString(text)
+	b.WriteString("\n")
+	for i, link := range c.footnotes {
+		b.WriteString("\n[" + strconv.Itoa(i+1) + "] " + link)
+	}
+	return b.String()
+}
+
+// plainLink writes a link with the given text to w, along with its URL as a footnote or in
+// parentheses. The URL is omitted when it is the same as the text, as it often is for email
+// addresses.
+func (c *htmlConverter) plainLink(text string, href string, w *inlineWriter) {
+	if c.markdown.BaseURL != nil {
+		if resolved, ok := resolveReference(c.markdown.BaseURL, href); ok {
+			href = resolved.String()
+		}
+	}
+	if text == "" {
+		w.raw(href)
+		return
+	}
+	w.raw(text)
+	if text == href || "mailto:"+text == href {
+		return
+	}
+
+	w.pending = true
+	if !c.text.LinksAsFootnotes {
+		w.raw("(" + href + ")")
+		return
+	}
+	number, ok := c.footnoteNumbers[href]
+	if !ok {
+		if c.footnoteNumbers == nil {
+			c.footnoteNumbers = make(map[string]int)
+		}
+		c.footnotes = append(c.footnotes, href)
+		number = len(c.footnotes)
+		c.footnoteNumbers[href] = number
+	}
+	w.raw("[" + strconv.Itoa(number) + "]")
+}
+
+// wrapText wraps each line of text at spaces into lines of at most width characters. Text is
+// left as it is when width is not positive.
+func wrapText(text string, width int) string {
+	if width <= 0 {
+		return text
+	}
+	var b strings.Builder
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		length := 0
+		for j, word := range strings.Fields(line) {
+			wordLength := utf8.RuneCountInString(word)
+			switch {
+			case j == 0:
+			case length+1+wordLength > width:
+				b.WriteString("\n")
+				length = 0
+			default:
+				b.WriteString(" ")
+				length++
+			}
+			b.WriteString(word)
+			length += wordLength
+		}
+	}
+	return b.String()
+}
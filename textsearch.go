@@ -0,0 +1,92 @@
+package restify
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+)
+
+// TextMatchOptions adjusts how FindByText compares the text of elements.
+type TextMatchOptions struct {
+	// IgnoreCase compares text without regard to case
+	IgnoreCase bool
+	// NormalizeSpace collapses each run of whitespace, including non-breaking spaces, to a
+	// single space before comparing
+	NormalizeSpace bool
+	// Substring matches elements whose text contains the text sought, rather than equals it
+	Substring bool
+	// Pattern, when set, matches elements whose text it matches, and the text sought is ignored
+	Pattern *regexp.Regexp
+}
+
+// FindByText locates the elements within root whose text content, as given by scrape.Text,
+// matches text according to opts, such as the button labelled "Continue". Since an element's
+// text includes that of its descendants, only the most specific matches are returned: those
+// without a child element that also matches. Script and style elements are not searched.
+func FindByText(root *html.Node, text string, opts TextMatchOptions) []*html.Node {
+	matches := func(node *html.Node) bool {
+		if node.Type != html.ElementNode || isNonTextElement(node) {
+			return false
+		}
+		return opts.match(scrape.Text(node), text)
+	}
+
+	var found []*html.Node
+	var visit func(node *html.Node) bool
+	// visit reports whether node or any of its descendants matched
+	visit = func(node *html.Node) bool {
+		childMatched := false
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && !isNonTextElement(c) && visit(c) {
+				childMatched = true
+			}
+		}
+		if childMatched {
+			return true
+		}
+		if matches(node) {
+			found = append(found, node)
+			return true
+		}
+		return false
+	}
+	visit(root)
+	return found
+}
+
+func (o *TextMatchOptions) match(candidate, text string) bool {
+	if o.NormalizeSpace {
+		candidate = normalizeSpace(candidate)
+		text = normalizeSpace(text)
+	}
+	if o.Pattern != nil {
+		return o.Pattern.MatchString(candidate)
+	}
+	if o.IgnoreCase {
+		if !o.Substring {
+			return strings.EqualFold(candidate, text)
+		}
+		candidate = strings.ToLower(candidate)
+		text = strings.ToLower(text)
+	}
+	if o.Substring {
+		return strings.Contains(candidate, text)
+	}
+	return candidate == text
+}
+
+func normalizeSpace(s string) string {
+	// unicode.IsSpace, used by Fields, includes non-breaking spaces
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// isNonTextElement reports whether node holds content other than readable text.
+func isNonTextElement(node *html.Node) bool {
+	switch node.Data {
+	case "script", "style", "noscript", "template":
+		return true
+	}
+	return false
+}
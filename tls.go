@@ -0,0 +1,52 @@
+package restify
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+)
+
+// WithTLSConfig replaces the TLS configuration of the Loader's transport with a copy of config,
+// such as for servers requiring particular protocol versions or cipher suites. Options adjusting
+// the TLS configuration that are given before this one are overridden by it.
+func WithTLSConfig(config *tls.Config) LoaderOption {
+	return WithTransportTuning(func(transport *http.Transport) {
+		transport.TLSClientConfig = config.Clone()
+	})
+}
+
+// WithClientCert presents certificate to servers that request a client certificate, for mutual
+// TLS. A certificate can be loaded from PEM files with tls.LoadX509KeyPair.
+func WithClientCert(certificate tls.Certificate) LoaderOption {
+	return withTLSTuning(func(config *tls.Config) {
+		config.Certificates = append(config.Certificates, certificate)
+	})
+}
+
+// WithRootCAs verifies servers against the certificate authorities in pool, in place of the
+// system's, such as for internal systems using a private CA. To trust a private CA as well as
+// the system's, add it to the pool returned by x509.SystemCertPool.
+func WithRootCAs(pool *x509.CertPool) LoaderOption {
+	return withTLSTuning(func(config *tls.Config) {
+		config.RootCAs = pool
+	})
+}
+
+// WithInsecureSkipVerify disables verification of server certificates. This leaves connections
+// open to interception, so it should only be used for testing or trusted networks.
+func WithInsecureSkipVerify() LoaderOption {
+	return withTLSTuning(func(config *tls.Config) {
+		config.InsecureSkipVerify = true
+	})
+}
+
+// withTLSTuning adjusts the TLS configuration of the Loader's transport, creating one if needed.
+// Cloning the transport also clones its TLS configuration, so it may be modified in place.
+func withTLSTuning(adjust func(config *tls.Config)) LoaderOption {
+	return WithTransportTuning(func(transport *http.Transport) {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		adjust(transport.TLSClientConfig)
+	})
+}
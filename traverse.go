@@ -0,0 +1,91 @@
+package restify
+
+import (
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+)
+
+// Closest locates the nearest node, starting with the given node itself and then its
+// ancestors, that satisfies matcher. If there is no such node, then ok will be false.
+func Closest(node *html.Node, matcher scrape.Matcher) (n *html.Node, ok bool) {
+	for n = node; n != nil; n = n.Parent {
+		if matcher(n) {
+			return n, true
+		}
+	}
+	return nil, false
+}
+
+// Parents retrieves the element ancestors of the given node, nearest first.
+func Parents(node *html.Node) []*html.Node {
+	var parents []*html.Node
+	for p := node.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode {
+			parents = append(parents, p)
+		}
+	}
+	return parents
+}
+
+// NextSibling locates the sibling after the given node, skipping over comments and text nodes
+// containing only whitespace. It returns nil if there is no such sibling.
+func NextSibling(node *html.Node) *html.Node {
+	for s := node.NextSibling; s != nil; s = s.NextSibling {
+		if !isIgnorable(s) {
+			return s
+		}
+	}
+	return nil
+}
+
+// PrevSibling locates the sibling before the given node, skipping over comments and text nodes
+// containing only whitespace. It returns nil if there is no such sibling.
+func PrevSibling(node *html.Node) *html.Node {
+	for s := node.PrevSibling; s != nil; s = s.PrevSibling {
+		if !isIgnorable(s) {
+			return s
+		}
+	}
+	return nil
+}
+
+// NextElementSibling locates the element after the given node, skipping any other kind of node.
+// It returns nil if there is no such element.
+func NextElementSibling(node *html.Node) *html.Node {
+	for s := node.NextSibling; s != nil; s = s.NextSibling {
+		if s.Type == html.ElementNode {
+			return s
+		}
+	}
+	return nil
+}
+
+// PrevElementSibling locates the element before the given node, skipping any other kind of node.
+// It returns nil if there is no such element.
+func PrevElementSibling(node *html.Node) *html.Node {
+	for s := node.PrevSibling; s != nil; s = s.PrevSibling {
+		if s.Type == html.ElementNode {
+			return s
+		}
+	}
+	return nil
+}
+
+// ChildrenElements retrieves the element children of the given node, in document order.
+func ChildrenElements(node *html.Node) []*html.Node {
+	var children []*html.Node
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			children = append(children, c)
+		}
+	}
+	return children
+}
+
+// isIgnorable reports whether the node is insignificant for sibling navigation.
+func isIgnorable(node *html.Node) bool {
+	return node.Type == html.CommentNode ||
+		(node.Type == html.TextNode && strings.TrimSpace(node.Data) == "")
+}
@@ -0,0 +1,213 @@
+package restify
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// previewImageWidth is the width at which preview images are chosen from srcset candidates.
+const previewImageWidth = 1200
+
+// maxPreviewDescription is the number of characters of a paragraph used as the description of
+// pages that do not declare one.
+const maxPreviewDescription = 300
+
+// minPreviewImageSize is the size below which images are not used as the image of pages that
+// do not declare one, since they are usually icons or tracking pixels.
+const minPreviewImageSize = 100
+
+// Preview summarizes a page for display as a card, as chat applications do when a link is
+// posted.
+type Preview struct {
+	// URL is the location the page was loaded from, after any redirects
+	URL string `json:"url"`
+	// CanonicalURL is the preferred URL of the page, as determined by CanonicalURL
+	CanonicalURL string `json:"canonicalUrl"`
+	// Title is the title of the page
+	Title string `json:"title,omitempty"`
+	// Description summarizes the page
+	Description string `json:"description,omitempty"`
+	// Image is the URL of an image representing the page
+	Image string `json:"image,omitempty"`
+	// SiteName is the name of the site the page belongs to
+	SiteName string `json:"siteName,omitempty"`
+	// Favicon is the URL of the site's icon, as determined by ResolveFavicon
+	Favicon string `json:"favicon,omitempty"`
+	// Type is the og:type of the page, such as "article" or "video.other"
+	Type string `json:"type,omitempty"`
+}
+
+// Unfurl loads the page at url and summarizes it as a Preview. Since the URLs unfurled are often
+// given by untrusted users, such as those of links posted in chats, only http and https URLs are
+// loaded, and connections to loopback, private, and other non-public addresses are refused, as
+// with DenyPrivateNetworks. Use Loader.Unfurl to unfurl URLs without these restrictions.
+func Unfurl(url *url.URL) (*Preview, error) {
+	if url.Scheme != "http" && url.Scheme != "https" {
+		return nil, fmt.Errorf("Refusing to unfurl %s: %w", url, ErrHostBlocked)
+	}
+	return NewLoader(DenyPrivateNetworks()).UnfurlContext(context.Background(), url)
+}
+
+// Unfurl loads the page at url and summarizes it as a Preview, as ExtractPreview does. When
+// the page does not declare the name of its site, the name in its web app manifest is used,
+// if it has one that can be loaded. Pages responding with an error status are an error.
+func (l *Loader) Unfurl(url *url.URL) (*Preview, error) {
+	return l.UnfurlContext(context.Background(), url)
+}
+
+// UnfurlContext is like Unfurl but the requests are bound to the given context, in addition to
+// the Loader's timeout.
+func (l *Loader) UnfurlContext(ctx context.Context, url *url.URL) (*Preview, error) {
+	resp, err := l.Fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("Page responded with status %d", resp.StatusCode)
+	}
+
+	preview := ExtractPreview(resp.Root, resp.URL)
+	if SiteName(resp.Root) == "" {
+		if manifest, err := l.LoadManifest(ctx, resp.Root, resp.URL); err == nil {
+			switch {
+			case manifest.Name != "":
+				preview.SiteName = manifest.Name
+			case manifest.ShortName != "":
+				preview.SiteName = manifest.ShortName
+			}
+		}
+	}
+	return preview, nil
+}
+
+// ExtractPreview summarizes the page at root, which was retrieved from pageUrl, as a Preview.
+// The OpenGraph and Twitter card meta tags of the page are preferred, falling back to its
+// title, meta description, first paragraph, and first sizeable image. The site name falls back
+// to the host of the page, without any "www." prefix.
+func ExtractPreview(root *html.Node, pageUrl *url.URL) *Preview {
+	preview := &Preview{
+		URL:          pageUrl.String(),
+		CanonicalURL: CanonicalURL(root, pageUrl).String(),
+		Title:        metaContent(root, "og:title", "twitter:title"),
+		Description:  metaContent(root, "og:description", "twitter:description", "description"),
+		SiteName:     SiteName(root),
+		Favicon:      ResolveFavicon(root, pageUrl).String(),
+		Type:         metaContent(root, "og:type"),
+	}
+
+	if preview.Title == "" {
+		if title, ok := scrape.Find(root, scrape.ByTag(atom.Title)); ok {
+			preview.Title = strings.Join(strings.Fields(scrape.Text(title)), " ")
+		}
+	}
+	if preview.Title == "" {
+		if heading, ok := scrape.Find(root, scrape.ByTag(atom.H1)); ok {
+			preview.Title = scrape.Text(heading)
+		}
+	}
+	if preview.Description == "" {
+		preview.Description = firstParagraph(root)
+	}
+	if preview.SiteName == "" {
+		preview.SiteName = strings.TrimPrefix(strings.ToLower(pageUrl.Hostname()), "www.")
+	}
+
+	image := metaContent(root, "og:image:secure_url", "og:image", "og:image:url", "twitter:image", "twitter:image:src")
+	if image == "" {
+		if link, ok := scrape.Find(root, matchLinkRel("image_src")); ok {
+			image = strings.TrimSpace(scrape.Attr(link, "href"))
+		}
+	}
+	if image == "" {
+		image = firstContentImage(root)
+	}
+	if image != "" {
+		if imageUrl, ok := resolveReference(pageUrl, image); ok {
+			preview.Image = imageUrl.String()
+		}
+	}
+	return preview
+}
+
+// metaContent returns the content of the first of the given meta properties or names that the
+// page at root declares with a non-empty value.
+func metaContent(root *html.Node, properties ...string) string {
+	for _, property := range properties {
+		if meta, ok := scrape.Find(root, matchMetaProperty(property)); ok {
+			if content := strings.TrimSpace(scrape.Attr(meta, "content")); content != "" {
+				return content
+			}
+		}
+	}
+	return ""
+}
+
+// firstParagraph returns the text of the first non-empty paragraph of the page at root,
+// preferring one within its main content, shortened to maxPreviewDescription characters.
+func firstParagraph(root *html.Node) string {
+	text := ""
+	for _, scope := range contentScopes(root) {
+		for _, p := range scrape.FindAll(scope, scrape.ByTag(atom.P)) {
+			if text = strings.Join(strings.Fields(scrape.Text(p)), " "); text != "" {
+				break
+			}
+		}
+		if text != "" {
+			break
+		}
+	}
+	if utf8.RuneCountInString(text) <= maxPreviewDescription {
+		return text
+	}
+
+	shortened := []rune(text)[:maxPreviewDescription]
+	if space := strings.LastIndex(string(shortened), " "); space > 0 {
+		return string(shortened)[:space] + "…"
+	}
+	return string(shortened) + "…"
+}
+
+// firstContentImage returns the source of the first image of the page at root that is not
+// declared smaller than minPreviewImageSize, preferring one within its main content.
+func firstContentImage(root *html.Node) string {
+	for _, scope := range contentScopes(root) {
+		for _, img := range scrape.FindAll(scope, scrape.ByTag(atom.Img)) {
+			if isSmallImage(img) {
+				continue
+			}
+			if src, ok := BestImage(img, previewImageWidth); ok && !strings.HasPrefix(src, "data:") {
+				return src
+			}
+		}
+	}
+	return ""
+}
+
+// isSmallImage reports whether img declares a width or height below minPreviewImageSize.
+func isSmallImage(img *html.Node) bool {
+	for _, key := range []string{"width", "height"} {
+		if size, err := strconv.Atoi(scrape.Attr(img, key)); err == nil && size < minPreviewImageSize {
+			return true
+		}
+	}
+	return false
+}
+
+// contentScopes returns the main content elements of the page at root, followed by root itself.
+func contentScopes(root *html.Node) []*html.Node {
+	var scopes []*html.Node
+	for _, tag := range []atom.Atom{atom.Main, atom.Article} {
+		if n, ok := scrape.Find(root, scrape.ByTag(tag)); ok {
+			scopes = append(scopes, n)
+		}
+	}
+	return append(scopes, root)
+}
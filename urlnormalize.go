@@ -0,0 +1,217 @@
+package restify
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// DefaultTrackingParams are the query parameters dropped by DefaultURLNormalizer, which track
+// the campaign or click a visitor came from rather than select content.
+var DefaultTrackingParams = []string{
+	"utm_*", "gclid", "gclsrc", "dclid", "fbclid", "msclkid", "yclid", "igshid", "mc_cid", "mc_eid",
+	"_ga", "_gl", "_hsenc", "_hsmi", "mkt_tok",
+}
+
+// DefaultURLNormalizer is used by NormalizeURL and, unless it or its Loader is configured
+// otherwise, by Crawler to recognize the URLs of pages already seen.
+var DefaultURLNormalizer = &URLNormalizer{
+	DropParams:        DefaultTrackingParams,
+	SortQuery:         true,
+	TrimTrailingSlash: true,
+}
+
+// URLNormalizer reduces the variations of URLs that address the same resource, so that they
+// compare equal. Every normalization lowercases the scheme and host, converts internationalized
+// host names to their ASCII punycode form, removes the default port of the scheme, the trailing
+// dot of the host, dot segments of the path, and the fragment, uppercases percent-encodings,
+// and gives an empty path as "/". Its zero value does only these, which never change the
+// resource addressed, so is how a Loader normalizes the URLs it requests unless configured
+// WithURLNormalizer.
+type URLNormalizer struct {
+	// DropParams are the query parameters removed, by name or by a prefix followed by "*", such
+	// as "utm_*"
+	DropParams []string
+	// SortQuery sorts the query parameters by name, keeping the order of repeated parameters
+	SortQuery bool
+	// TrimTrailingSlash removes the trailing slash of paths other than "/". Most servers treat
+	// such paths the same, or redirect between them.
+	TrimTrailingSlash bool
+	// KeepFragment keeps the fragment, which is otherwise removed since it is not sent to servers
+	KeepFragment bool
+}
+
+// percentEncoding matches a percent-encoded byte.
+var percentEncoding = regexp.MustCompile(`%[0-9a-fA-F]{2}`)
+
+// NormalizeURL normalizes the absolute URL raw with DefaultURLNormalizer, such as to compare or
+// deduplicate URLs. For example, "HTTP://Bücher.example:80/a/./b/?utm_source=x&z=1&a=2#top"
+// becomes "http://xn--bcher-kva.example/a/b?a=2&z=1".
+func NormalizeURL(raw string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse URL: %w", err)
+	}
+	normalized, err := DefaultURLNormalizer.Normalize(parsed)
+	if err != nil {
+		return "", err
+	}
+	return normalized.String(), nil
+}
+
+// Normalize returns a normalized copy of the absolute URL u.
+func (n *URLNormalizer) Normalize(u *url.URL) (*url.URL, error) {
+	if !u.IsAbs() {
+		return nil, fmt.Errorf("Unable to normalize relative URL %s", u)
+	}
+	if u.Opaque != "" {
+		// URLs such as mailto:someone@example.com have no host or path to normalize
+		normalized := *u
+		normalized.Scheme = strings.ToLower(u.Scheme)
+		return &normalized, nil
+	}
+
+	// resolving an absolute URL removes the dot segments of its path
+	normalized := (&url.URL{}).ResolveReference(u)
+	normalized.Scheme = strings.ToLower(normalized.Scheme)
+
+	host, err := normalizeHost(normalized.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("Unable to normalize the host of %s: %w", u, err)
+	}
+	port := normalized.Port()
+	if isDefaultPort(normalized.Scheme, port) {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	normalized.Host = host
+
+	if normalized.Path == "" {
+		normalized.Path = "/"
+	}
+	if n.TrimTrailingSlash && len(normalized.Path) > 1 && strings.HasSuffix(normalized.Path, "/") {
+		normalized.Path = strings.TrimRight(normalized.Path, "/")
+		if normalized.Path == "" {
+			normalized.Path = "/"
+		}
+		normalized.RawPath = strings.TrimRight(normalized.RawPath, "/")
+	}
+	normalized.RawPath = percentEncoding.ReplaceAllStringFunc(normalized.RawPath, strings.ToUpper)
+
+	normalized.RawQuery = n.normalizeQuery(normalized.RawQuery)
+	normalized.ForceQuery = false
+	if !n.KeepFragment {
+		normalized.Fragment = ""
+		normalized.RawFragment = ""
+	}
+	return normalized, nil
+}
+
+// normalizeQuery drops and sorts the parameters of a query, keeping the encoding of those kept.
+func (n *URLNormalizer) normalizeQuery(query string) string {
+	if query == "" {
+		return ""
+	}
+	type parameter struct {
+		name string
+		pair string
+	}
+	var parameters []parameter
+	for _, pair := range strings.Split(query, "&") {
+		if pair == "" {
+			continue
+		}
+		pair = percentEncoding.ReplaceAllStringFunc(pair, strings.ToUpper)
+		name := pair
+		if equals := strings.IndexByte(pair, '='); equals >= 0 {
+			name = pair[:equals]
+		}
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if !n.dropped(name) {
+			parameters = append(parameters, parameter{name, pair})
+		}
+	}
+	if n.SortQuery {
+		sort.SliceStable(parameters, func(i, j int) bool {
+			return parameters[i].name < parameters[j].name
+		})
+	}
+
+	pairs := make([]string, len(parameters))
+	for i, p := range parameters {
+		pairs[i] = p.pair
+	}
+	return strings.Join(pairs, "&")
+}
+
+// dropped reports whether the query parameter named name is removed.
+func (n *URLNormalizer) dropped(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range n.DropParams {
+		pattern = strings.ToLower(pattern)
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHost lowercases host, converting internationalized names to punycode.
+func normalizeHost(host string) (string, error) {
+	host = strings.TrimSuffix(host, ".")
+	if net.ParseIP(host) != nil {
+		return strings.ToLower(host), nil
+	}
+	for _, r := range host {
+		if r >= 0x80 {
+			return idna.Lookup.ToASCII(host)
+		}
+	}
+	return strings.ToLower(host), nil
+}
+
+// isDefaultPort reports whether port is the one implied by scheme.
+func isDefaultPort(scheme, port string) bool {
+	switch scheme {
+	case "http", "ws":
+		return port == "80"
+	case "https", "wss":
+		return port == "443"
+	case "ftp":
+		return port == "21"
+	}
+	return false
+}
+
+// WithURLNormalizer normalizes each URL requested by the Loader with normalizer, such as to drop
+// tracking parameters, in place of the zero URLNormalizer. The normalizer is also used for the
+// keys of its DocumentStore, by Loader.ExtractLinks, and by Crawlers using the Loader.
+func WithURLNormalizer(normalizer *URLNormalizer) LoaderOption {
+	return func(l *Loader) {
+		l.urlNormalizer = normalizer
+	}
+}
+
+// normalizer returns the URLNormalizer of the URLs the Loader requests.
+func (l *Loader) normalizer() *URLNormalizer {
+	if l.urlNormalizer == nil {
+		return &URLNormalizer{}
+	}
+	return l.urlNormalizer
+}
@@ -0,0 +1,53 @@
+package restify
+
+import "sync/atomic"
+
+// Realistic browser user-agent strings for use with WithUserAgent and WithUserAgentRotation.
+const (
+	UserAgentChromeWindows  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	UserAgentChromeMac      = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	UserAgentChromeLinux    = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	UserAgentChromeAndroid  = "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36"
+	UserAgentFirefoxWindows = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0"
+	UserAgentFirefoxMac     = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:121.0) Gecko/20100101 Firefox/121.0"
+	UserAgentFirefoxLinux   = "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+	UserAgentSafariMac      = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15"
+	UserAgentSafariIPhone   = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1"
+	UserAgentEdgeWindows    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0"
+)
+
+// DesktopUserAgents is a catalog of the desktop browser user-agents, suitable for
+// WithUserAgentRotation.
+var DesktopUserAgents = []string{
+	UserAgentChromeWindows,
+	UserAgentChromeMac,
+	UserAgentChromeLinux,
+	UserAgentFirefoxWindows,
+	UserAgentFirefoxMac,
+	UserAgentFirefoxLinux,
+	UserAgentSafariMac,
+	UserAgentEdgeWindows,
+}
+
+// MobileUserAgents is a catalog of the mobile browser user-agents, suitable for WithUserAgentRotation.
+var MobileUserAgents = []string{
+	UserAgentChromeAndroid,
+	UserAgentSafariIPhone,
+}
+
+// WithUserAgentRotation cycles through the given user-agents, using the next one for each
+// request so that long crawls vary their user-agent. It takes precedence over WithUserAgent.
+func WithUserAgentRotation(userAgents []string) LoaderOption {
+	return func(l *Loader) {
+		l.userAgents = append([]string(nil), userAgents...)
+	}
+}
+
+// nextUserAgent picks the user-agent to send with the next request.
+func (l *Loader) nextUserAgent() string {
+	if len(l.userAgents) == 0 {
+		return l.userAgent
+	}
+	next := atomic.AddUint32(&l.userAgentIndex, 1) - 1
+	return l.userAgents[next%uint32(len(l.userAgents))]
+}
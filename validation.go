@@ -0,0 +1,157 @@
+package restify
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RuleType is the kind of value expected of a Rule.
+type RuleType string
+
+const (
+	// RuleTypeString accepts any value
+	RuleTypeString RuleType = ""
+	// RuleTypeNumber expects a number, as accepted by ParseNumber without a locale
+	RuleTypeNumber RuleType = "number"
+	// RuleTypeInteger expects a whole number, as accepted by ParseNumber without a locale
+	RuleTypeInteger RuleType = "integer"
+	// RuleTypePrice expects a price, as accepted by ParsePrice
+	RuleTypePrice RuleType = "price"
+	// RuleTypeDate expects a date, as accepted by ParseDate without hints
+	RuleTypeDate RuleType = "date"
+	// RuleTypeUrl expects an absolute URL, or a reference starting with / resolved against the page
+	RuleTypeUrl RuleType = "url"
+)
+
+func (t RuleType) valid() bool {
+	switch t {
+	case RuleTypeString, RuleTypeNumber, RuleTypeInteger, RuleTypePrice, RuleTypeDate, RuleTypeUrl:
+		return true
+	}
+	return false
+}
+
+// check reports why value is not of the type, or an empty string if it is.
+func (t RuleType) check(value string) string {
+	var err error
+	switch t {
+	case RuleTypeNumber, RuleTypeInteger:
+		var number float64
+		number, err = ParseNumber(value, "")
+		if err == nil && t == RuleTypeInteger && number != float64(int64(number)) {
+			return "is not a whole number"
+		}
+	case RuleTypePrice:
+		_, err = ParsePrice(value)
+	case RuleTypeDate:
+		_, err = ParseDate(value, DateHints{})
+	case RuleTypeUrl:
+		var parsed *url.URL
+		parsed, err = url.Parse(value)
+		if err == nil && !parsed.IsAbs() && !strings.HasPrefix(value, "/") {
+			return "is not an absolute URL"
+		}
+	}
+	if err != nil {
+		switch t {
+		case RuleTypeInteger:
+			return "is not an integer"
+		case RuleTypeUrl:
+			return "is not a URL"
+		}
+		return "is not a " + string(t)
+	}
+	return ""
+}
+
+// ValidationError describes how a page failed the expectations of a Ruleset.
+type ValidationError struct {
+	// Item is the index of the item that failed, or -1 if the page as a whole failed
+	Item int
+	// Rule is the name of the rule that failed, or empty if the page as a whole failed
+	Rule string
+	// Value is the value that failed, if any
+	Value string
+	// Reason describes the failure
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	switch {
+	case e.Rule == "":
+		return e.Reason
+	case e.Value != "":
+		return fmt.Sprintf("item %d field %s value %q %s", e.Item, e.Rule, e.Value, e.Reason)
+	default:
+		return fmt.Sprintf("item %d field %s %s", e.Item, e.Rule, e.Reason)
+	}
+}
+
+// ValidationErrors are every failure of a page to meet the expectations of a Ruleset, as
+// returned by Ruleset.Extract along with the records extracted.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	if len(e) == 1 {
+		return "Validation failed: " + e[0].Error()
+	}
+	reasons := make([]string, len(e))
+	for i, failure := range e {
+		reasons[i] = failure.Error()
+	}
+	return fmt.Sprintf("Validation failed %d times: %s", len(e), strings.Join(reasons, "; "))
+}
+
+// validate checks the record of item index against the expectations of the rules, whose
+// compiled patterns are given by patterns.
+func (rs *Ruleset) validate(index int, record Record, patterns []*regexp.Regexp) ValidationErrors {
+	var failures ValidationErrors
+	fail := func(rule *Rule, value string, reason string) {
+		failures = append(failures, &ValidationError{Item: index, Rule: rule.Name, Value: value, Reason: reason})
+	}
+
+	for i := range rs.Rules {
+		rule := &rs.Rules[i]
+		values := recordValues(record[rule.Name])
+
+		present := 0
+		for _, value := range values {
+			if value != "" {
+				present++
+			}
+		}
+		switch {
+		case rule.Required && present == 0:
+			fail(rule, "", "is missing")
+		case rule.Multiple && len(values) < rule.MinCount:
+			fail(rule, "", fmt.Sprintf("has %d values rather than at least %d", len(values), rule.MinCount))
+		case rule.Multiple && rule.MaxCount > 0 && len(values) > rule.MaxCount:
+			fail(rule, "", fmt.Sprintf("has %d values rather than at most %d", len(values), rule.MaxCount))
+		}
+
+		for _, value := range values {
+			if value == "" {
+				continue
+			}
+			if reason := rule.Type.check(value); reason != "" {
+				fail(rule, value, reason)
+			} else if patterns[i] != nil && !patterns[i].MatchString(value) {
+				fail(rule, value, fmt.Sprintf("does not match %s", rule.Pattern))
+			}
+		}
+	}
+	return failures
+}
+
+// validateCount checks the number of items found against the ruleset's expectation.
+func (rs *Ruleset) validateCount(items int) ValidationErrors {
+	if items >= rs.MinItems {
+		return nil
+	}
+	return ValidationErrors{{
+		Item:   -1,
+		Reason: fmt.Sprintf("found %d items rather than at least %d", items, rs.MinItems),
+	}}
+}
@@ -0,0 +1,97 @@
+package restify
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/yhat/scrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// PageVersion identifies a variant of a page that is published at its own URL.
+type PageVersion string
+
+const (
+	// VersionCanonical is the page as originally requested
+	VersionCanonical PageVersion = "canonical"
+	// VersionAmp is the Accelerated Mobile Pages variant declared by <link rel="amphtml">
+	VersionAmp PageVersion = "amp"
+	// VersionMobile is the separate mobile site declared by a <link rel="alternate"> with a
+	// media query for small screens
+	VersionMobile PageVersion = "mobile"
+)
+
+// WithPreferredVersions loads the first available of the given versions of each page instead
+// of the page itself, since AMP and mobile variants are usually far simpler to extract from.
+// When a page declares none of the versions, or loading the declared version fails, the
+// page itself is used. Response.Version reports the version that was loaded.
+func WithPreferredVersions(versions ...PageVersion) LoaderOption {
+	return func(l *Loader) {
+		l.preferredVersions = append(l.preferredVersions, versions...)
+	}
+}
+
+// FindVersionURL locates the URL of the given version of the page at root, which was retrieved
+// from pageUrl. If the page doesn't declare that version as an http or https URL, then ok will be
+// false.
+func FindVersionURL(root *html.Node, pageUrl *url.URL, version PageVersion) (versionUrl *url.URL, ok bool) {
+	var matcher scrape.Matcher
+	switch version {
+	case VersionAmp:
+		matcher = matchLinkRel("amphtml")
+	case VersionMobile:
+		matcher = func(n *html.Node) bool {
+			return n.DataAtom == atom.Link && hasRel(n, "alternate") && isMobileMedia(scrape.Attr(n, "media"))
+		}
+	default:
+		return nil, false
+	}
+
+	link, found := scrape.Find(root, matcher)
+	if !found || strings.TrimSpace(scrape.Attr(link, "href")) == "" {
+		return nil, false
+	}
+	versionUrl, ok = resolveReference(pageUrl, scrape.Attr(link, "href"))
+	if !ok || (versionUrl.Scheme != "http" && versionUrl.Scheme != "https") {
+		return nil, false
+	}
+	return versionUrl, true
+}
+
+// alternateRedirects lists the redirects of an alternate loaded in place of resp: those of resp,
+// then resp itself, then those followed to load the alternate.
+func alternateRedirects(resp *Response, redirects []Redirect) []Redirect {
+	combined := make([]Redirect, 0, len(resp.Redirects)+1+len(redirects))
+	combined = append(combined, resp.Redirects...)
+	combined = append(combined, Redirect{URL: resp.URL, Kind: RedirectAlternate})
+	return append(combined, redirects...)
+}
+
+// isMobileMedia reports whether a media query targets small screens, such as the
+// "only screen and (max-width: 640px)" used to annotate separate mobile sites.
+func isMobileMedia(media string) bool {
+	media = strings.ToLower(media)
+	return strings.Contains(media, "max-width") || strings.Contains(media, "handheld")
+}
+
+// preferVersion swaps resp for the first of the Loader's preferred versions that can be loaded.
+func (l *Loader) preferVersion(ctx context.Context, resp *Response) *Response {
+	for _, version := range l.preferredVersions {
+		versionUrl, ok := FindVersionURL(resp.Root, resp.URL, version)
+		if !ok || versionUrl.String() == resp.URL.String() {
+			continue
+		}
+
+		var redirects []Redirect
+		alternate, err := l.fetchOnce(ctx, resp.URL, versionUrl, &redirects)
+		if err != nil || alternate.StatusCode >= 400 {
+			continue
+		}
+		alternate.Redirects = alternateRedirects(resp, redirects)
+		alternate.Version = version
+		return alternate
+	}
+	return resp
+}
@@ -0,0 +1,169 @@
+package restify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is the time between checks of a Watcher when not configured.
+const DefaultWatchInterval = 5 * time.Minute
+
+// Change describes a page whose extracted records differ from those last seen.
+type Change struct {
+	// URL is the watched page
+	URL string `json:"url"`
+	// Previous are the records extracted when the page was last checked
+	Previous []Record `json:"previous"`
+	// Current are the records extracted now
+	Current []Record `json:"current"`
+	// Time is when the change was detected
+	Time time.Time `json:"time"`
+}
+
+// Watcher periodically loads a set of pages and extracts records from them with a Ruleset,
+// reporting whenever the records of a page change. The first check of each page records its
+// initial values and is not reported as a change.
+type Watcher struct {
+	// Loader loads the pages, a default Loader if nil
+	Loader *Loader
+	// URLs are the pages to watch
+	URLs []*url.URL
+	// Ruleset extracts the watched values from each page
+	Ruleset *Ruleset
+	// Interval is the time between checks, DefaultWatchInterval if zero
+	Interval time.Duration
+	// OnChange, when set, is called with each change
+	OnChange func(change *Change)
+	// OnError, when set, is called when a page cannot be checked or a webhook fails
+	OnError func(url *url.URL, err error)
+	// WebhookURL, when set, is sent each change as a JSON POST request
+	WebhookURL string
+	// WebhookClient sends webhook requests, a client with a timeout of HttpRequestTimeout if nil
+	WebhookClient *http.Client
+
+	mu       sync.Mutex
+	previous map[string][]Record
+}
+
+// Run checks the pages immediately and then at each interval until ctx is done, returning the
+// context's error.
+func (w *Watcher) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		w.Check(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Check loads each page once, reporting any changes through OnChange and the webhook, and
+// returns the changes found.
+func (w *Watcher) Check(ctx context.Context) []*Change {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.Loader == nil {
+		w.Loader = NewLoader()
+	}
+	if w.previous == nil {
+		w.previous = make(map[string][]Record)
+	}
+
+	var changes []*Change
+	for _, pageUrl := range w.URLs {
+		if ctx.Err() != nil {
+			break
+		}
+		change, err := w.check(ctx, pageUrl)
+		if err != nil {
+			w.reportError(pageUrl, err)
+			continue
+		}
+		if change == nil {
+			continue
+		}
+		changes = append(changes, change)
+
+		if w.OnChange != nil {
+			w.OnChange(change)
+		}
+		if w.WebhookURL != "" {
+			if err := w.postWebhook(ctx, change); err != nil {
+				w.reportError(pageUrl, err)
+			}
+		}
+	}
+	return changes
+}
+
+func (w *Watcher) check(ctx context.Context, pageUrl *url.URL) (*Change, error) {
+	root, err := w.Loader.LoadContext(ctx, pageUrl)
+	if err != nil {
+		return nil, err
+	}
+	current, err := w.Ruleset.Extract(root)
+	if err != nil {
+		return nil, err
+	}
+
+	key := pageUrl.String()
+	previous, seen := w.previous[key]
+	w.previous[key] = current
+	if !seen || reflect.DeepEqual(previous, current) {
+		return nil, nil
+	}
+	return &Change{URL: key, Previous: previous, Current: current, Time: time.Now()}, nil
+}
+
+func (w *Watcher) reportError(pageUrl *url.URL, err error) {
+	if w.OnError != nil {
+		w.OnError(pageUrl, err)
+	}
+}
+
+func (w *Watcher) postWebhook(ctx context.Context, change *Change) error {
+	body, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("Failed to encode change: %w", err)
+	}
+	request, err := http.NewRequestWithContext(ctx, "POST", w.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Failed to request webhook: %w", err)
+	}
+	request.Header.Set("content-type", "application/json")
+
+	client := w.WebhookClient
+	if client == nil {
+		client = &http.Client{Timeout: HttpRequestTimeout}
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("Failed to post webhook: %w", err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	io.Copy(ioutil.Discard, resp.Body)
+	//goland:noinspection GoUnhandledErrorResult
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}